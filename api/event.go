@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/flashbots/mempool-dumpster/common"
+)
+
+// Formats of the SSE transaction stream (query parameter format)
+const (
+	FormatRLP  = "rlp"  // id: <event id>, data: <raw tx RLP hex> (default)
+	FormatJSON = "json" // id: <event id>, data: <TxEvent JSON>
+)
+
+var ErrInvalidFormat = errors.New("invalid format")
+
+func parseFormat(format string) (string, error) {
+	switch format {
+	case "", FormatRLP:
+		return FormatRLP, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("%w: %s (supported: %s, %s)", ErrInvalidFormat, format, FormatRLP, FormatJSON)
+	}
+}
+
+// TxEvent is a transaction of the JSON stream, with the fields of TxSummaryEntry that are known when it's received
+type TxEvent struct {
+	Timestamp int64  `json:"timestamp"` // received at (ms)
+	Hash      string `json:"hash"`
+	ChainID   string `json:"chainId"`
+	TxType    int64  `json:"txType"`
+
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Value     string `json:"value"`
+	Nonce     string `json:"nonce"`
+	Gas       string `json:"gas"`
+	GasPrice  string `json:"gasPrice"`
+	GasTipCap string `json:"gasTipCap"`
+	GasFeeCap string `json:"gasFeeCap"`
+
+	DataSize   int64  `json:"dataSize"`
+	Data4Bytes string `json:"data4Bytes"`
+
+	Source  string   `json:"source"`  // first source
+	Sources []string `json:"sources"` // sources seen so far (the collector sends a tx when it's first seen, i.e. only the first source)
+
+	BlobCount           int64    `json:"blobCount,omitempty"`
+	BlobVersionedHashes []string `json:"blobVersionedHashes,omitempty"`
+	MaxFeePerBlobGas    string   `json:"maxFeePerBlobGas,omitempty"`
+	BlobGas             int64    `json:"blobGas,omitempty"`
+
+	AuthorizationCount int64    `json:"authorizationCount,omitempty"`
+	Authorizations     []string `json:"authorizations,omitempty"`
+
+	RawTx string `json:"rawTx"` // RLP hex, like the rlp format
+}
+
+func NewTxEvent(tx *common.TxIn) (*TxEvent, error) {
+	summary, _, err := common.ParseTx(tx.T.UnixMilli(), tx.Tx)
+	if err != nil {
+		return nil, err
+	}
+	rawTx, err := common.TxToRLPString(tx.Tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TxEvent{
+		Timestamp:           summary.Timestamp,
+		Hash:                summary.Hash,
+		ChainID:             summary.ChainID,
+		TxType:              summary.TxType,
+		From:                summary.From,
+		To:                  summary.To,
+		Value:               summary.Value,
+		Nonce:               summary.Nonce,
+		Gas:                 summary.Gas,
+		GasPrice:            summary.GasPrice,
+		GasTipCap:           summary.GasTipCap,
+		GasFeeCap:           summary.GasFeeCap,
+		DataSize:            summary.DataSize,
+		Data4Bytes:          summary.Data4Bytes,
+		Source:              tx.Source,
+		Sources:             []string{tx.Source},
+		BlobCount:           summary.BlobCount,
+		BlobVersionedHashes: summary.BlobVersionedHashes,
+		MaxFeePerBlobGas:    summary.MaxFeePerBlobGas,
+		BlobGas:             summary.BlobGas,
+		AuthorizationCount:  summary.AuthorizationCount,
+		Authorizations:      summary.Authorizations,
+		RawTx:               rawTx,
+	}, nil
+}
+
+// encodeTx returns the data of a transaction event in the given format
+func encodeTx(tx *common.TxIn, format string) (string, error) {
+	if format == FormatJSON {
+		event, err := NewTxEvent(tx)
+		if err != nil {
+			return "", err
+		}
+		b, err := json.Marshal(event)
+		return string(b), err
+	}
+	return common.TxToRLPString(tx.Tx)
+}
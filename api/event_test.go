@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribeSSE opens /sse/transactions with the query and Last-Event-ID (if not empty). The subscription is registered
+// when the headers are received.
+func subscribeSSE(t *testing.T, url, query, lastEventID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/sse/transactions?"+query, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req) //nolint:bodyclose
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return bufio.NewReader(resp.Body)
+}
+
+// readSSEEvent returns the lines of the next event
+func readSSEEvent(t *testing.T, r *bufio.Reader) []string {
+	t.Helper()
+	lines := make([]string, 0)
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestServer_handleTxSSE_formats(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)}) //nolint:exhaustruct
+	srv := httptest.NewServer(s.srv.Handler)
+	t.Cleanup(srv.Close) // after the subscriptions are closed (cleanups run in reverse order)
+
+	rawStream := subscribeSSE(t, srv.URL, "", "")
+	jsonStream := subscribeSSE(t, srv.URL, "format=json", "")
+
+	txIn, from := newTestTxIn(t, "local", 20_000_000_000, append(testSelector, make([]byte, 64)...))
+	txIn.T = time.UnixMilli(1693785600337)
+	require.NoError(t, s.SendTx(context.Background(), txIn))
+	txRLP, err := common.TxToRLPString(txIn.Tx)
+	require.NoError(t, err)
+
+	// default: event id and the raw transaction
+	id := s.lastEventID
+	require.Equal(t, []string{fmt.Sprintf("id: %d", id), "data: " + txRLP}, readSSEEvent(t, rawStream))
+
+	// json: event id and TxEvent
+	lines := readSSEEvent(t, jsonStream)
+	require.Len(t, lines, 2)
+	require.Equal(t, fmt.Sprintf("id: %d", id), lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "data: "))
+
+	var event TxEvent
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event))
+	require.Equal(t, int64(1693785600337), event.Timestamp)
+	require.Equal(t, txIn.Tx.Hash().Hex(), event.Hash)
+	require.Equal(t, strings.ToLower(from.Hex()), event.From)
+	require.Equal(t, strings.ToLower(testTo.Hex()), event.To)
+	require.Equal(t, "20000000000", event.GasFeeCap)
+	require.Equal(t, "0xa9059cbb", event.Data4Bytes)
+	require.Equal(t, int64(2), event.TxType)
+	require.Equal(t, "local", event.Source)
+	require.Equal(t, []string{"local"}, event.Sources)
+	require.Equal(t, txRLP, event.RawTx)
+
+	// ids increase with every transaction
+	txIn2, _ := newTestTxIn(t, "local", 1, nil)
+	require.NoError(t, s.SendTx(context.Background(), txIn2))
+	require.Equal(t, fmt.Sprintf("id: %d", id+1), readSSEEvent(t, jsonStream)[0])
+}
+
+func TestServer_handleTxSSE_invalidFormat(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)}) //nolint:exhaustruct
+
+	req := httptest.NewRequest(http.MethodGet, "/sse/transactions?format=xml", nil)
+	w := httptest.NewRecorder()
+	s.handleTxSSE(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "invalid format")
+}
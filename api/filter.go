@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/mempool-dumpster/common"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// TxFilter selects the transactions of a subscription. It's compiled from the query parameters:
+//
+//	to, from  address
+//	selector  4-byte function selector (0x-prefixed hex), "0x" for transactions without calldata
+//	type      transaction type (0: legacy, 1: access list, 2: dynamic fee, 3: blob, 4: set code)
+//	min_fee   minimum max fee per gas (gas price for legacy transactions) in wei
+//	source    source of the transaction ("all" for any source)
+//
+// A parameter can be repeated or hold a comma separated list, and matches if any value matches. A transaction has to
+// match all parameters. Without parameters, all transactions match.
+type TxFilter struct {
+	to        map[ethcommon.Address]bool
+	from      map[ethcommon.Address]bool
+	selectors map[string]bool // 0x-prefixed lowercase hex, "0x" for no calldata
+	txTypes   map[uint8]bool
+	minFee    *big.Int
+	sources   *common.SourceAllowList
+}
+
+// filterValues returns the values of a query parameter, split at commas
+func filterValues(query url.Values, key string) []string {
+	values := make([]string, 0)
+	for _, value := range query[key] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+func parseAddresses(query url.Values, key string) (map[ethcommon.Address]bool, error) {
+	values := filterValues(query, key)
+	if len(values) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+	addresses := make(map[ethcommon.Address]bool, len(values))
+	for _, v := range values {
+		if !ethcommon.IsHexAddress(v) {
+			return nil, fmt.Errorf("%w: %s=%s is not an address", ErrInvalidFilter, key, v)
+		}
+		addresses[ethcommon.HexToAddress(v)] = true
+	}
+	return addresses, nil
+}
+
+// ParseTxFilter compiles the filter from the query parameters of a subscription request
+func ParseTxFilter(query url.Values) (f *TxFilter, err error) {
+	f = &TxFilter{} //nolint:exhaustruct
+
+	if f.to, err = parseAddresses(query, "to"); err != nil {
+		return nil, err
+	}
+	if f.from, err = parseAddresses(query, "from"); err != nil {
+		return nil, err
+	}
+
+	for _, v := range filterValues(query, "selector") {
+		b, err := hexutil.Decode(v)
+		if err != nil || (len(b) != 4 && len(b) != 0) {
+			return nil, fmt.Errorf("%w: selector=%s is not a 4-byte selector", ErrInvalidFilter, v)
+		}
+		if f.selectors == nil {
+			f.selectors = make(map[string]bool)
+		}
+		f.selectors[hexutil.Encode(b)] = true
+	}
+
+	for _, v := range filterValues(query, "type") {
+		txType, err := strconv.ParseUint(v, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("%w: type=%s is not a transaction type", ErrInvalidFilter, v)
+		}
+		if f.txTypes == nil {
+			f.txTypes = make(map[uint8]bool)
+		}
+		f.txTypes[uint8(txType)] = true
+	}
+
+	minFee := filterValues(query, "min_fee")
+	if len(minFee) > 1 {
+		return nil, fmt.Errorf("%w: min_fee can only be set once", ErrInvalidFilter)
+	} else if len(minFee) == 1 {
+		fee, ok := new(big.Int).SetString(minFee[0], 10)
+		if !ok || fee.Sign() < 0 {
+			return nil, fmt.Errorf("%w: min_fee=%s is not an amount in wei", ErrInvalidFilter, minFee[0])
+		}
+		f.minFee = fee
+	}
+
+	if sources := filterValues(query, "source"); len(sources) > 0 {
+		f.sources = common.NewSourceAllowList(sources)
+	}
+	return f, nil
+}
+
+// Match returns true if the transaction passes the filter (a nil filter matches all transactions)
+func (f *TxFilter) Match(tx *common.TxIn) bool {
+	if f == nil {
+		return true
+	}
+	if f.sources != nil && !f.sources.Allows(tx.Source) {
+		return false
+	}
+	if f.txTypes != nil && !f.txTypes[tx.Tx.Type()] {
+		return false
+	}
+	if f.minFee != nil && tx.Tx.GasFeeCap().Cmp(f.minFee) < 0 {
+		return false
+	}
+	if f.to != nil && (tx.Tx.To() == nil || !f.to[*tx.Tx.To()]) {
+		return false
+	}
+	if f.selectors != nil {
+		data := tx.Tx.Data()
+		if !f.selectors[hexutil.Encode(data[:min(len(data), 4)])] {
+			return false
+		}
+	}
+	if f.from != nil {
+		// last, because recovering the sender is expensive (it's cached in the transaction for the other subscribers)
+		from, err := types.Sender(types.LatestSignerForChainID(tx.Tx.ChainId()), tx.Tx)
+		if err != nil || !f.from[from] {
+			return false
+		}
+	}
+	return true
+}
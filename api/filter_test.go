@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testTo       = ethcommon.HexToAddress("0x7a250d5630b4cf539739df2c5dacb4c659f2488d")
+	testSelector = []byte{0xa9, 0x05, 0x9c, 0xbb}
+)
+
+// newTestTxIn returns a signed dynamic fee transaction to testTo (with calldata), and its sender
+func newTestTxIn(t *testing.T, source string, gasFeeCap int64, data []byte) (*common.TxIn, ethcommon.Address) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.DynamicFeeTx{ //nolint:exhaustruct
+		ChainID:   big.NewInt(1),
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(gasFeeCap),
+		Gas:       21000,
+		To:        &testTo,
+		Data:      data,
+	})
+	require.NoError(t, err)
+	return &common.TxIn{Tx: tx, Source: source}, crypto.PubkeyToAddress(key.PublicKey) //nolint:exhaustruct
+}
+
+func TestTxFilter(t *testing.T) {
+	tx, from := newTestTxIn(t, "local", 20_000_000_000, append(testSelector, make([]byte, 64)...))
+
+	tests := []struct {
+		query string
+		match bool
+	}{
+		{"", true},
+		{"to=0x7a250d5630b4cf539739df2c5dacb4c659f2488d", true},
+		{"to=0x7A250D5630B4CF539739DF2C5DACB4C659F2488D", true},
+		{"to=0x0000000000000000000000000000000000000001", false},
+		{"to=0x0000000000000000000000000000000000000001,0x7a250d5630b4cf539739df2c5dacb4c659f2488d", true},
+		{"to=0x0000000000000000000000000000000000000001&to=0x7a250d5630b4cf539739df2c5dacb4c659f2488d", true},
+		{"from=" + from.Hex(), true},
+		{"from=0x0000000000000000000000000000000000000001", false},
+		{"selector=0xa9059cbb", true},
+		{"selector=0x095ea7b3", false},
+		{"selector=0x", false},
+		{"type=2", true},
+		{"type=0,1", false},
+		{"min_fee=20000000000", true},
+		{"min_fee=20000000001", false},
+		{"source=local", true},
+		{"source=bloxroute", false},
+		{"source=all", true},
+		{"to=0x7a250d5630b4cf539739df2c5dacb4c659f2488d&selector=0xa9059cbb&type=2&source=local&from=" + from.Hex(), true},
+		{"to=0x7a250d5630b4cf539739df2c5dacb4c659f2488d&selector=0xa9059cbb&type=2&source=eden", false},
+		{"format=json", true}, // unknown parameters are ignored
+	}
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		require.NoError(t, err)
+		filter, err := ParseTxFilter(query)
+		require.NoError(t, err, test.query)
+		require.Equal(t, test.match, filter.Match(tx), test.query)
+	}
+
+	// transaction without calldata
+	txNoData, _ := newTestTxIn(t, "local", 1, nil)
+	filter, err := ParseTxFilter(url.Values{"selector": {"0x"}})
+	require.NoError(t, err)
+	require.True(t, filter.Match(txNoData))
+	require.False(t, filter.Match(tx))
+
+	// nil filter matches all
+	require.True(t, (*TxFilter)(nil).Match(tx))
+}
+
+func TestParseTxFilter_invalid(t *testing.T) {
+	for _, query := range []string{
+		"to=0x1234",
+		"from=abc",
+		"selector=0xa9059c",
+		"selector=xyz",
+		"type=256",
+		"type=dynamic",
+		"min_fee=-1",
+		"min_fee=1.5",
+		"min_fee=1&min_fee=2",
+	} {
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		_, err = ParseTxFilter(values)
+		require.ErrorIs(t, err, ErrInvalidFilter, query)
+	}
+}
+
+func TestServer_SendTx_filter(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)}) //nolint:exhaustruct
+
+	subAll := &SSESubscription{uid: "all", txC: make(chan sseEvent, 10), format: FormatRLP}     //nolint:exhaustruct
+	subLocal := &SSESubscription{uid: "local", txC: make(chan sseEvent, 10), format: FormatRLP} //nolint:exhaustruct
+	subLocal.filter = &TxFilter{sources: common.NewSourceAllowList([]string{"local"})}          //nolint:exhaustruct
+	s.addSubscriber(subAll)
+	s.addSubscriber(subLocal)
+
+	txLocal, _ := newTestTxIn(t, "local", 1, nil)
+	txOther, _ := newTestTxIn(t, "other", 1, nil)
+	require.NoError(t, s.SendTx(context.Background(), txLocal))
+	require.NoError(t, s.SendTx(context.Background(), txOther))
+
+	rlpLocal, err := common.TxToRLPString(txLocal.Tx)
+	require.NoError(t, err)
+	require.Len(t, subAll.txC, 2)
+	require.Len(t, subLocal.txC, 1)
+	require.Equal(t, rlpLocal, (<-subLocal.txC).data)
+}
+
+func TestServer_handleTxSSE_invalidFilter(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)}) //nolint:exhaustruct
+
+	req := httptest.NewRequest(http.MethodGet, "/sse/transactions?to=invalid", nil)
+	w := httptest.NewRecorder()
+	s.handleTxSSE(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "invalid filter")
+	require.Empty(t, s.sseConnectionMap)
+}
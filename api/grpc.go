@@ -0,0 +1,136 @@
+package api
+
+//
+// gRPC StreamTransactions service (see pb/transactions.proto). Regenerate the code with `make generate-proto`.
+//
+// Every call has a bounded queue. If the client doesn't keep up (gRPC flow control blocks sending), transactions are
+// dropped instead of blocking the other receivers, and the number of dropped transactions is sent with the next one.
+//
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/flashbots/mempool-dumpster/api/pb"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/google/uuid"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const grpcQueueSize = 1000 // transactions queued per StreamTransactions call
+
+// grpcSubscription is a StreamTransactions call
+type grpcSubscription struct {
+	uid     string
+	filter  *TxFilter
+	txC     chan *common.TxIn
+	dropped atomic.Uint64 // transactions dropped because txC was full
+}
+
+// txStreamServer implements the Transactions service
+type txStreamServer struct {
+	pb.UnimplementedTransactionsServer
+	s *Server
+}
+
+// filterQuery returns the request filters as query parameters of /sse/transactions, so they are parsed the same way
+func filterQuery(req *pb.StreamTransactionsRequest) url.Values {
+	query := url.Values{
+		"to":       req.GetTo(),
+		"from":     req.GetFrom(),
+		"selector": req.GetSelectors(),
+		"source":   req.GetSources(),
+	}
+	for _, txType := range req.GetTypes() {
+		query.Add("type", strconv.FormatUint(uint64(txType), 10))
+	}
+	if req.GetMinFee() != "" {
+		query.Set("min_fee", req.GetMinFee())
+	}
+	return query
+}
+
+// newTxMessage returns the protobuf message of a transaction
+func newTxMessage(tx *common.TxIn) (*pb.Transaction, error) {
+	rawTx, err := tx.Tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Transaction{ //nolint:exhaustruct
+		Hash:        tx.Tx.Hash().Bytes(),
+		RawTx:       rawTx,
+		TimestampMs: tx.T.UnixMilli(),
+		Sources:     []string{tx.Source},
+	}, nil
+}
+
+// StreamTransactions sends the transactions that match the request filters, until the call ends or the server shuts down
+func (g *txStreamServer) StreamTransactions(req *pb.StreamTransactionsRequest, stream grpc.ServerStreamingServer[pb.Transaction]) error {
+	filter, err := ParseTxFilter(filterQuery(req))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub := &grpcSubscription{ //nolint:exhaustruct
+		uid:    uuid.New().String(),
+		filter: filter,
+		txC:    make(chan *common.TxIn, grpcQueueSize),
+	}
+	g.s.addGRPCSubscriber(sub)
+	defer g.s.removeGRPCSubscriber(sub)
+	g.s.log.Infow("gRPC transaction stream opened", "request", req.String())
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+
+		case <-g.s.shutdownC:
+			return status.Error(codes.Unavailable, "server shutting down")
+
+		case tx := <-sub.txC:
+			msg, err := newTxMessage(tx)
+			if err != nil {
+				g.s.log.Errorw("failed to encode tx", "error", err)
+				continue
+			}
+			msg.Dropped = sub.dropped.Swap(0)
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) addGRPCSubscriber(sub *grpcSubscription) {
+	s.grpcSubscriptionLock.Lock()
+	defer s.grpcSubscriptionLock.Unlock()
+	s.grpcSubscriptions[sub.uid] = sub
+}
+
+func (s *Server) removeGRPCSubscriber(sub *grpcSubscription) {
+	s.grpcSubscriptionLock.Lock()
+	defer s.grpcSubscriptionLock.Unlock()
+	delete(s.grpcSubscriptions, sub.uid)
+	s.log.With("subscribers", len(s.grpcSubscriptions)).Debug("removed gRPC subscriber")
+}
+
+// sendTxGRPC queues the transaction for the gRPC streams whose filter matches (only if their queue is not full)
+func (s *Server) sendTxGRPC(tx *common.TxIn) {
+	s.grpcSubscriptionLock.RLock()
+	defer s.grpcSubscriptionLock.RUnlock()
+
+	for _, sub := range s.grpcSubscriptions {
+		if !sub.filter.Match(tx) {
+			continue
+		}
+		select {
+		case sub.txC <- tx:
+		default:
+			sub.dropped.Inc()
+		}
+	}
+}
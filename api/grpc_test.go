@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/mempool-dumpster/api/pb"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// newTestGRPCClient serves the gRPC service of the server on a local port, and returns a client
+func newTestGRPCClient(t *testing.T, s *Server) pb.TransactionsClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = s.grpcServer.Serve(lis) }()
+	t.Cleanup(s.grpcServer.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewTransactionsClient(conn)
+}
+
+// waitGRPCSubscribers waits until the streams are registered
+func waitGRPCSubscribers(t *testing.T, s *Server, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		s.grpcSubscriptionLock.RLock()
+		defer s.grpcSubscriptionLock.RUnlock()
+		return len(s.grpcSubscriptions) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestServer_StreamTransactions(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)}) //nolint:exhaustruct
+	client := newTestGRPCClient(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	streamAll, err := client.StreamTransactions(ctx, &pb.StreamTransactionsRequest{}) //nolint:exhaustruct
+	require.NoError(t, err)
+	streamLocal, err := client.StreamTransactions(ctx, &pb.StreamTransactionsRequest{Sources: []string{"local"}, Types: []uint32{2}}) //nolint:exhaustruct
+	require.NoError(t, err)
+	waitGRPCSubscribers(t, s, 2)
+
+	txOther, _ := newTestTxIn(t, "other", 1, nil)
+	txLocal, _ := newTestTxIn(t, "local", 1, append(testSelector, make([]byte, 64)...))
+	txLocal.T = time.UnixMilli(1693785600337)
+	require.NoError(t, s.SendTx(ctx, txOther))
+	require.NoError(t, s.SendTx(ctx, txLocal))
+
+	// without filters: all transactions
+	msg, err := streamAll.Recv()
+	require.NoError(t, err)
+	require.Equal(t, txOther.Tx.Hash().Bytes(), msg.GetHash())
+	msg, err = streamAll.Recv()
+	require.NoError(t, err)
+	require.Equal(t, txLocal.Tx.Hash().Bytes(), msg.GetHash())
+
+	// filtered: only the local transaction
+	msg, err = streamLocal.Recv()
+	require.NoError(t, err)
+	require.Equal(t, txLocal.Tx.Hash().Bytes(), msg.GetHash())
+	require.Equal(t, int64(1693785600337), msg.GetTimestampMs())
+	require.Equal(t, []string{"local"}, msg.GetSources())
+	require.Equal(t, uint64(0), msg.GetDropped())
+
+	var tx types.Transaction
+	require.NoError(t, tx.UnmarshalBinary(msg.GetRawTx()))
+	require.Equal(t, txLocal.Tx.Hash(), tx.Hash())
+
+	// closing the stream removes the subscription
+	cancel()
+	waitGRPCSubscribers(t, s, 0)
+}
+
+func TestServer_StreamTransactions_invalidFilter(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)}) //nolint:exhaustruct
+	client := newTestGRPCClient(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.StreamTransactions(ctx, &pb.StreamTransactionsRequest{To: []string{"0x1234"}}) //nolint:exhaustruct
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Contains(t, err.Error(), "invalid filter")
+}
+
+func TestServer_sendTxGRPC_dropped(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)})        //nolint:exhaustruct
+	sub := &grpcSubscription{uid: "slow", txC: make(chan *common.TxIn, 1)} //nolint:exhaustruct
+	s.addGRPCSubscriber(sub)
+
+	// the queue holds one transaction, the others are counted as dropped
+	for range 3 {
+		txIn, _ := newTestTxIn(t, "local", 1, nil)
+		require.NoError(t, s.SendTx(context.Background(), txIn))
+	}
+	require.Len(t, sub.txC, 1)
+	require.Equal(t, uint64(2), sub.dropped.Load())
+}
@@ -1,20 +1,82 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"go.uber.org/atomic"
 )
 
+var ErrInvalidLastEventID = errors.New("invalid Last-Event-ID")
+
 type SSESubscription struct {
-	uid string
-	txC chan string
+	uid    string
+	txC    chan sseEvent
+	filter *TxFilter
+	format string
+
+	reportGaps bool          // send gap events (json format, or when resuming with Last-Event-ID)
+	missed     atomic.Uint64 // events dropped because txC was full
+}
+
+// sseEvent is a transaction event, encoded in the format of the subscription
+type sseEvent struct {
+	id   uint64
+	data string
+}
+
+func (sub *SSESubscription) writeEvent(w http.ResponseWriter, event sseEvent) {
+	if sub.reportGaps {
+		if missed := sub.missed.Swap(0); missed > 0 {
+			sub.writeGap(w, &GapEvent{Reason: GapReasonDropped, Missed: missed}) //nolint:exhaustruct
+		}
+	}
+
+	fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.id, event.data)
+}
+
+// writeGap writes a gap event (without id, so the client keeps its Last-Event-ID)
+func (sub *SSESubscription) writeGap(w http.ResponseWriter, gap *GapEvent) {
+	data, _ := json.Marshal(gap) //nolint:errchkjson
+	fmt.Fprintf(w, "event: gap\ndata: %s\n\n", data)
+}
+
+// parseLastEventID returns the Last-Event-ID of a reconnecting client (ok is false for new subscriptions)
+func parseLastEventID(r *http.Request) (lastEventID uint64, ok bool, err error) {
+	header := r.Header.Get("Last-Event-ID")
+	if header == "" {
+		return 0, false, nil
+	}
+	lastEventID, err = strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: %s", ErrInvalidLastEventID, header)
+	}
+	return lastEventID, true, nil
 }
 
 func (s *Server) handleTxSSE(w http.ResponseWriter, r *http.Request) {
 	// SSE server for transactions
-	s.log.Info("SSE connection opened for transactions")
+	filter, err := ParseTxFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := parseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lastEventID, resume, err := parseLastEventID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.log.Infow("SSE connection opened for transactions", "query", r.URL.RawQuery, "lastEventID", lastEventID)
 
 	// Set CORS headers to allow all origins. You may want to restrict this to specific origins in a production environment.
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -24,11 +86,45 @@ func (s *Server) handleTxSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
-	subscriber := SSESubscription{
-		uid: uuid.New().String(),
-		txC: make(chan string, 100),
+	subscriber := SSESubscription{ //nolint:exhaustruct
+		uid:        uuid.New().String(),
+		txC:        make(chan sseEvent, 100),
+		filter:     filter,
+		format:     format,
+		reportGaps: format == FormatJSON || resume,
 	}
+
+	// subscribe and take the missed events at the same time, so that none is lost or sent twice
+	var (
+		replay []replayEntry
+		gap    *GapEvent
+	)
+	s.eventLock.Lock()
 	s.addSubscriber(&subscriber)
+	if resume {
+		replay, gap = s.replay.since(lastEventID, s.lastEventID+1, time.Now())
+	}
+	s.eventLock.Unlock()
+
+	// send the headers, so the client knows the subscription is open
+	w.WriteHeader(http.StatusOK)
+
+	// replay the missed events
+	if gap != nil {
+		subscriber.writeGap(w, gap)
+	}
+	for _, entry := range replay {
+		if !filter.Match(entry.tx) {
+			continue
+		}
+		data, err := encodeTx(entry.tx, format)
+		if err != nil {
+			s.log.Errorw("failed to encode replayed tx", "error", err)
+			continue
+		}
+		subscriber.writeEvent(w, sseEvent{id: entry.id, data: data})
+	}
+	w.(http.Flusher).Flush() //nolint:forcetypeassert
 
 	// pingTicker := time.NewTicker(5 * time.Second)
 
@@ -40,9 +136,14 @@ func (s *Server) handleTxSSE(w http.ResponseWriter, r *http.Request) {
 			s.removeSubscriber(&subscriber)
 			return
 
-		case tx := <-subscriber.txC:
+		case <-s.shutdownC:
+			s.log.Info("server shutting down, closing SSE connection")
+			s.removeSubscriber(&subscriber)
+			return
+
+		case event := <-subscriber.txC:
 			// Note/TODO: a client with a slow connection may cause blocking other clients and cause DoS on all receivers
-			fmt.Fprintf(w, "data: %s\n\n", tx)
+			subscriber.writeEvent(w, event)
 			w.(http.Flusher).Flush() //nolint:forcetypeassert
 
 			// case <-pingTicker.C:
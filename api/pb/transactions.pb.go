@@ -0,0 +1,257 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: pb/transactions.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// StreamTransactionsRequest has the filters of the stream. A transaction must match all the given filters, and any
+// of the values of a filter (same as the query parameters of /sse/transactions).
+type StreamTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	To            []string               `protobuf:"bytes,1,rep,name=to,proto3" json:"to,omitempty"`                       // recipient addresses
+	From          []string               `protobuf:"bytes,2,rep,name=from,proto3" json:"from,omitempty"`                   // sender addresses
+	Selectors     []string               `protobuf:"bytes,3,rep,name=selectors,proto3" json:"selectors,omitempty"`         // 4-byte method selectors (0x matches transactions without calldata)
+	Types         []uint32               `protobuf:"varint,4,rep,packed,name=types,proto3" json:"types,omitempty"`         // transaction types
+	MinFee        string                 `protobuf:"bytes,5,opt,name=min_fee,json=minFee,proto3" json:"min_fee,omitempty"` // min gas fee cap in wei (decimal)
+	Sources       []string               `protobuf:"bytes,6,rep,name=sources,proto3" json:"sources,omitempty"`             // sources the transaction was received from ("all" matches any source)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamTransactionsRequest) Reset() {
+	*x = StreamTransactionsRequest{}
+	mi := &file_pb_transactions_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamTransactionsRequest) ProtoMessage() {}
+
+func (x *StreamTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_transactions_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*StreamTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_pb_transactions_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StreamTransactionsRequest) GetTo() []string {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *StreamTransactionsRequest) GetFrom() []string {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *StreamTransactionsRequest) GetSelectors() []string {
+	if x != nil {
+		return x.Selectors
+	}
+	return nil
+}
+
+func (x *StreamTransactionsRequest) GetTypes() []uint32 {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *StreamTransactionsRequest) GetMinFee() string {
+	if x != nil {
+		return x.MinFee
+	}
+	return ""
+}
+
+func (x *StreamTransactionsRequest) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+type Transaction struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Hash        []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	RawTx       []byte                 `protobuf:"bytes,2,opt,name=raw_tx,json=rawTx,proto3" json:"raw_tx,omitempty"`                    // binary encoding of the transaction (with blob sidecar)
+	TimestampMs int64                  `protobuf:"varint,3,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"` // time the transaction was received
+	Sources     []string               `protobuf:"bytes,4,rep,name=sources,proto3" json:"sources,omitempty"`
+	// number of matching transactions that were dropped before this one, because the client didn't keep up
+	Dropped       uint64 `protobuf:"varint,5,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_pb_transactions_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_transactions_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_pb_transactions_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Transaction) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Transaction) GetRawTx() []byte {
+	if x != nil {
+		return x.RawTx
+	}
+	return nil
+}
+
+func (x *Transaction) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *Transaction) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *Transaction) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+var File_pb_transactions_proto protoreflect.FileDescriptor
+
+const file_pb_transactions_proto_rawDesc = "" +
+	"\n" +
+	"\x15pb/transactions.proto\x12\x12mempooldumpster.v1\"\xa6\x01\n" +
+	"\x19StreamTransactionsRequest\x12\x0e\n" +
+	"\x02to\x18\x01 \x03(\tR\x02to\x12\x12\n" +
+	"\x04from\x18\x02 \x03(\tR\x04from\x12\x1c\n" +
+	"\tselectors\x18\x03 \x03(\tR\tselectors\x12\x14\n" +
+	"\x05types\x18\x04 \x03(\rR\x05types\x12\x17\n" +
+	"\amin_fee\x18\x05 \x01(\tR\x06minFee\x12\x18\n" +
+	"\asources\x18\x06 \x03(\tR\asources\"\x8f\x01\n" +
+	"\vTransaction\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\x12\x15\n" +
+	"\x06raw_tx\x18\x02 \x01(\fR\x05rawTx\x12!\n" +
+	"\ftimestamp_ms\x18\x03 \x01(\x03R\vtimestampMs\x12\x18\n" +
+	"\asources\x18\x04 \x03(\tR\asources\x12\x18\n" +
+	"\adropped\x18\x05 \x01(\x04R\adropped2v\n" +
+	"\fTransactions\x12f\n" +
+	"\x12StreamTransactions\x12-.mempooldumpster.v1.StreamTransactionsRequest\x1a\x1f.mempooldumpster.v1.Transaction0\x01B.Z,github.com/flashbots/mempool-dumpster/api/pbb\x06proto3"
+
+var (
+	file_pb_transactions_proto_rawDescOnce sync.Once
+	file_pb_transactions_proto_rawDescData []byte
+)
+
+func file_pb_transactions_proto_rawDescGZIP() []byte {
+	file_pb_transactions_proto_rawDescOnce.Do(func() {
+		file_pb_transactions_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pb_transactions_proto_rawDesc), len(file_pb_transactions_proto_rawDesc)))
+	})
+	return file_pb_transactions_proto_rawDescData
+}
+
+var file_pb_transactions_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_pb_transactions_proto_goTypes = []any{
+	(*StreamTransactionsRequest)(nil), // 0: mempooldumpster.v1.StreamTransactionsRequest
+	(*Transaction)(nil),               // 1: mempooldumpster.v1.Transaction
+}
+var file_pb_transactions_proto_depIdxs = []int32{
+	0, // 0: mempooldumpster.v1.Transactions.StreamTransactions:input_type -> mempooldumpster.v1.StreamTransactionsRequest
+	1, // 1: mempooldumpster.v1.Transactions.StreamTransactions:output_type -> mempooldumpster.v1.Transaction
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_pb_transactions_proto_init() }
+func file_pb_transactions_proto_init() {
+	if File_pb_transactions_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pb_transactions_proto_rawDesc), len(file_pb_transactions_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pb_transactions_proto_goTypes,
+		DependencyIndexes: file_pb_transactions_proto_depIdxs,
+		MessageInfos:      file_pb_transactions_proto_msgTypes,
+	}.Build()
+	File_pb_transactions_proto = out.File
+	file_pb_transactions_proto_goTypes = nil
+	file_pb_transactions_proto_depIdxs = nil
+}
@@ -0,0 +1,130 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pb/transactions.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Transactions_StreamTransactions_FullMethodName = "/mempooldumpster.v1.Transactions/StreamTransactions"
+)
+
+// TransactionsClient is the client API for Transactions service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Transactions streams the transactions received by the collector
+type TransactionsClient interface {
+	// StreamTransactions streams the transactions that match the filters, until the client cancels the call
+	StreamTransactions(ctx context.Context, in *StreamTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transaction], error)
+}
+
+type transactionsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTransactionsClient(cc grpc.ClientConnInterface) TransactionsClient {
+	return &transactionsClient{cc}
+}
+
+func (c *transactionsClient) StreamTransactions(ctx context.Context, in *StreamTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transaction], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transactions_ServiceDesc.Streams[0], Transactions_StreamTransactions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamTransactionsRequest, Transaction]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transactions_StreamTransactionsClient = grpc.ServerStreamingClient[Transaction]
+
+// TransactionsServer is the server API for Transactions service.
+// All implementations must embed UnimplementedTransactionsServer
+// for forward compatibility.
+//
+// Transactions streams the transactions received by the collector
+type TransactionsServer interface {
+	// StreamTransactions streams the transactions that match the filters, until the client cancels the call
+	StreamTransactions(*StreamTransactionsRequest, grpc.ServerStreamingServer[Transaction]) error
+	mustEmbedUnimplementedTransactionsServer()
+}
+
+// UnimplementedTransactionsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTransactionsServer struct{}
+
+func (UnimplementedTransactionsServer) StreamTransactions(*StreamTransactionsRequest, grpc.ServerStreamingServer[Transaction]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTransactions not implemented")
+}
+func (UnimplementedTransactionsServer) mustEmbedUnimplementedTransactionsServer() {}
+func (UnimplementedTransactionsServer) testEmbeddedByValue()                      {}
+
+// UnsafeTransactionsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TransactionsServer will
+// result in compilation errors.
+type UnsafeTransactionsServer interface {
+	mustEmbedUnimplementedTransactionsServer()
+}
+
+func RegisterTransactionsServer(s grpc.ServiceRegistrar, srv TransactionsServer) {
+	// If the following call pancis, it indicates UnimplementedTransactionsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Transactions_ServiceDesc, srv)
+}
+
+func _Transactions_StreamTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TransactionsServer).StreamTransactions(m, &grpc.GenericServerStream[StreamTransactionsRequest, Transaction]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transactions_StreamTransactionsServer = grpc.ServerStreamingServer[Transaction]
+
+// Transactions_ServiceDesc is the grpc.ServiceDesc for Transactions service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Transactions_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mempooldumpster.v1.Transactions",
+	HandlerType: (*TransactionsServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTransactions",
+			Handler:       _Transactions_StreamTransactions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pb/transactions.proto",
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+)
+
+// Reasons of gap events
+const (
+	GapReasonEvicted = "evicted" // the events after Last-Event-ID aren't in the replay buffer anymore (i.e. after a restart)
+	GapReasonUnknown = "unknown" // Last-Event-ID is newer than the last event (it wasn't sent by this server)
+	GapReasonDropped = "dropped" // events were dropped because the client didn't keep up
+)
+
+// GapEvent tells a client that it missed events (SSE event type "gap")
+type GapEvent struct {
+	Reason      string `json:"reason"`
+	LastEventID uint64 `json:"lastEventId,omitempty"` // Last-Event-ID of the client (evicted and unknown)
+	NextEventID uint64 `json:"nextEventId,omitempty"` // id of the first event after the gap (evicted and unknown)
+	Missed      uint64 `json:"missed,omitempty"`      // number of dropped events (dropped)
+}
+
+type replayEntry struct {
+	id    uint64
+	added time.Time
+	tx    *common.TxIn
+}
+
+// replayBuffer is a ring buffer of the recent transaction events, bounded in size and age. It isn't safe for
+// concurrent use.
+type replayBuffer struct {
+	entries []replayEntry
+	start   int // index of the oldest entry
+	n       int
+	window  time.Duration // 0: no age limit
+}
+
+func newReplayBuffer(size int, window time.Duration) *replayBuffer {
+	return &replayBuffer{ //nolint:exhaustruct
+		entries: make([]replayEntry, size),
+		window:  window,
+	}
+}
+
+func (b *replayBuffer) at(i int) *replayEntry {
+	return &b.entries[(b.start+i)%len(b.entries)]
+}
+
+// add appends an event, replacing the oldest one if the buffer is full
+func (b *replayBuffer) add(entry replayEntry) {
+	if len(b.entries) == 0 {
+		return
+	}
+	if b.n == len(b.entries) {
+		b.start = (b.start + 1) % len(b.entries)
+		b.n -= 1
+	}
+	*b.at(b.n) = entry
+	b.n += 1
+	b.evict(entry.added)
+}
+
+// evict removes the events that are older than the window
+func (b *replayBuffer) evict(now time.Time) {
+	for b.window > 0 && b.n > 0 && now.Sub(b.at(0).added) > b.window {
+		*b.at(0) = replayEntry{} //nolint:exhaustruct // release the tx
+		b.start = (b.start + 1) % len(b.entries)
+		b.n -= 1
+	}
+}
+
+// since returns the buffered events after lastEventID (nextEventID is the id of the next event), and a gap event if
+// events after lastEventID were already evicted, or if lastEventID is unknown (then all buffered events are returned)
+func (b *replayBuffer) since(lastEventID, nextEventID uint64, now time.Time) ([]replayEntry, *GapEvent) {
+	b.evict(now)
+
+	firstID := nextEventID // id of the oldest event in the buffer
+	if b.n > 0 {
+		firstID = b.at(0).id
+	}
+
+	var gap *GapEvent
+	switch {
+	case lastEventID >= nextEventID:
+		gap = &GapEvent{Reason: GapReasonUnknown, LastEventID: lastEventID, NextEventID: firstID} //nolint:exhaustruct
+		lastEventID = 0
+	case lastEventID+1 < firstID:
+		gap = &GapEvent{Reason: GapReasonEvicted, LastEventID: lastEventID, NextEventID: firstID} //nolint:exhaustruct
+	}
+
+	entries := make([]replayEntry, 0)
+	for i := range b.n {
+		if entry := b.at(i); entry.id > lastEventID {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries, gap
+}
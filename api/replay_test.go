@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func replayIDs(entries []replayEntry) []uint64 {
+	ids := make([]uint64, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.id
+	}
+	return ids
+}
+
+func TestReplayBuffer(t *testing.T) {
+	now := time.Now()
+	b := newReplayBuffer(3, time.Minute)
+	for id := uint64(1); id <= 5; id++ {
+		b.add(replayEntry{id: id, added: now, tx: nil})
+	}
+
+	// bounded in size: 1 and 2 were evicted
+	entries, gap := b.since(2, 6, now)
+	require.Nil(t, gap)
+	require.Equal(t, []uint64{3, 4, 5}, replayIDs(entries))
+
+	entries, gap = b.since(4, 6, now)
+	require.Nil(t, gap)
+	require.Equal(t, []uint64{5}, replayIDs(entries))
+
+	entries, gap = b.since(5, 6, now)
+	require.Nil(t, gap)
+	require.Empty(t, entries)
+
+	entries, gap = b.since(1, 6, now)
+	require.Equal(t, &GapEvent{Reason: GapReasonEvicted, LastEventID: 1, NextEventID: 3}, gap) //nolint:exhaustruct
+	require.Equal(t, []uint64{3, 4, 5}, replayIDs(entries))
+
+	// newer than the last event (not sent by this server): all events
+	entries, gap = b.since(10, 6, now)
+	require.Equal(t, &GapEvent{Reason: GapReasonUnknown, LastEventID: 10, NextEventID: 3}, gap) //nolint:exhaustruct
+	require.Equal(t, []uint64{3, 4, 5}, replayIDs(entries))
+
+	// bounded in age
+	b.add(replayEntry{id: 6, added: now.Add(time.Minute), tx: nil})
+	entries, gap = b.since(5, 7, now.Add(time.Minute+time.Second))
+	require.Nil(t, gap)
+	require.Equal(t, []uint64{6}, replayIDs(entries))
+
+	entries, gap = b.since(3, 7, now.Add(3*time.Minute))
+	require.Equal(t, &GapEvent{Reason: GapReasonEvicted, LastEventID: 3, NextEventID: 7}, gap) //nolint:exhaustruct
+	require.Empty(t, entries)
+
+	// without buffer
+	b = newReplayBuffer(0, 0)
+	b.add(replayEntry{id: 1, added: now, tx: nil})
+	entries, gap = b.since(1, 2, now)
+	require.Nil(t, gap)
+	require.Empty(t, entries)
+	_, gap = b.since(0, 2, now)
+	require.Equal(t, GapReasonEvicted, gap.Reason)
+}
+
+func TestServer_handleTxSSE_resume(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false), ReplayBufferSize: 3}) //nolint:exhaustruct
+	srv := httptest.NewServer(s.srv.Handler)
+	t.Cleanup(srv.Close)
+
+	// 5 transactions without subscribers (ids base+1 to base+5, the buffer keeps base+3 to base+5)
+	base := s.lastEventID
+	eventID := func(n uint64) string { return strconv.FormatUint(base+n, 10) }
+	txRLPs := make([]string, 0)
+	for i := range 5 {
+		source := "local"
+		if i == 3 {
+			source = "other"
+		}
+		txIn, _ := newTestTxIn(t, source, 1, nil)
+		require.NoError(t, s.SendTx(context.Background(), txIn))
+		txRLP, err := common.TxToRLPString(txIn.Tx)
+		require.NoError(t, err)
+		txRLPs = append(txRLPs, txRLP)
+	}
+
+	// resume after 3: replays 4 and 5, then continues with new events
+	stream := subscribeSSE(t, srv.URL, "format=json", eventID(3))
+	for _, n := range []uint64{4, 5} {
+		lines := readSSEEvent(t, stream)
+		require.Equal(t, "id: "+eventID(n), lines[0])
+	}
+	txIn, _ := newTestTxIn(t, "local", 1, nil)
+	require.NoError(t, s.SendTx(context.Background(), txIn))
+	require.Equal(t, "id: "+eventID(6), readSSEEvent(t, stream)[0])
+
+	// resume after an evicted id: gap event first, and the filter applies to the replayed events (also in rlp format)
+	stream = subscribeSSE(t, srv.URL, "source=local", eventID(1))
+	lines := readSSEEvent(t, stream)
+	require.Equal(t, "event: gap", lines[0])
+	var gap GapEvent
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &gap))
+	require.Equal(t, GapEvent{Reason: GapReasonEvicted, LastEventID: base + 1, NextEventID: base + 4}, gap) //nolint:exhaustruct
+	require.Equal(t, []string{"id: " + eventID(5), "data: " + txRLPs[4]}, readSSEEvent(t, stream))          // 4 is from the other source
+
+	// after a restart, the ids of the previous server are older than the new ones: gap, and all buffered events
+	time.Sleep(time.Millisecond)
+	restarted := New(&HTTPServerConfig{Log: common.GetLogger(true, false), ReplayBufferSize: 3}) //nolint:exhaustruct
+	require.Greater(t, restarted.lastEventID, s.lastEventID)
+	require.NoError(t, restarted.SendTx(context.Background(), txIn))
+	entries, gap2 := restarted.replay.since(s.lastEventID, restarted.lastEventID+1, time.Now())
+	require.Equal(t, GapReasonEvicted, gap2.Reason)
+	require.Equal(t, []uint64{restarted.lastEventID}, replayIDs(entries))
+
+	// invalid Last-Event-ID
+	req := httptest.NewRequest(http.MethodGet, "/sse/transactions", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	w := httptest.NewRecorder()
+	s.handleTxSSE(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestServer_SendTx_dropped(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)})                                         //nolint:exhaustruct
+	sub := &SSESubscription{uid: "slow", txC: make(chan sseEvent, 1), format: FormatJSON, reportGaps: true} //nolint:exhaustruct
+	s.addSubscriber(sub)
+
+	for range 3 {
+		txIn, _ := newTestTxIn(t, "local", 1, nil)
+		require.NoError(t, s.SendTx(context.Background(), txIn))
+	}
+
+	w := httptest.NewRecorder()
+	sub.writeEvent(w, <-sub.txC)
+	expected := fmt.Sprintf("event: gap\ndata: {\"reason\":\"dropped\",\"missed\":2}\n\nid: %d\n", s.lastEventID-2)
+	require.True(t, strings.HasPrefix(w.Body.String(), expected), w.Body.String())
+}
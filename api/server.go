@@ -1,28 +1,38 @@
-// Package api contains the webserver for API and SSE subscription
+// Package api contains the webserver for API and SSE subscription, and the gRPC transaction stream
 package api
 
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/flashbots/go-utils/httplogger"
+	"github.com/flashbots/mempool-dumpster/api/pb"
 	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 type HTTPServerConfig struct {
-	ListenAddr string
-	Log        *zap.SugaredLogger
+	ListenAddr     string // HTTP API (SSE and websocket), disabled if empty
+	GRPCListenAddr string // gRPC StreamTransactions service, disabled if empty
+	Log            *zap.SugaredLogger
 
 	DrainDuration            time.Duration
 	GracefulShutdownDuration time.Duration
 	ReadTimeout              time.Duration
 	WriteTimeout             time.Duration
+
+	// Replay buffer of the recent transaction events, for SSE clients resuming with Last-Event-ID (0: no buffer)
+	ReplayBufferSize int
+	ReplayWindow     time.Duration // max age of the buffered events (0: no limit)
 }
 
 type Server struct {
@@ -33,21 +43,50 @@ type Server struct {
 	srv               *http.Server
 	sseConnectionMap  map[string]*SSESubscription
 	sseConnectionLock sync.RWMutex
+
+	eventLock   sync.Mutex // serializes the transaction events, so they are sent in the order of their ids
+	lastEventID uint64     // id of the last transaction event, starts at the startup time (µs) so ids aren't reused after a restart
+	replay      *replayBuffer
+
+	rpcServer          *rpc.Server // websocket JSON-RPC (eth_subscribe)
+	wsSubscriptions    map[rpc.ID]*wsSubscription
+	wsSubscriptionLock sync.RWMutex
+
+	grpcServer           *grpc.Server
+	grpcSubscriptions    map[string]*grpcSubscription
+	grpcSubscriptionLock sync.RWMutex
+
+	shutdownC    chan struct{} // closed on shutdown, ends the long-running SSE requests
+	shutdownOnce sync.Once
 }
 
 func New(cfg *HTTPServerConfig) (srv *Server) {
 	srv = &Server{
-		cfg:              cfg,
-		log:              cfg.Log,
-		srv:              nil,
-		sseConnectionMap: make(map[string]*SSESubscription),
+		cfg:               cfg,
+		log:               cfg.Log,
+		srv:               nil,
+		sseConnectionMap:  make(map[string]*SSESubscription),
+		shutdownC:         make(chan struct{}),
+		lastEventID:       uint64(time.Now().UnixMicro()), //nolint:gosec // also below 2^53, for JSON numbers in JavaScript
+		replay:            newReplayBuffer(cfg.ReplayBufferSize, cfg.ReplayWindow),
+		rpcServer:         rpc.NewServer(),
+		wsSubscriptions:   make(map[rpc.ID]*wsSubscription),
+		grpcServer:        grpc.NewServer(),
+		grpcSubscriptions: make(map[string]*grpcSubscription),
 	}
+
+	// only fails if the API has no suitable methods
+	if err := srv.rpcServer.RegisterName("eth", &pendingTxAPI{s: srv}); err != nil {
+		panic(err)
+	}
+	pb.RegisterTransactionsServer(srv.grpcServer, &txStreamServer{s: srv}) //nolint:exhaustruct
 	srv.isReady.Swap(true)
 
 	mux := chi.NewRouter()
 
 	mux.Use(srv.httpLogger)
 	mux.Get("/sse/transactions", srv.handleTxSSE)
+	mux.Handle("/ws", srv.rpcServer.WebsocketHandler([]string{"*"}))
 	mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
@@ -73,15 +112,36 @@ func (s *Server) httpLogger(next http.Handler) http.Handler {
 
 func (s *Server) RunInBackground() {
 	// api
-	go func() {
-		s.log.With("listenAddress", s.cfg.ListenAddr).Info("Starting HTTP server")
-		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			s.log.With("err", err).Error("HTTP server failed")
-		}
-	}()
+	if s.cfg.ListenAddr != "" {
+		go func() {
+			s.log.With("listenAddress", s.cfg.ListenAddr).Info("Starting HTTP server")
+			if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.With("err", err).Error("HTTP server failed")
+			}
+		}()
+	}
+
+	// grpc
+	if s.cfg.GRPCListenAddr != "" {
+		go func() {
+			s.log.With("listenAddress", s.cfg.GRPCListenAddr).Info("Starting gRPC server")
+			lis, err := net.Listen("tcp", s.cfg.GRPCListenAddr)
+			if err != nil {
+				s.log.With("err", err).Error("gRPC server failed")
+				return
+			}
+			if err := s.grpcServer.Serve(lis); err != nil {
+				s.log.With("err", err).Error("gRPC server failed")
+			}
+		}()
+	}
 }
 
 func (s *Server) Shutdown() {
+	s.shutdownOnce.Do(func() { close(s.shutdownC) })
+	s.rpcServer.Stop()          // closes the websocket connections (they are hijacked, and not closed by srv.Shutdown)
+	s.grpcServer.GracefulStop() // the streams end on shutdownC
+
 	// api
 	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.GracefulShutdownDuration)
 	defer cancel()
@@ -106,24 +166,52 @@ func (s *Server) removeSubscriber(sub *SSESubscription) {
 }
 
 func (s *Server) SendTx(ctx context.Context, tx *common.TxIn) error {
+	s.eventLock.Lock()
+	defer s.eventLock.Unlock()
+
+	// every transaction gets an id and is buffered, also without subscribers (clients can resume later)
+	s.lastEventID += 1
+	id := s.lastEventID
+	s.replay.add(replayEntry{id: id, added: time.Now(), tx: tx})
+
+	s.sendTxGRPC(tx)
+	return errors.Join(s.sendTxSSE(id, tx), s.sendTxWS(tx))
+}
+
+// sendTxSSE sends the transaction event to the SSE subscribers
+func (s *Server) sendTxSSE(id uint64, tx *common.TxIn) error {
 	s.sseConnectionLock.RLock()
 	defer s.sseConnectionLock.RUnlock()
 	if len(s.sseConnectionMap) == 0 {
 		return nil
 	}
 
-	txRLP, err := common.TxToRLPString(tx.Tx)
-	if err != nil {
-		return err
-	}
-
-	// Send tx to all subscribers (only if channel is not full)
+	// Send tx to all subscribers whose filter matches (only if channel is not full)
+	var err error
+	events := make(map[string]string, 2) // format -> event data (empty if encoding failed), encoded once per format
 	for _, sub := range s.sseConnectionMap {
+		if !sub.filter.Match(tx) {
+			continue
+		}
+		data, ok := events[sub.format]
+		if !ok {
+			var errEncode error
+			data, errEncode = encodeTx(tx, sub.format)
+			if errEncode != nil {
+				err = errors.Join(err, fmt.Errorf("encoding tx as %s: %w", sub.format, errEncode))
+			}
+			events[sub.format] = data
+		}
+		if data == "" {
+			continue
+		}
+
 		select {
-		case sub.txC <- txRLP:
+		case sub.txC <- sseEvent{id: id, data: data}:
 		default:
+			sub.missed.Inc() // reported to the client with a gap event
 		}
 	}
 
-	return nil
+	return err
 }
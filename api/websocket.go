@@ -0,0 +1,134 @@
+package api
+
+//
+// WebSocket JSON-RPC endpoint (/ws), with the pending transaction subscriptions of a node:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newPendingTransactions"]}        -> tx hashes
+//	{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newPendingTransactions", true]}  -> full transactions
+//
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flashbots/mempool-dumpster/common"
+)
+
+// wsSubscription is a newPendingTransactions subscription
+type wsSubscription struct {
+	id     rpc.ID
+	fullTx bool
+	txC    chan any // tx hash or full transaction (json.RawMessage)
+}
+
+// pendingTxAPI is the "eth" namespace of the JSON-RPC server
+type pendingTxAPI struct {
+	s *Server
+}
+
+// NewPendingTransactions subscribes to the hashes of the transactions, or to the full transactions if fullTx is true
+func (api *pendingTxAPI) NewPendingTransactions(ctx context.Context, fullTx *bool) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	sub := &wsSubscription{
+		id:     rpcSub.ID,
+		fullTx: fullTx != nil && *fullTx,
+		txC:    make(chan any, 100),
+	}
+	api.s.addWSSubscriber(sub)
+
+	go func() {
+		defer api.s.removeWSSubscriber(sub)
+		for {
+			select {
+			case tx := <-sub.txC:
+				if err := notifier.Notify(rpcSub.ID, tx); err != nil {
+					return
+				}
+			case <-rpcSub.Err(): // unsubscribed, or connection closed
+				return
+			case <-api.s.shutdownC:
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// marshalPendingTx returns the transaction like a node's newPendingTransactions subscription (with sender and gas
+// price, not yet in a block, and without blob sidecar)
+func marshalPendingTx(tx *types.Transaction) (json.RawMessage, error) {
+	txJSON, err := tx.WithoutBlobTxSidecar().MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]any)
+	if err = json.Unmarshal(txJSON, &fields); err != nil {
+		return nil, err
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, err
+	}
+	fields["from"] = from
+	fields["gasPrice"] = (*hexutil.Big)(tx.GasPrice()) // fee cap of dynamic fee transactions, like a node
+	fields["blockHash"] = nil
+	fields["blockNumber"] = nil
+	fields["transactionIndex"] = nil
+	return json.Marshal(fields)
+}
+
+func (s *Server) addWSSubscriber(sub *wsSubscription) {
+	s.wsSubscriptionLock.Lock()
+	defer s.wsSubscriptionLock.Unlock()
+	s.wsSubscriptions[sub.id] = sub
+}
+
+func (s *Server) removeWSSubscriber(sub *wsSubscription) {
+	s.wsSubscriptionLock.Lock()
+	defer s.wsSubscriptionLock.Unlock()
+	delete(s.wsSubscriptions, sub.id)
+	s.log.With("subscribers", len(s.wsSubscriptions)).Debug("removed websocket subscriber")
+}
+
+// sendTxWS sends the transaction to the websocket subscribers (only if their channel is not full)
+func (s *Server) sendTxWS(tx *common.TxIn) error {
+	s.wsSubscriptionLock.RLock()
+	defer s.wsSubscriptionLock.RUnlock()
+
+	var (
+		fullTx json.RawMessage // encoded once, for the first full subscription
+		err    error
+	)
+	for _, sub := range s.wsSubscriptions {
+		var data any = tx.Tx.Hash()
+		if sub.fullTx {
+			if fullTx == nil && err == nil {
+				fullTx, err = marshalPendingTx(tx.Tx)
+			}
+			if err != nil {
+				continue
+			}
+			data = fullTx
+		}
+
+		select {
+		case sub.txC <- data:
+		default:
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("encoding pending tx: %w", err)
+	}
+	return nil
+}
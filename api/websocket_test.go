@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_websocketSubscriptions(t *testing.T) {
+	s := New(&HTTPServerConfig{Log: common.GetLogger(true, false)}) //nolint:exhaustruct
+	srv := httptest.NewServer(s.srv.Handler)
+	defer srv.Close()
+	defer s.rpcServer.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rpcClient, err := rpc.DialContext(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws")
+	require.NoError(t, err)
+	defer rpcClient.Close()
+	client := gethclient.New(rpcClient)
+
+	hashC := make(chan ethcommon.Hash, 10)
+	hashSub, err := client.SubscribePendingTransactions(ctx, hashC)
+	require.NoError(t, err)
+	txC := make(chan *types.Transaction, 10)
+	txSub, err := client.SubscribeFullPendingTransactions(ctx, txC)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		s.wsSubscriptionLock.RLock()
+		defer s.wsSubscriptionLock.RUnlock()
+		return len(s.wsSubscriptions) == 2
+	}, time.Second, time.Millisecond)
+
+	txIn, _ := newTestTxIn(t, "local", 1, append(testSelector, make([]byte, 64)...))
+	require.NoError(t, s.SendTx(ctx, txIn))
+
+	select {
+	case hash := <-hashC:
+		require.Equal(t, txIn.Tx.Hash(), hash)
+	case <-ctx.Done():
+		t.Fatal("no tx hash received")
+	}
+	select {
+	case tx := <-txC:
+		require.Equal(t, txIn.Tx.Hash(), tx.Hash())
+		require.Equal(t, txIn.Tx.Data(), tx.Data())
+	case <-ctx.Done():
+		t.Fatal("no tx received")
+	}
+
+	// unsubscribe removes the subscription
+	hashSub.Unsubscribe()
+	txSub.Unsubscribe()
+	require.Eventually(t, func() bool {
+		s.wsSubscriptionLock.RLock()
+		defer s.wsSubscriptionLock.RUnlock()
+		return len(s.wsSubscriptions) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestMarshalPendingTx(t *testing.T) {
+	txIn, from := newTestTxIn(t, "local", 1, nil)
+	data, err := marshalPendingTx(txIn.Tx)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"from":"`+strings.ToLower(from.Hex())+`"`)
+	require.Contains(t, string(data), `"gasPrice":"0x1"`)
+	require.Contains(t, string(data), `"blockHash":null`)
+
+	var tx types.Transaction
+	require.NoError(t, tx.UnmarshalJSON(data))
+	require.Equal(t, txIn.Tx.Hash(), tx.Hash())
+}
@@ -7,8 +7,6 @@ import (
 
 	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/urfave/cli/v2"
-	"github.com/xitongsys/parquet-go-source/local"
-	"github.com/xitongsys/parquet-go/reader"
 )
 
 var (
@@ -33,6 +31,14 @@ var (
 			Name:  "cmp",
 			Usage: "compare these sources",
 		},
+		&cli.StringSliceFlag{
+			Name:  "4byte-file",
+			Usage: "additional function signature files (selector,signature or a 4byte.directory CSV export)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "address-labels",
+			Usage: "address label files (address,label)",
+		},
 	}
 )
 
@@ -78,50 +84,50 @@ func analyzeV2(cCtx *cli.Context) error {
 	// Load parquet input files
 	timeStart := time.Now()
 	log.Infow("Loading parquet input files...", "memUsed", common.GetMemUsageHuman())
-	fr, err := local.NewLocalFileReader(parquetInputFiles[0])
-	if err != nil {
-		log.Fatalw("Can't open file", "error", err)
-	}
-	pr, err := reader.NewParquetReader(fr, new(common.TxSummaryEntry), 4)
-	if err != nil {
-		log.Fatalw("Can't create parquet reader", "error", err)
-	}
-	num := int(pr.GetNumRows())
 	entries := make(map[string]*common.TxSummaryEntry)
-	var i int
-	for i := range num {
-		stus := make([]common.TxSummaryEntry, 1)
-		if err = pr.Read(&stus); err != nil {
-			log.Errorw("Read error", "error", err)
-		}
-		if i%20_000 == 0 {
-			log.Infow(common.Printer.Sprintf("- Loaded %10d / %d rows", i, num), "memUsed", common.GetMemUsageHuman())
+	for _, fn := range parquetInputFiles {
+		log.Infof("Loading %s ...", fn)
+		schemaVersion, err := common.ReadTransactionParquetFile(fn, func(tx *common.TxSummaryEntry) bool {
+			entries[tx.Hash] = tx
+			if len(entries)%20_000 == 0 {
+				log.Infow(common.Printer.Sprintf("- Loaded %10d rows", len(entries)), "memUsed", common.GetMemUsageHuman())
+			}
+			return len(entries) != maxTxs
+		})
+		if err != nil {
+			log.Fatalw("Can't read parquet file", "error", err, "file", fn)
 		}
-		entries[stus[0].Hash] = &stus[0]
-		if i+1 == maxTxs {
+		log.Infow("Loaded file", "schemaVersion", schemaVersion, "file", fn)
+		if len(entries) == maxTxs {
 			break
 		}
 	}
-	pr.ReadStop()
-	fr.Close()
-	log.Infow(common.Printer.Sprintf("- Loaded %10d / %d rows", i+1, num), "memUsed", common.GetMemUsageHuman(), "timeTaken", time.Since(timeStart).String())
+	log.Infow(common.Printer.Sprintf("- Loaded %10d rows", len(entries)), "memUsed", common.GetMemUsageHuman(), "timeTaken", time.Since(timeStart).String())
 
 	// Load input files
-	var sourcelog map[string]map[string]int64 // [hash][source] = timestampMs
+	var sourcelog, announcements map[string]map[string]int64 // [hash][source] = timestampMs
 	if len(inputSourceLogFiles) > 0 {
 		log.Info("Loading sourcelog files...")
-		sourcelog, _ = common.LoadSourcelogFiles(log, inputSourceLogFiles)
+		sourcelog, announcements, _ = common.LoadSourcelogFilesWithAnnouncements(log, inputSourceLogFiles)
 		log.Infow("Processed input sourcelog files",
 			"txTotal", common.Printer.Sprintf("%d", len(sourcelog)),
+			"txAnnounced", common.Printer.Sprintf("%d", len(announcements)),
 			"memUsed", common.GetMemUsageHuman(),
 		)
 	}
 
+	labels, err := common.LoadLabels(cCtx.StringSlice("4byte-file"), cCtx.StringSlice("address-labels"))
+	if err != nil {
+		log.Fatalw("Can't load labels", "error", err)
+	}
+
 	log.Info("Analyzing...")
 	analyzer := common.NewAnalyzer2(common.Analyzer2Opts{ //nolint:exhaustruct
-		Transactions: entries,
-		Sourelog:     sourcelog,
-		SourceComps:  sourceComps,
+		Transactions:  entries,
+		Sourelog:      sourcelog,
+		Announcements: announcements,
+		SourceComps:   sourceComps,
+		Labels:        labels,
 	})
 
 	s := analyzer.Sprint()
@@ -130,7 +136,7 @@ func analyzeV2(cCtx *cli.Context) error {
 	fmt.Println(s)
 
 	if outFile != "" {
-		err = analyzer.WriteToFile(outFile)
+		err := analyzer.WriteToFile(outFile)
 		if err != nil {
 			log.Errorw("Can't write to file", "error", err)
 		}
@@ -0,0 +1,339 @@
+package cmd_merge //nolint:stylecheck
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/flashbots/mempool-dumpster/common"
+	"go.uber.org/zap"
+)
+
+var errInvalidCheckpoint = errors.New("invalid inclusion checkpoint")
+
+// BlockIndex - tx hash -> block index of the blocks around the transactions, scanned once with BlockByNumber (instead
+// of a receipt lookup per transaction).
+//
+// The scanned blocks are appended to the checkpoint file (if any), one line per block:
+//
+//	<block_number>,<timestamp>,<tx_hash> <tx_hash> ...
+//
+// An interrupted merge loads the checkpoint and only scans the missing blocks.
+type BlockIndex struct {
+	log        *zap.SugaredLogger
+	clients    []*ethclient.Client
+	retries    int
+	scanMargin time.Duration
+	head       uint64
+
+	txs         map[ethcommon.Hash]uint64 // tx hash -> block number
+	scanned     map[uint64]uint64         // block number -> timestamp (seconds) of the scanned blocks
+	headerTimes map[uint64]uint64         // block number -> timestamp (seconds) of other looked up blocks
+
+	checkpoint  *os.File
+	checkpointW *bufio.Writer
+}
+
+func NewBlockIndex(log *zap.SugaredLogger, checkNodeURIs []string, retries int, scanMargin time.Duration, fnCheckpoint string) (*BlockIndex, error) {
+	bi := &BlockIndex{ //nolint:exhaustruct
+		log:         log,
+		retries:     retries,
+		scanMargin:  scanMargin,
+		txs:         make(map[ethcommon.Hash]uint64),
+		scanned:     make(map[uint64]uint64),
+		headerTimes: make(map[uint64]uint64),
+	}
+
+	for _, checkNodeURI := range checkNodeURIs {
+		log.Infof("- conecting block scanner to %s ...", checkNodeURI)
+		client, err := ethclient.Dial(checkNodeURI)
+		if err != nil {
+			bi.Close()
+			return nil, fmt.Errorf("ethclient.Dial %s: %w", checkNodeURI, err)
+		}
+		bi.clients = append(bi.clients, client)
+	}
+
+	err := withRetries(retries, func() (err error) {
+		bi.head, err = bi.clients[0].BlockNumber(context.Background())
+		return err
+	})
+	if err != nil {
+		bi.Close()
+		return nil, fmt.Errorf("BlockNumber: %w", err)
+	}
+
+	if fnCheckpoint != "" {
+		err = bi.loadCheckpoint(fnCheckpoint)
+		if err != nil {
+			bi.Close()
+			return nil, fmt.Errorf("loadCheckpoint: %w", err)
+		}
+		log.Infow("Loaded inclusion checkpoint", "file", fnCheckpoint, "blocks", printer.Sprintf("%d", len(bi.scanned)), "txs", printer.Sprintf("%d", len(bi.txs)))
+	}
+	return bi, nil
+}
+
+func (bi *BlockIndex) Close() error {
+	var err error
+	if bi.checkpoint != nil {
+		err = errors.Join(bi.checkpointW.Flush(), bi.checkpoint.Close())
+		bi.checkpoint = nil
+	}
+	for _, client := range bi.clients {
+		client.Close()
+	}
+	bi.clients = nil
+	return err
+}
+
+// loadCheckpoint adds the blocks of the checkpoint file, and opens it for appending. An incomplete last line (from
+// an interrupted write) is removed.
+func (bi *BlockIndex) loadCheckpoint(fn string) error {
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+
+	var offset int64 // end of the last complete line
+	r := bufio.NewReader(f)
+	for lineNum := 1; ; lineNum++ {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			if line != "" {
+				bi.log.Warnw("Dropping incomplete last line of the inclusion checkpoint", "file", fn, "line", lineNum)
+			}
+			break
+		} else if err != nil {
+			_ = f.Close()
+			return err
+		}
+
+		if err = bi.addCheckpointLine(strings.TrimSuffix(line, "\n")); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("%s line %d: %w", fn, lineNum, err)
+		}
+		offset += int64(len(line))
+	}
+
+	if err = f.Truncate(offset); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return err
+	}
+	bi.checkpoint = f
+	bi.checkpointW = bufio.NewWriter(f)
+	return nil
+}
+
+func (bi *BlockIndex) addCheckpointLine(line string) error {
+	parts := strings.Split(line, ",")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: expected 3 fields, got %d", errInvalidCheckpoint, len(parts))
+	}
+	number, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	timestamp, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	for _, hash := range strings.Fields(parts[2]) {
+		bi.txs[ethcommon.HexToHash(hash)] = number
+	}
+	bi.scanned[number] = timestamp
+	return nil
+}
+
+func (bi *BlockIndex) addBlock(block *types.Block) error {
+	number := block.NumberU64()
+	hashes := make([]string, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		bi.txs[tx.Hash()] = number
+		hashes[i] = tx.Hash().Hex()
+	}
+	bi.scanned[number] = block.Time()
+
+	if bi.checkpoint == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(bi.checkpointW, "%d,%d,%s\n", number, block.Time(), strings.Join(hashes, " "))
+	return err
+}
+
+// blockTime returns the timestamp (seconds) of a block
+func (bi *BlockIndex) blockTime(number uint64) (uint64, error) {
+	if t, ok := bi.scanned[number]; ok {
+		return t, nil
+	}
+	if t, ok := bi.headerTimes[number]; ok {
+		return t, nil
+	}
+
+	var header *types.Header
+	err := withRetries(bi.retries, func() (err error) {
+		header, err = bi.clients[0].HeaderByNumber(context.Background(), new(big.Int).SetUint64(number))
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("HeaderByNumber %d: %w", number, err)
+	}
+	bi.headerTimes[number] = header.Time
+	return header.Time, nil
+}
+
+// blockAtTime returns the first block with a timestamp at or after t (seconds), or the head block
+func (bi *BlockIndex) blockAtTime(t uint64) (uint64, error) {
+	var err error
+	n := sort.Search(int(bi.head)+1, func(i int) bool { //nolint:gosec
+		if err != nil {
+			return true
+		}
+		var blockTime uint64
+		blockTime, err = bi.blockTime(uint64(i)) //nolint:gosec
+		return blockTime >= t
+	})
+	return min(uint64(n), bi.head), err //nolint:gosec
+}
+
+// scan adds the missing blocks of the range to the index, and returns the number of blocks that couldn't be loaded
+func (bi *BlockIndex) scan(from, to uint64) (cntFailed int, err error) {
+	missing := make([]uint64, 0)
+	for number := from; number <= to; number++ {
+		if _, ok := bi.scanned[number]; !ok {
+			missing = append(missing, number)
+		}
+	}
+	bi.log.Infow("Scanning blocks", "from", from, "to", to, "missing", printer.Sprintf("%d", len(missing)))
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	type scanResult struct {
+		number uint64
+		block  *types.Block
+		err    error
+	}
+	numberC := make(chan uint64)
+	resultC := make(chan scanResult, 100)
+
+	// kick off workers
+	for _, client := range bi.clients {
+		for range numRPCWorkers {
+			go func() {
+				for number := range numberC {
+					var block *types.Block
+					err := withRetries(bi.retries, func() (err error) {
+						block, err = client.BlockByNumber(context.Background(), new(big.Int).SetUint64(number))
+						return err
+					})
+					resultC <- scanResult{number: number, block: block, err: err}
+				}
+			}()
+		}
+	}
+
+	go func() {
+		for _, number := range missing {
+			numberC <- number
+		}
+		close(numberC) // stops the workers
+	}()
+
+	// add the blocks (receiving all results, even if writing the checkpoint fails)
+	for i := range missing {
+		res := <-resultC
+		if res.err != nil {
+			bi.log.Errorw("BlockByNumber failed", "block", res.number, "error", res.err)
+			cntFailed += 1
+		} else if errAdd := bi.addBlock(res.block); errAdd != nil && err == nil {
+			err = fmt.Errorf("writing checkpoint: %w", errAdd)
+		}
+
+		if (i+1)%1000 == 0 {
+			bi.log.Infow(printer.Sprintf("- block scan progress %7d / %d", i+1, len(missing)),
+				"failed", cntFailed,
+				"indexedTxs", printer.Sprintf("%d", len(bi.txs)),
+				"memUsed", common.GetMemUsageHuman(),
+			)
+		}
+	}
+
+	if bi.checkpoint != nil && err == nil {
+		err = bi.checkpointW.Flush()
+	}
+	return cntFailed, err
+}
+
+// updateInclusionStatus scans the blocks around the transactions, and sets their inclusion status. Transactions that
+// aren't in the index are unresolved if some blocks couldn't be scanned.
+func (bi *BlockIndex) updateInclusionStatus(txs map[string]*common.TxSummaryEntry) (cntUnresolved int, err error) {
+	inclusionCheckStart := time.Now().UTC()
+
+	minTimestamp, maxTimestamp := int64(-1), int64(0)
+	for _, tx := range txs {
+		if minTimestamp == -1 || tx.Timestamp < minTimestamp {
+			minTimestamp = tx.Timestamp
+		}
+		maxTimestamp = max(maxTimestamp, tx.Timestamp)
+	}
+	timeFrom := max(minTimestamp/1000-int64(bi.scanMargin.Seconds()), 0)
+	timeTo := maxTimestamp/1000 + int64(bi.scanMargin.Seconds())
+
+	from, err := bi.blockAtTime(uint64(timeFrom)) //nolint:gosec
+	if err != nil {
+		return 0, err
+	}
+	to, err := bi.blockAtTime(uint64(timeTo)) //nolint:gosec
+	if err != nil {
+		return 0, err
+	}
+
+	cntFailedBlocks, err := bi.scan(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	cntIncluded := 0
+	for _, tx := range txs {
+		number, ok := bi.txs[ethcommon.HexToHash(tx.Hash)]
+		if !ok {
+			if cntFailedBlocks > 0 {
+				cntUnresolved += 1
+			}
+			continue
+		}
+		tx.IncludedAtBlockHeight = int64(number)                     //nolint:gosec
+		tx.IncludedBlockTimestamp = int64(bi.scanned[number] * 1000) //nolint:gosec
+		tx.InclusionDelayMs = tx.IncludedBlockTimestamp - tx.Timestamp
+		cntIncluded += 1
+	}
+
+	bi.log.Infow("Inclusion check done",
+		"blockFrom", from,
+		"blockTo", to,
+		"failedBlocks", cntFailedBlocks,
+		"memUsed", common.GetMemUsageHuman(),
+		"duration", common.FmtDuration(time.Since(inclusionCheckStart)),
+		"txTotal", printer.Sprintf("%d", len(txs)),
+		"txIncluded", printer.Sprintf("%d", cntIncluded),
+		"txNotIncluded", printer.Sprintf("%d", len(txs)-cntIncluded),
+		"txUnresolved", printer.Sprintf("%d", cntUnresolved),
+	)
+	return cntUnresolved, nil
+}
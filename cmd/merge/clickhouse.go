@@ -73,7 +73,10 @@ func (ch *Clickhouse) connect() error {
 func (ch *Clickhouse) loadTransactions(timeStart, timeEnd time.Time) (txs map[string]*common.TxSummaryEntry, err error) {
 	ctx := context.Background()
 	rows, err := ch.conn.Query(ctx, `SELECT
-		min(received_at), hash, chain_id, tx_type, from, to, value, nonce, gas, gas_price, gas_tip_cap, gas_fee_cap, data_size, data_4bytes, any(raw_tx)
+		min(received_at), hash, chain_id, tx_type, from, to, value, nonce, gas, gas_price, gas_tip_cap, gas_fee_cap, data_size, data_4bytes, any(raw_tx),
+		any(authorization_count), any(authorizations), any(invalid_authorization_count),
+		any(access_list_address_count), any(access_list_storage_key_count), any(data_zero_bytes), any(data_nonzero_bytes),
+		any(intrinsic_gas), any(is_contract_creation), any(contract_address)
 	FROM transactions WHERE received_at >= ? AND received_at < ?
 	GROUP BY (hash, chain_id, tx_type, from, to, value, nonce, gas, gas_price, gas_tip_cap, gas_fee_cap, data_size, data_4bytes)
 	SETTINGS max_threads = 8,
@@ -86,7 +89,10 @@ func (ch *Clickhouse) loadTransactions(timeStart, timeEnd time.Time) (txs map[st
 	txs = make(map[string]*common.TxSummaryEntry)
 	for rows.Next() {
 		entry := common.TxSummaryEntry{}
-		if err := rows.Scan(&entry.Timestamp, &entry.Hash, &entry.ChainID, &entry.TxType, &entry.From, &entry.To, &entry.Value, &entry.Nonce, &entry.Gas, &entry.GasPrice, &entry.GasTipCap, &entry.GasFeeCap, &entry.DataSize, &entry.Data4Bytes, &entry.RawTx); err != nil {
+		if err := rows.Scan(&entry.Timestamp, &entry.Hash, &entry.ChainID, &entry.TxType, &entry.From, &entry.To, &entry.Value, &entry.Nonce, &entry.Gas, &entry.GasPrice, &entry.GasTipCap, &entry.GasFeeCap, &entry.DataSize, &entry.Data4Bytes, &entry.RawTx,
+			&entry.AuthorizationCount, &entry.Authorizations, &entry.InvalidAuthorizationCount,
+			&entry.AccessListAddressCount, &entry.AccessListStorageKeyCount, &entry.DataZeroBytes, &entry.DataNonZeroBytes,
+			&entry.IntrinsicGas, &entry.IsContractCreation, &entry.ContractAddress); err != nil {
 			return nil, err
 		}
 		txs[entry.Hash] = &entry
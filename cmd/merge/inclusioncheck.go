@@ -2,6 +2,7 @@ package cmd_merge //nolint:stylecheck
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -12,6 +13,120 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	inclusionCheckReceipts = "receipts" // one receipt lookup per transaction
+	inclusionCheckBlocks   = "blocks"   // scan the blocks around the transactions once (see BlockIndex)
+
+	// inclusionRetryBackoff is the delay before the first retry of a failed lookup, doubled for every further retry
+	inclusionRetryBackoff = 500 * time.Millisecond
+)
+
+var inclusionCheckModes = []string{inclusionCheckReceipts, inclusionCheckBlocks}
+
+// withRetries calls fn until it succeeds, at most retries+1 times
+func withRetries(retries int, fn func() error) (err error) {
+	backoff := inclusionRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// InclusionChecker - sets the inclusion status of transactions, and counts the ones that couldn't be checked. The
+// node connections (and the block cache) are reused for all updates.
+type InclusionChecker struct {
+	log        *zap.SugaredLogger
+	blockIndex *BlockIndex // only in blocks mode
+
+	// receipts mode
+	workers    []*TxUpdateWorker
+	txC        chan *common.TxSummaryEntry
+	respC      chan error
+	blockCache *BlockCache
+
+	cntUnresolved int
+}
+
+type InclusionCheckerOpts struct {
+	CheckNodeURIs  []string
+	Mode           string
+	Retries        int
+	ScanMargin     time.Duration // blocks mode
+	CheckpointFile string        // blocks mode (optional)
+}
+
+func NewInclusionChecker(log *zap.SugaredLogger, opts InclusionCheckerOpts) (*InclusionChecker, error) {
+	c := &InclusionChecker{ //nolint:exhaustruct
+		log: log,
+	}
+	if opts.Mode == inclusionCheckBlocks {
+		var err error
+		c.blockIndex, err = NewBlockIndex(log, opts.CheckNodeURIs, opts.Retries, opts.ScanMargin, opts.CheckpointFile)
+		if err != nil {
+			return nil, fmt.Errorf("NewBlockIndex: %w", err)
+		}
+		return c, nil
+	}
+
+	// kick off geth workers
+	c.txC = make(chan *common.TxSummaryEntry)
+	c.respC = make(chan error, 100)
+	c.blockCache = NewBlockCache()
+	for _, checkNodeURI := range opts.CheckNodeURIs {
+		for range numRPCWorkers {
+			w := NewTxUpdateWorker(log, checkNodeURI, c.txC, c.respC, c.blockCache, opts.Retries)
+			if err := w.dial(); err != nil {
+				_ = c.Close()
+				return nil, fmt.Errorf("ethclient.Dial %s: %w", checkNodeURI, err)
+			}
+			c.workers = append(c.workers, w)
+		}
+	}
+	for _, w := range c.workers {
+		go w.start()
+	}
+	return c, nil
+}
+
+func (c *InclusionChecker) Close() error {
+	if c.blockIndex != nil {
+		return c.blockIndex.Close()
+	}
+	if c.txC != nil {
+		close(c.txC) // stops the workers
+		c.txC = nil
+	}
+	for _, w := range c.workers {
+		w.ethClient.Close()
+	}
+	c.workers = nil
+	return nil
+}
+
+// Update sets the inclusion status of the transactions
+func (c *InclusionChecker) Update(txs map[string]*common.TxSummaryEntry) error {
+	var (
+		cntUnresolved int
+		err           error
+	)
+	if c.blockIndex != nil {
+		cntUnresolved, err = c.blockIndex.updateInclusionStatus(txs)
+	} else {
+		cntUnresolved, err = updateInclusionStatus(c.log, c.txC, c.respC, c.blockCache, txs)
+	}
+	c.cntUnresolved += cntUnresolved
+	return err
+}
+
+// CntUnresolved returns the number of transactions whose inclusion status couldn't be checked (after retries)
+func (c *InclusionChecker) CntUnresolved() int {
+	return c.cntUnresolved
+}
+
 // BlockCache - reuse already known blocks and avoid unnecessary lookups for transaction inclusion
 type BlockCache struct {
 	blocks      map[string]bool
@@ -57,31 +172,32 @@ type TxUpdateWorker struct {
 	txC          chan *common.TxSummaryEntry
 	respC        chan error
 	blockCache   *BlockCache
+	retries      int
 }
 
-func NewTxUpdateWorker(log *zap.SugaredLogger, checkNodeURI string, txC chan *common.TxSummaryEntry, respC chan error, blockCache *BlockCache) (p *TxUpdateWorker) {
+func NewTxUpdateWorker(log *zap.SugaredLogger, checkNodeURI string, txC chan *common.TxSummaryEntry, respC chan error, blockCache *BlockCache, retries int) (p *TxUpdateWorker) {
 	return &TxUpdateWorker{ //nolint:exhaustruct
 		log:          log,
 		checkNodeURI: checkNodeURI,
 		txC:          txC,
 		respC:        respC,
 		blockCache:   blockCache,
+		retries:      retries,
 	}
 }
 
-func (p *TxUpdateWorker) start() {
-	var err error
-
+func (p *TxUpdateWorker) dial() (err error) {
 	p.log.Infof("- conecting worker to %s ...", p.checkNodeURI)
 	p.ethClient, err = ethclient.Dial(p.checkNodeURI)
-	if err != nil {
-		p.log.Fatal("ethclient.Dial", "error", err)
-		return
-	}
+	return err
+}
 
+// start processes transactions until txC is closed
+func (p *TxUpdateWorker) start() {
 	for tx := range p.txC {
-		err = p.updateTx(tx)
-		p.respC <- err
+		p.respC <- withRetries(p.retries, func() error {
+			return p.updateTx(tx)
+		})
 	}
 }
 
@@ -118,35 +234,35 @@ func (p *TxUpdateWorker) updateTx(tx *common.TxSummaryEntry) error {
 	return nil
 }
 
-// updateInclusionStatus - load and set inclusion status for all transactions
-func updateInclusionStatus(log *zap.SugaredLogger, checkNodeURIs []string, txs map[string]*common.TxSummaryEntry) (err error) {
+// updateInclusionStatus - load and set inclusion status for all transactions with the workers of txC, returns the
+// number of transactions whose lookup failed
+func updateInclusionStatus(log *zap.SugaredLogger, txC chan *common.TxSummaryEntry, respC chan error, blockCache *BlockCache, txs map[string]*common.TxSummaryEntry) (cntUnresolved int, err error) {
 	inclusionCheckStart := time.Now().UTC()
-	txC := make(chan *common.TxSummaryEntry)
-	respC := make(chan error, 100)
-	blockCache := NewBlockCache()
-
-	// kick off geth workers
-	for _, checkNodeURI := range checkNodeURIs {
-		for range numRPCWorkers {
-			w := NewTxUpdateWorker(log, checkNodeURI, txC, respC, blockCache)
-			go w.start()
-		}
+	cntTxs := len(txs)
+	if txLimit > 0 {
+		cntTxs = min(cntTxs, txLimit)
 	}
 
 	// send tx to worker
 	go func() {
 		log.Info("Loading inclusion status - sending to workers...")
+		i := 0
 		for _, entry := range txs {
+			if i == cntTxs {
+				break
+			}
 			txC <- entry
+			i += 1
 		}
 	}()
 
-	// wait for results
+	// wait for results (all of them, the workers are reused for the next update)
 	log.Info("Loading inclusion status - waiting for results...")
-	for i := range len(txs) {
+	for i := range cntTxs {
 		err := <-respC
 		if err != nil {
 			log.Errorw("updateInclusionStatus", "error", err)
+			cntUnresolved += 1
 		}
 
 		if (i+1)%10000 == 0 {
@@ -157,10 +273,6 @@ func updateInclusionStatus(log *zap.SugaredLogger, checkNodeURIs []string, txs m
 				"cachedBlocks", printer.Sprintf("%d", len(blockCache.blocks)),
 			)
 		}
-
-		if txLimit > 0 && i == txLimit {
-			break
-		}
 	}
 
 	// Run some stats
@@ -185,7 +297,8 @@ func updateInclusionStatus(log *zap.SugaredLogger, checkNodeURIs []string, txs m
 		"txTotal", printer.Sprintf("%d", cnt),
 		"txIncluded", printer.Sprintf("%d", cntIncluded),
 		"txNotIncluded", printer.Sprintf("%d", cntNotIncluded),
+		"txUnresolved", printer.Sprintf("%d", cntUnresolved),
 	)
 
-	return nil
+	return cntUnresolved, nil
 }
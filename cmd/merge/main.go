@@ -2,6 +2,8 @@
 package cmd_merge //nolint:stylecheck
 
 import (
+	"time"
+
 	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
@@ -46,15 +48,70 @@ var (
 			Name:  "check-node",
 			Usage: "eth nodes for checking tx inclusion status",
 		},
+		&cli.StringFlag{
+			Name:  "inclusion-check",
+			Value: inclusionCheckReceipts,
+			Usage: "how to check the inclusion status: 'receipts' (a receipt lookup per transaction) or 'blocks' (scan the blocks around the transactions once)",
+		},
+		&cli.StringFlag{
+			Name:  "inclusion-checkpoint",
+			Usage: "blocks inclusion check: file to record the scanned blocks, an interrupted merge resumes from it (must be from the same chain)",
+		},
+		&cli.IntFlag{
+			Name:  "inclusion-retries",
+			Value: 3,
+			Usage: "retries of failed inclusion lookups (receipts or blocks)",
+		},
+		&cli.DurationFlag{
+			Name:  "inclusion-scan-margin",
+			Value: time.Hour,
+			Usage: "blocks inclusion check: also scan the blocks this long before the first and after the last transaction",
+		},
+		&cli.IntFlag{
+			Name:  "max-unresolved-txs",
+			Value: -1,
+			Usage: "fail the merge if the inclusion status of more transactions couldn't be checked (opt-in, default -1: no limit)",
+		},
 		&cli.BoolFlag{
 			Name:  "write-tx-csv",
 			Value: false,
 			Usage: "write a CSV with all received transactions (timestamp_ms,hash,raw_tx)",
 		},
+		&cli.BoolFlag{
+			Name:  "write-blob-sidecars",
+			Usage: "write a CSV with the sidecars of blob transactions (one row per blob: timestamp_ms,hash,blob_index,versioned_hash,commitment,proof,blob)",
+		},
+		&cli.BoolFlag{
+			Name:  "write-replacements",
+			Usage: "write the replacements (same sender and nonce, new hash) to replacements.csv and replacements.parquet",
+		},
 		&cli.BoolFlag{
 			Name:  "write-summary",
 			Usage: "run analyzer and write summary",
 		},
+		&cli.IntFlag{
+			Name:  "memory-budget-mb",
+			Usage: "streaming merge: spill the input to temporary files and process it in partitions that fit into this memory budget (0: load everything into memory)",
+		},
+		&cli.StringFlag{
+			Name:  "tmp-dir",
+			Usage: "directory for the temporary files of the streaming merge (default: system temp dir)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "4byte-file",
+			Value: &cli.StringSlice{},
+			Usage: "additional function signature files (selector,signature or a 4byte.directory CSV export), used on top of the bundled 4byte dump",
+		},
+		&cli.StringSliceFlag{
+			Name:  "address-labels",
+			Value: &cli.StringSlice{},
+			Usage: "address label files (address,label) to label the receivers of transactions",
+		},
+		&cli.IntFlag{
+			Name:  "schema-version",
+			Value: common.ParquetSchemaV1,
+			Usage: "parquet output schema version (1: numbers as strings, 2: numeric types and binary rawTx)",
+		},
 
 		&cli.StringFlag{
 			Name:     "clickhouse-dsn",
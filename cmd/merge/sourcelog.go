@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 
 	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/urfave/cli/v2"
@@ -43,16 +44,17 @@ func mergeSourcelog(cCtx *cli.Context) error {
 	}
 
 	// Load input files
-	sourcelog, cntProcessedRecords := common.LoadSourcelogFiles(log, inputFiles)
+	sourcelog, announcements, cntProcessedRecords := common.LoadSourcelogFilesWithAnnouncements(log, inputFiles)
 	log.Infow("Processed all input files",
 		"txTotal", printer.Sprintf("%d", len(sourcelog)),
+		"txAnnounced", printer.Sprintf("%d", len(announcements)),
 		"records", printer.Sprintf("%d", cntProcessedRecords),
 		"memUsed", common.GetMemUsageHuman(),
 	)
 
 	// Write output files
 	log.Infof("Writing sourcelog CSV file %s ...", fnCSVSourcelog)
-	err = writeSourcelogCSV(fnCSVSourcelog, sourcelog)
+	err = writeSourcelogCSV(fnCSVSourcelog, sourcelog, announcements)
 	if err != nil {
 		return fmt.Errorf("writeSourcelogCSV: %w", err)
 	}
@@ -61,7 +63,9 @@ func mergeSourcelog(cCtx *cli.Context) error {
 	return nil
 }
 
-func writeSourcelogCSV(fn string, sourcelog map[string]map[string]int64) error {
+// writeSourcelogCSV writes the merged sourcelog. The announced_ms column is only added if any input had announcement
+// timestamps, so that the output stays identical to the 3-column format otherwise.
+func writeSourcelogCSV(fn string, sourcelog, announcements map[string]map[string]int64) error {
 	f, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
 		return err
@@ -69,7 +73,12 @@ func writeSourcelogCSV(fn string, sourcelog map[string]map[string]int64) error {
 	defer f.Close()
 
 	// Write header
-	_, err = f.WriteString("timestamp_ms,hash,source\n")
+	withAnnouncements := len(announcements) > 0
+	header := "timestamp_ms,hash,source\n"
+	if withAnnouncements {
+		header = "timestamp_ms,hash,source,announced_ms\n"
+	}
+	_, err = f.WriteString(header)
 	if err != nil {
 		return err
 	}
@@ -96,7 +105,15 @@ func writeSourcelogCSV(fn string, sourcelog map[string]map[string]int64) error {
 	for _, ts := range timestamps {
 		for hash, sources := range cache[ts] {
 			for _, source := range sources {
-				_, err = f.WriteString(fmt.Sprintf("%d,%s,%s\n", ts, hash, source))
+				if withAnnouncements {
+					announced := ""
+					if announcedTs, ok := announcements[hash][source]; ok {
+						announced = strconv.FormatInt(announcedTs, 10)
+					}
+					_, err = f.WriteString(fmt.Sprintf("%d,%s,%s,%s\n", ts, hash, source, announced))
+				} else {
+					_, err = f.WriteString(fmt.Sprintf("%d,%s,%s\n", ts, hash, source))
+				}
 				if err != nil {
 					return err
 				}
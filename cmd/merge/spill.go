@@ -0,0 +1,484 @@
+package cmd_merge //nolint:stylecheck
+
+//
+// Streaming merge (--memory-budget-mb): instead of loading all transactions and the whole sourcelog into memory,
+//
+// 1. the input transactions and sourcelogs are partitioned by tx hash into temporary files,
+// 2. each partition is loaded, deduplicated and completed (labels, sources, inclusion status) like in the in-memory
+//    merge, sorted, and written to a sorted run,
+// 3. the output files are written by merging the sorted runs.
+//
+// A partition holds all entries of its transactions, so the output is the same as the one of the in-memory merge.
+// With --write-summary, the announcement delays are added to the analyzer per partition (while its sourcelog is
+// loaded), and the other stats from the sorted output stream.
+//
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/csv"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/flashbots/mempool-dumpster/common"
+)
+
+const (
+	// spillMemoryFactor is the estimated memory use of the in-memory merge, per byte of uncompressed input
+	spillMemoryFactor = 4
+
+	// spillCompressionRatio is the estimated compression ratio of compressed input files
+	spillCompressionRatio = 5
+
+	// maxSpillPartitions limits the number of partitions (each has up to 3 open files while partitioning)
+	maxSpillPartitions = 256
+)
+
+type spillMerge struct {
+	dir          string
+	budgetBytes  int64
+	nPartitions  int
+	partitions   []*spillPartition
+	runs         []string // sorted runs, gob encoded transactions
+	cntTxs       int
+	prevKnownTxs map[string]bool
+	analyzer     *common.Analyzer2 // optional, gets the announcement delays of the partitions
+}
+
+// spillPartition holds the input entries of the transactions whose hash falls into the partition
+type spillPartition struct {
+	fnTxs       string // transaction CSV lines (timestamp_ms,hash,raw_tx)
+	fnParquet   string // transactions of parquet input files, gob encoded
+	fnSourcelog string // sourcelog CSV rows
+
+	fTxs, fParquet, fSourcelog *os.File
+	wTxs, wParquet             *bufio.Writer
+	encParquet                 *gob.Encoder
+	wSourcelog                 *csv.Writer
+}
+
+func newSpillMerge(tmpDir string, memoryBudgetMB int) (*spillMerge, error) {
+	dir, err := os.MkdirTemp(tmpDir, "mempool-dumpster-merge-")
+	if err != nil {
+		return nil, err
+	}
+	return &spillMerge{ //nolint:exhaustruct
+		dir:         dir,
+		budgetBytes: int64(memoryBudgetMB) * 1024 * 1024,
+	}, nil
+}
+
+// Close removes the temporary files
+func (m *spillMerge) Close() {
+	for _, p := range m.partitions {
+		_ = p.close()
+	}
+	if err := os.RemoveAll(m.dir); err != nil {
+		log.Errorw("failed to remove temporary directory", "error", err, "dir", m.dir)
+	}
+}
+
+// Load partitions the input files, and completes each partition into a sorted run
+func (m *spillMerge) Load(inputFiles, sourcelogFiles, txBlacklistFiles []string, inclusion *InclusionChecker, labels *common.Labels) (err error) {
+	var csvFiles, parquetFiles []string
+	for _, fn := range inputFiles {
+		if strings.HasSuffix(fn, ".parquet") {
+			common.MustBeParquetFile(log, fn)
+			parquetFiles = append(parquetFiles, fn)
+		} else {
+			common.MustBeCSVFile(log, fn)
+			csvFiles = append(csvFiles, fn)
+		}
+	}
+	for _, fn := range sourcelogFiles {
+		common.MustBeCSVFile(log, fn)
+	}
+
+	inputBytes, err := estimateInputBytes(append(slices.Clone(inputFiles), sourcelogFiles...))
+	if err != nil {
+		return err
+	}
+	m.nPartitions = int(min(max(inputBytes*spillMemoryFactor/m.budgetBytes+1, 1), maxSpillPartitions))
+	if inputBytes*spillMemoryFactor/int64(m.nPartitions) > m.budgetBytes {
+		log.Warnw("memory budget too small, partitions will exceed it", "partitions", m.nPartitions)
+	}
+	log.Infow("Streaming merge", "inputBytes", common.HumanBytes(uint64(inputBytes)), "partitions", m.nPartitions, "tmpDir", m.dir) //nolint:gosec
+
+	m.prevKnownTxs, err = common.LoadTxHashesFromMetadataCSVFiles(log, txBlacklistFiles)
+	if err != nil {
+		return fmt.Errorf("LoadTxHashesFromMetadataCSVFiles: %w", err)
+	}
+
+	// 1. Partition the input
+	m.partitions = make([]*spillPartition, m.nPartitions)
+	for i := range m.partitions {
+		m.partitions[i] = &spillPartition{ //nolint:exhaustruct
+			fnTxs:       filepath.Join(m.dir, fmt.Sprintf("part-%04d-txs.csv", i)),
+			fnParquet:   filepath.Join(m.dir, fmt.Sprintf("part-%04d-parquet.gob", i)),
+			fnSourcelog: filepath.Join(m.dir, fmt.Sprintf("part-%04d-sourcelog.csv", i)),
+		}
+	}
+	for _, fn := range csvFiles {
+		log.Infof("Partitioning %s ...", fn)
+		if err = common.ForEachCSVReader(fn, m.partitionTxCSV); err != nil {
+			return fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+	for _, fn := range parquetFiles {
+		log.Infof("Partitioning %s ...", fn)
+		var errPartition error
+		_, err = common.ReadTransactionParquetFile(fn, m.partitionParquetTx(&errPartition))
+		if err = errors.Join(err, errPartition); err != nil {
+			return fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+	for _, fn := range sourcelogFiles {
+		log.Infof("Partitioning %s ...", fn)
+		if err = common.ForEachCSVReader(fn, m.partitionSourcelog); err != nil {
+			return fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+	for _, p := range m.partitions {
+		if err = p.close(); err != nil {
+			return err
+		}
+	}
+
+	// 2. Complete and sort each partition
+	for i, p := range m.partitions {
+		log.Infow(printer.Sprintf("Processing partition %d / %d", i+1, m.nPartitions), "memUsed", common.GetMemUsageHuman())
+		fnRun := filepath.Join(m.dir, fmt.Sprintf("run-%04d.gob", i))
+		cnt, err := p.process(fnRun, m.prevKnownTxs, inclusion, labels, m.analyzer)
+		if err != nil {
+			return fmt.Errorf("partition %d: %w", i, err)
+		}
+		if cnt > 0 {
+			m.runs = append(m.runs, fnRun)
+			m.cntTxs += cnt
+		}
+	}
+	log.Infow("Partitions processed", "txs", printer.Sprintf("%d", m.cntTxs), "runs", len(m.runs), "memUsed", common.GetMemUsageHuman())
+	return nil
+}
+
+// estimateInputBytes returns the estimated uncompressed size of the files
+func estimateInputBytes(files []string) (total int64, err error) {
+	for _, fn := range files {
+		stat, err := os.Stat(fn)
+		if err != nil {
+			return 0, err
+		}
+		size := stat.Size()
+		if !strings.HasSuffix(fn, ".csv") {
+			size *= spillCompressionRatio
+		}
+		total += size
+	}
+	return total, nil
+}
+
+func (m *spillMerge) partitionOf(txHash string) *spillPartition {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.ToLower(txHash)))
+	return m.partitions[h.Sum32()%uint32(m.nPartitions)] //nolint:gosec
+}
+
+// partitionTxCSV copies the lines of a transaction CSV file to the partitions (invalid lines are skipped when loading)
+func (m *spillMerge) partitionTxCSV(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for {
+		l, err := reader.ReadString('\n')
+		if len(l) == 0 && err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		txHash := ""
+		if items := strings.SplitN(l, ",", 3); len(items) == 3 {
+			txHash = items[1]
+		}
+		w, err := m.partitionOf(txHash).txsWriter()
+		if err != nil {
+			return err
+		}
+		if _, err = w.WriteString(strings.TrimSuffix(l, "\n") + "\n"); err != nil {
+			return err
+		}
+	}
+}
+
+// partitionParquetTx returns a ReadTransactionParquetFile callback that writes the transactions to the partitions
+func (m *spillMerge) partitionParquetTx(errOut *error) func(tx *common.TxSummaryEntry) bool {
+	return func(tx *common.TxSummaryEntry) bool {
+		enc, err := m.partitionOf(tx.Hash).parquetEncoder()
+		if err == nil {
+			err = enc.Encode(tx)
+		}
+		*errOut = err
+		return err == nil
+	}
+}
+
+func (m *spillMerge) partitionSourcelog(r io.Reader) error {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	for {
+		row, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		txHash := ""
+		if len(row) > 1 {
+			txHash = row[1]
+		}
+		w, err := m.partitionOf(txHash).sourcelogWriter()
+		if err != nil {
+			return err
+		}
+		if err = w.Write(row); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *spillPartition) txsWriter() (*bufio.Writer, error) {
+	if p.wTxs == nil {
+		f, err := os.Create(p.fnTxs)
+		if err != nil {
+			return nil, err
+		}
+		p.fTxs, p.wTxs = f, bufio.NewWriter(f)
+	}
+	return p.wTxs, nil
+}
+
+func (p *spillPartition) parquetEncoder() (*gob.Encoder, error) {
+	if p.encParquet == nil {
+		f, err := os.Create(p.fnParquet)
+		if err != nil {
+			return nil, err
+		}
+		p.fParquet, p.wParquet = f, bufio.NewWriter(f)
+		p.encParquet = gob.NewEncoder(p.wParquet)
+	}
+	return p.encParquet, nil
+}
+
+func (p *spillPartition) sourcelogWriter() (*csv.Writer, error) {
+	if p.wSourcelog == nil {
+		f, err := os.Create(p.fnSourcelog)
+		if err != nil {
+			return nil, err
+		}
+		p.fSourcelog, p.wSourcelog = f, csv.NewWriter(f)
+	}
+	return p.wSourcelog, nil
+}
+
+// close flushes and closes the open partition files
+func (p *spillPartition) close() error {
+	var errs []error
+	if p.fTxs != nil {
+		errs = append(errs, p.wTxs.Flush(), p.fTxs.Close())
+		p.fTxs = nil
+	}
+	if p.fParquet != nil {
+		errs = append(errs, p.wParquet.Flush(), p.fParquet.Close())
+		p.fParquet = nil
+	}
+	if p.fSourcelog != nil {
+		p.wSourcelog.Flush()
+		errs = append(errs, p.wSourcelog.Error(), p.fSourcelog.Close())
+		p.fSourcelog = nil
+	}
+	return errors.Join(errs...)
+}
+
+// process loads the partition like the in-memory merge, and writes its transactions sorted to fnRun
+func (p *spillPartition) process(fnRun string, prevKnownTxs map[string]bool, inclusion *InclusionChecker, labels *common.Labels, analyzer *common.Analyzer2) (int, error) {
+	txs := make(map[string]*common.TxSummaryEntry)
+	err := readFileIfExists(p.fnTxs, func(r io.Reader) error {
+		return common.ReadTransactionCSV(log, r, prevKnownTxs, txs)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	parquetTxs := make(map[string]*common.TxSummaryEntry)
+	err = readFileIfExists(p.fnParquet, func(r io.Reader) error {
+		dec := gob.NewDecoder(bufio.NewReader(r))
+		for {
+			tx := new(common.TxSummaryEntry)
+			if err := dec.Decode(tx); errors.Is(err, io.EOF) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			parquetTxs[strings.ToLower(tx.Hash)] = normalizeSpilledTx(tx)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	addParquetTxs(txs, parquetTxs, prevKnownTxs)
+
+	sourcelog := make(map[string]map[string]int64)
+	announcements := make(map[string]map[string]int64)
+	if _, err = os.Stat(p.fnSourcelog); err == nil {
+		sourcelog, announcements, _ = common.LoadSourcelogFilesWithAnnouncements(log, []string{p.fnSourcelog})
+	}
+
+	err = completeTransactions(txs, sourcelog, inclusion, labels)
+	if err != nil {
+		return 0, err
+	}
+	if analyzer != nil {
+		for hash, tx := range txs {
+			analyzer.AddAnnouncements(tx, announcements[hash], sourcelog[hash])
+		}
+	}
+
+	sorted := make([]*common.TxSummaryEntry, 0, len(txs))
+	for _, tx := range txs {
+		sorted = append(sorted, tx)
+	}
+	slices.SortFunc(sorted, compareTxs)
+	if len(sorted) > 0 {
+		if err = writeSpillRun(fnRun, sorted); err != nil {
+			return 0, err
+		}
+	}
+
+	err = errors.Join(removeIfExists(p.fnTxs), removeIfExists(p.fnParquet), removeIfExists(p.fnSourcelog))
+	return len(sorted), err
+}
+
+func readFileIfExists(fn string, cb func(r io.Reader) error) error {
+	f, err := os.Open(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return cb(f)
+}
+
+func removeIfExists(fn string) error {
+	err := os.Remove(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// normalizeSpilledTx restores the empty lists of a gob decoded transaction (gob doesn't distinguish empty and nil)
+func normalizeSpilledTx(tx *common.TxSummaryEntry) *common.TxSummaryEntry {
+	if tx.Sources == nil {
+		tx.Sources = []string{}
+	}
+	if tx.BlobVersionedHashes == nil {
+		tx.BlobVersionedHashes = []string{}
+	}
+	if tx.Authorizations == nil {
+		tx.Authorizations = []string{}
+	}
+	return tx
+}
+
+func writeSpillRun(fn string, txs []*common.TxSummaryEntry) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	for _, tx := range txs {
+		if err = enc.Encode(tx); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	return errors.Join(err, f.Close())
+}
+
+// SortedTransactions returns all transactions sorted by compareTxs, by merging the sorted runs. Each iteration reads
+// the runs again.
+func (m *spillMerge) SortedTransactions() iter.Seq[*common.TxSummaryEntry] {
+	return func(yield func(*common.TxSummaryEntry) bool) {
+		h := &spillRunHeap{}
+		for _, fn := range m.runs {
+			f, err := os.Open(fn)
+			if err != nil {
+				log.Fatalw("os.Open", "error", err, "file", fn)
+			}
+			defer f.Close()
+
+			run := &spillRunReader{dec: gob.NewDecoder(bufio.NewReader(f)), fn: fn} //nolint:exhaustruct
+			if run.next() {
+				heap.Push(h, run)
+			}
+		}
+
+		for h.Len() > 0 {
+			run := (*h)[0]
+			if !yield(run.tx) {
+				return
+			}
+			if run.next() {
+				heap.Fix(h, 0)
+			} else {
+				heap.Pop(h)
+			}
+		}
+	}
+}
+
+// spillRunReader reads the transactions of a sorted run
+type spillRunReader struct {
+	dec *gob.Decoder
+	fn  string
+	tx  *common.TxSummaryEntry
+}
+
+// next reads the next transaction, and returns false at the end of the run
+func (r *spillRunReader) next() bool {
+	tx := new(common.TxSummaryEntry)
+	err := r.dec.Decode(tx)
+	if errors.Is(err, io.EOF) {
+		return false
+	} else if err != nil {
+		log.Fatalw("failed to read sorted run", "error", err, "file", r.fn)
+	}
+	r.tx = normalizeSpilledTx(tx)
+	return true
+}
+
+// spillRunHeap is a min-heap of runs by their current transaction
+type spillRunHeap []*spillRunReader
+
+func (h spillRunHeap) Len() int           { return len(h) }
+func (h spillRunHeap) Less(i, j int) bool { return compareTxs(h[i].tx, h[j].tx) < 0 }
+func (h spillRunHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *spillRunHeap) Push(x any)        { *h = append(*h, x.(*spillRunReader)) } //nolint:forcetypeassert
+func (h *spillRunHeap) Pop() any {
+	old := *h
+	run := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return run
+}
@@ -1,24 +1,27 @@
 package cmd_merge //nolint:stylecheck
 
 import (
+	"cmp"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"iter"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/urfave/cli/v2"
-	"github.com/xitongsys/parquet-go-source/local"
-	"github.com/xitongsys/parquet-go/parquet"
-	"github.com/xitongsys/parquet-go/writer"
 )
 
 // Number of RPC workers for checking transaction inclusion status
 var txLimit = 0 // max transactions to process
 
-// mergeTransactions merges multiple transaction CSV files into transactions.parquet + metadata.csv files
+// mergeTransactions merges multiple transaction CSV (or parquet) files into transactions.parquet + metadata.csv files
 func mergeTransactions(cCtx *cli.Context) error {
 	var err error
 	timeStart := time.Now().UTC()
@@ -30,6 +33,18 @@ func mergeTransactions(cCtx *cli.Context) error {
 	writeTxCSV := cCtx.Bool("write-tx-csv")
 	checkNodeURIs := cCtx.StringSlice("check-node")
 	writeSummary := cCtx.Bool("write-summary")
+	writeBlobSidecars := cCtx.Bool("write-blob-sidecars")
+	writeReplacements := cCtx.Bool("write-replacements")
+	schemaVersion := cCtx.Int("schema-version")
+	signatureFiles := cCtx.StringSlice("4byte-file")
+	addressLabelFiles := cCtx.StringSlice("address-labels")
+	memoryBudgetMB := cCtx.Int("memory-budget-mb")
+	tmpDir := cCtx.String("tmp-dir")
+	inclusionCheckMode := cCtx.String("inclusion-check")
+	inclusionCheckpoint := cCtx.String("inclusion-checkpoint")
+	inclusionRetries := cCtx.Int("inclusion-retries")
+	inclusionScanMargin := cCtx.Duration("inclusion-scan-margin")
+	maxUnresolvedTxs := cCtx.Int("max-unresolved-txs")
 	inputFiles := cCtx.Args().Slice()
 
 	clickhouseDSN := cCtx.String("clickhouse-dsn")
@@ -45,12 +60,27 @@ func mergeTransactions(cCtx *cli.Context) error {
 	if len(clickhouseDSN) > 0 && (len(dateFrom) == 0 || len(dateTo) == 0) {
 		log.Fatal("clickhouseDSN needs date-from and date-to arguments")
 	}
+	if !slices.Contains(common.ParquetSchemaVersions, schemaVersion) {
+		log.Fatalf("invalid schema-version %d (supported: %v)", schemaVersion, common.ParquetSchemaVersions)
+	}
+	if !slices.Contains(inclusionCheckModes, inclusionCheckMode) {
+		log.Fatalf("invalid inclusion-check %s (supported: %v)", inclusionCheckMode, inclusionCheckModes)
+	}
+	if inclusionCheckpoint != "" && inclusionCheckMode != inclusionCheckBlocks {
+		log.Fatal("inclusion-checkpoint needs --inclusion-check blocks")
+	}
+	if memoryBudgetMB > 0 && len(inputFiles) == 0 {
+		log.Fatal("memory-budget-mb needs input files (the Clickhouse data source is always loaded into memory)")
+	}
 
 	log.Infow("Merge transactions",
 		"version", common.Version,
 		"outDir", outDir,
 		"fnPrefix", fnPrefix,
+		"schemaVersion", schemaVersion,
 		"checkNodes", checkNodeURIs,
+		"inclusionCheck", inclusionCheckMode,
+		"memoryBudgetMB", memoryBudgetMB,
 	)
 
 	err = os.MkdirAll(outDir, os.ModePerm)
@@ -63,11 +93,20 @@ func mergeTransactions(cCtx *cli.Context) error {
 	fnParquetTxs := filepath.Join(outDir, "transactions.parquet")
 	fnCSVTxs := filepath.Join(outDir, "transactions.csv")
 	fnSummary := filepath.Join(outDir, "summary.txt")
+	fnCSVBlobs := filepath.Join(outDir, "blob_sidecars.csv")
+	fnCSVReplacements := filepath.Join(outDir, "replacements.csv")
+	fnParquetReplacements := filepath.Join(outDir, "replacements.parquet")
 	if fnPrefix != "" {
 		fnParquetTxs = filepath.Join(outDir, fmt.Sprintf("%s.parquet", fnPrefix))
 		fnCSVMeta = filepath.Join(outDir, fmt.Sprintf("%s.csv", fnPrefix))
 		fnCSVTxs = filepath.Join(outDir, fmt.Sprintf("%s_transactions.csv", fnPrefix))
 		fnSummary = filepath.Join(outDir, fmt.Sprintf("%s_summary.txt", fnPrefix))
+		fnCSVBlobs = filepath.Join(outDir, fmt.Sprintf("%s_blob_sidecars.csv", fnPrefix))
+		fnCSVReplacements = filepath.Join(outDir, fmt.Sprintf("%s_replacements.csv", fnPrefix))
+		fnParquetReplacements = filepath.Join(outDir, fmt.Sprintf("%s_replacements.parquet", fnPrefix))
+	}
+	if !writeBlobSidecars {
+		fnCSVBlobs = ""
 	}
 	common.MustNotExist(log, fnParquetTxs)
 	common.MustNotExist(log, fnCSVMeta)
@@ -75,37 +114,170 @@ func mergeTransactions(cCtx *cli.Context) error {
 	if writeSummary {
 		common.MustNotExist(log, fnSummary)
 	}
+	if writeBlobSidecars {
+		common.MustNotExist(log, fnCSVBlobs)
+	}
+	if writeReplacements {
+		common.MustNotExist(log, fnCSVReplacements)
+		common.MustNotExist(log, fnParquetReplacements)
+	}
 
 	log.Infof("Output Parquet file: %s", fnParquetTxs)
 	log.Infof("Output metadata CSV file: %s", fnCSVMeta)
 	if writeTxCSV {
 		log.Infof("Output transactions CSV file: %s", fnCSVTxs)
 	}
+	if writeBlobSidecars {
+		log.Infof("Output blob sidecars CSV file: %s", fnCSVBlobs)
+	}
+	if writeReplacements {
+		log.Infof("Output replacements files: %s, %s", fnCSVReplacements, fnParquetReplacements)
+	}
+
+	var (
+		txs           map[string]*common.TxSummaryEntry
+		sourcelog     map[string]map[string]int64
+		announcements map[string]map[string]int64
+	)
+
+	labels, err := common.LoadLabels(signatureFiles, addressLabelFiles)
+	if err != nil {
+		return fmt.Errorf("common.LoadLabels: %w", err)
+	}
+
+	var inclusion *InclusionChecker // nil without check nodes
+	if len(checkNodeURIs) > 0 {
+		inclusion, err = NewInclusionChecker(log, InclusionCheckerOpts{
+			CheckNodeURIs:  checkNodeURIs,
+			Mode:           inclusionCheckMode,
+			Retries:        inclusionRetries,
+			ScanMargin:     inclusionScanMargin,
+			CheckpointFile: inclusionCheckpoint,
+		})
+		if err != nil {
+			return fmt.Errorf("NewInclusionChecker: %w", err)
+		}
+		defer func() { _ = inclusion.Close() }()
+	}
 
 	var (
-		txs       map[string]*common.TxSummaryEntry
-		sourcelog map[string]map[string]int64
+		sortedTxs iter.Seq[*common.TxSummaryEntry] // all transactions, sorted by timestamp and hash
+		cntTxs    int
+		analyzer  *common.Analyzer2 // streaming analyzer (only with memory-budget-mb)
 	)
 
-	if len(inputFiles) > 0 {
-		// Load input files
-		txs, sourcelog, err = loadInputFiles(inputFiles, sourcelogFiles, txBlacklistFiles)
+	if memoryBudgetMB > 0 {
+		// Streaming merge: the input is partitioned by tx hash, and the partitions are completed one by one
+		spill, err := newSpillMerge(tmpDir, memoryBudgetMB)
 		if err != nil {
-			return fmt.Errorf("loadInputFiles: %w", err)
+			return fmt.Errorf("newSpillMerge: %w", err)
+		}
+		defer spill.Close()
+		if writeSummary {
+			spill.analyzer = common.NewStreamingAnalyzer2(common.Analyzer2Opts{ //nolint:exhaustruct
+				SourceComps: common.DefaultSourceComparisons,
+				Labels:      labels,
+			})
 		}
+
+		err = spill.Load(inputFiles, sourcelogFiles, txBlacklistFiles, inclusion, labels)
+		if err != nil {
+			return fmt.Errorf("spill.Load: %w", err)
+		}
+		sortedTxs, cntTxs, analyzer = spill.SortedTransactions(), spill.cntTxs, spill.analyzer
 	} else {
-		dateFrom, err := common.ParseDateString(dateFrom)
+		if len(inputFiles) > 0 {
+			// Load input files
+			txs, sourcelog, announcements, err = loadInputFiles(inputFiles, sourcelogFiles, txBlacklistFiles)
+			if err != nil {
+				return fmt.Errorf("loadInputFiles: %w", err)
+			}
+		} else {
+			dateFrom, err := common.ParseDateString(dateFrom)
+			if err != nil {
+				return fmt.Errorf("ParseDateString dateFrom: %w", err)
+			}
+			dateTo, err := common.ParseDateString(dateTo)
+			if err != nil {
+				return fmt.Errorf("ParseDateString dateTo: %w", err)
+			}
+			log.Infow("Using Clickhouse data source", "dateFrom", dateFrom.String(), "dateTo", dateTo.String())
+
+			txs = loadDataFromClickhouse(clickhouseDSN, dateFrom, dateTo)
+			sourcelog = make(map[string]map[string]int64) // empty sourcelog
+		}
+
+		err = completeTransactions(txs, sourcelog, inclusion, labels)
 		if err != nil {
-			return fmt.Errorf("ParseDateString dateFrom: %w", err)
+			return err
+		}
+
+		//
+		// Convert map to slice sorted by summary.timestamp
+		//
+		log.Info("Sorting transactions by timestamp...")
+		txsSlice := make([]*common.TxSummaryEntry, 0, len(txs))
+		for _, v := range txs {
+			txsSlice = append(txsSlice, v)
 		}
-		dateTo, err := common.ParseDateString(dateTo)
+		slices.SortFunc(txsSlice, compareTxs)
+		log.Infow("Transactions sorted...", "txs", printer.Sprintf("%d", len(txsSlice)), "memUsed", common.GetMemUsageHuman())
+		sortedTxs, cntTxs = slices.Values(txsSlice), len(txsSlice)
+	}
+
+	// Don't write output files with too many transactions of unknown inclusion status (they'd look not included)
+	if inclusion != nil {
+		cntUnresolved := inclusion.CntUnresolved()
+		if maxUnresolvedTxs >= 0 && cntUnresolved > maxUnresolvedTxs {
+			return fmt.Errorf("inclusion status of %d transactions couldn't be checked (max-unresolved-txs: %d)", cntUnresolved, maxUnresolvedTxs)
+		}
+		log.Infow("Inclusion check finished", "txUnresolved", printer.Sprintf("%d", cntUnresolved))
+	}
+
+	//
+	// Write output files
+	//
+	cntTxWritten := writeFiles(sortedTxs, cntTxs, fnParquetTxs, schemaVersion, fnCSVTxs, fnCSVMeta, fnCSVBlobs)
+	if writeReplacements {
+		err = writeReplacementFiles(sortedTxs, fnCSVReplacements, fnParquetReplacements)
 		if err != nil {
-			return fmt.Errorf("ParseDateString dateTo: %w", err)
+			return fmt.Errorf("writeReplacementFiles: %w", err)
+		}
+	}
+	log.Infow("Finished merging!", "cntTx", printer.Sprintf("%d", cntTxWritten), "duration", time.Since(timeStart).String())
+
+	// Analyze and write summary
+	if writeSummary {
+		log.Info("Analyzing...")
+		if analyzer != nil {
+			for tx := range sortedTxs {
+				analyzer.Add(tx)
+			}
+			analyzer.Finish()
+		} else {
+			analyzer = common.NewAnalyzer2(common.Analyzer2Opts{ //nolint:exhaustruct
+				Transactions:  txs,
+				Sourelog:      sourcelog,
+				Announcements: announcements,
+				SourceComps:   common.DefaultSourceComparisons,
+				Labels:        labels,
+			})
 		}
-		log.Infow("Using Clickhouse data source", "dateFrom", dateFrom.String(), "dateTo", dateTo.String())
 
-		txs = loadDataFromClickhouse(clickhouseDSN, dateFrom, dateTo)
-		sourcelog = make(map[string]map[string]int64) // empty sourcelog
+		err = analyzer.WriteToFile(fnSummary)
+		if err != nil {
+			return fmt.Errorf("analyzer.WriteToFile: %w", err)
+		}
+		log.Infof("Wrote summary file %s", fnSummary)
+	}
+	return nil
+}
+
+// completeTransactions adds the labels, the sources (from the sourcelog) and the inclusion status to the transactions
+func completeTransactions(txs map[string]*common.TxSummaryEntry, sourcelog map[string]map[string]int64, inclusion *InclusionChecker, labels *common.Labels) error {
+	// Label method selectors and receivers
+	for _, tx := range txs {
+		labels.Apply(tx)
 	}
 
 	// Attach sources (sorted by timestamp) to transactions
@@ -115,14 +287,21 @@ func mergeTransactions(cCtx *cli.Context) error {
 		timestamp int64
 	}
 	for hash, tx := range txs {
+		if len(sourcelog[hash]) == 0 && len(tx.Sources) > 0 {
+			continue // keep the sources of parquet input files
+		}
+
 		txSources := make([]srcWithTS, 0, len(sourcelog[hash]))
 		for source := range sourcelog[hash] {
 			txSources = append(txSources, srcWithTS{source: source, timestamp: sourcelog[hash][source]})
 		}
 
-		// sort by timestamp
+		// sort by timestamp (and source, for a stable order)
 		sort.Slice(txSources, func(i, j int) bool {
-			return txSources[i].timestamp < txSources[j].timestamp
+			if txSources[i].timestamp != txSources[j].timestamp {
+				return txSources[i].timestamp < txSources[j].timestamp
+			}
+			return txSources[i].source < txSources[j].source
 		})
 
 		// add to tx
@@ -138,62 +317,34 @@ func mergeTransactions(cCtx *cli.Context) error {
 	//
 	// Update txs with inclusion status
 	//
-	if len(checkNodeURIs) == 0 {
+	if inclusion == nil {
 		log.Info("No check-node specified, skipping inclusion status update")
-	} else {
-		err = updateInclusionStatus(log, checkNodeURIs, txs)
-		if err != nil {
-			return fmt.Errorf("updateInclusionStatus: %w", err)
-		}
-	}
-
-	//
-	// Convert map to slice sorted by summary.timestamp
-	//
-	log.Info("Sorting transactions by timestamp...")
-	txsSlice := make([]*common.TxSummaryEntry, 0, len(txs))
-	for _, v := range txs {
-		txsSlice = append(txsSlice, v)
-	}
-	sort.Slice(txsSlice, func(i, j int) bool {
-		return txsSlice[i].Timestamp < txsSlice[j].Timestamp
-	})
-	log.Infow("Transactions sorted...", "txs", printer.Sprintf("%d", len(txsSlice)), "memUsed", common.GetMemUsageHuman())
-
-	//
-	// Write output files
-	//
-	cntTxWritten := writeFiles(txsSlice, fnParquetTxs, fnCSVTxs, fnCSVMeta)
-	log.Infow("Finished merging!", "cntTx", printer.Sprintf("%d", cntTxWritten), "duration", time.Since(timeStart).String())
-
-	// Analyze and write summary
-	if writeSummary {
-		log.Info("Analyzing...")
-		analyzer := common.NewAnalyzer2(common.Analyzer2Opts{ //nolint:exhaustruct
-			Transactions: txs,
-			Sourelog:     sourcelog,
-			SourceComps:  common.DefaultSourceComparisons,
-		})
-
-		err = analyzer.WriteToFile(fnSummary)
+	} else if len(txs) > 0 {
+		err := inclusion.Update(txs)
 		if err != nil {
-			return fmt.Errorf("analyzer.WriteToFile: %w", err)
+			return fmt.Errorf("inclusion.Update: %w", err)
 		}
-		log.Infof("Wrote summary file %s", fnSummary)
 	}
 	return nil
 }
 
-func writeFiles(txs []*common.TxSummaryEntry, fnParquetTxs, fnCSVTxs, fnCSVMeta string) (cntTxWritten int) { //nolint:gocognit
+// compareTxs orders transactions by timestamp, and by hash for the same timestamp (the output order of the merger)
+func compareTxs(a, b *common.TxSummaryEntry) int {
+	return cmp.Or(cmp.Compare(a.Timestamp, b.Timestamp), cmp.Compare(a.Hash, b.Hash))
+}
+
+func writeFiles(txs iter.Seq[*common.TxSummaryEntry], cntTxTotal int, fnParquetTxs string, schemaVersion int, fnCSVTxs, fnCSVMeta, fnCSVBlobs string) (cntTxWritten int) { //nolint:gocognit
 	writeTxCSV := fnCSVTxs != ""
+	writeBlobsCSV := fnCSVBlobs != ""
 
 	fCSVMeta, err := os.OpenFile(fnCSVMeta, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
 		log.Fatalw("os.Create", "error", err, "file", fnCSVMeta)
 	}
 
-	csvHeader := strings.Join(common.TxSummaryEntryCSVHeader, ",")
-	_, err = fmt.Fprintf(fCSVMeta, "%s\n", csvHeader)
+	// method names contain commas, so the metadata rows are quoted where needed
+	csvMeta := csv.NewWriter(fCSVMeta)
+	err = csvMeta.Write(common.TxSummaryEntryCSVHeader)
 	if err != nil {
 		log.Fatalw("fCSVMeta.WriteCSVHeader", "error", err, "file", fnCSVMeta)
 	}
@@ -211,32 +362,32 @@ func writeFiles(txs []*common.TxSummaryEntry, fnParquetTxs, fnCSVTxs, fnCSVMeta
 		}
 	}
 
-	// Setup parquet writer
-	fw, err := local.NewLocalFileWriter(fnParquetTxs)
-	if err != nil {
-		log.Fatalw("parquet.NewLocalFileWriter", "error", err, "file", fnParquetTxs)
+	var fCSVBlobs *os.File
+	if writeBlobsCSV {
+		fCSVBlobs, err = os.OpenFile(fnCSVBlobs, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatalw("os.Create", "error", err, "file", fnCSVBlobs)
+		}
+
+		_, err = fmt.Fprintf(fCSVBlobs, "%s\n", strings.Join(common.BlobSidecarCSVHeader, ","))
+		if err != nil {
+			log.Fatalw("fCSVBlobs.WriteCSVHeader", "error", err, "file", fnCSVBlobs)
+		}
 	}
 
-	pw, err := writer.NewParquetWriter(fw, new(common.TxSummaryEntry), 4)
+	// Setup parquet writer
+	pw, err := common.NewTxParquetWriter(fnParquetTxs, schemaVersion)
 	if err != nil {
-		log.Fatalw("parquet.NewParquetWriter", "error", err, "file", fnParquetTxs)
+		log.Fatalw("common.NewTxParquetWriter", "error", err, "file", fnParquetTxs)
 	}
 
-	// Parquet config: https://parquet.apache.org/docs/file-format/configurations/
-	pw.RowGroupSize = 128 * 1024 * 1024 // 128M
-	pw.PageSize = 1024 * 1024           // 1M
-
-	// Parquet compression: must be gzip for compatibility with both ClickHouse and S3 Select
-	pw.CompressionType = parquet.CompressionCodec_GZIP
-
 	//
 	// Write output files
 	//
 	log.Info("Writing output files...")
 
-	cntTxTotal := len(txs)
 	cntTxAlreadyIncluded := 0
-	for _, tx := range txs {
+	for tx := range txs {
 		// Skip transactions that were included before they were received
 		if tx.WasIncludedBeforeReceived() {
 			cntTxAlreadyIncluded += 1
@@ -256,10 +407,14 @@ func writeFiles(txs []*common.TxSummaryEntry, fnParquetTxs, fnCSVTxs, fnCSVMeta
 			}
 		}
 
+		// Write to blob sidecars CSV (the raw tx includes the sidecar)
+		if writeBlobsCSV && tx.BlobCount > 0 {
+			writeBlobSidecars(fCSVBlobs, tx)
+		}
+
 		// Write to summary CSV
-		csvRow := strings.Join(tx.ToCSVRow(), ",")
-		if _, err = fmt.Fprintf(fCSVMeta, "%s\n", csvRow); err != nil {
-			log.Errorw("fCSV.WriteString", "error", err)
+		if err = csvMeta.Write(tx.ToCSVRow()); err != nil {
+			log.Errorw("csvMeta.Write", "error", err)
 		}
 
 		cntTxWritten += 1
@@ -284,41 +439,140 @@ func writeFiles(txs []*common.TxSummaryEntry, fnParquetTxs, fnCSVTxs, fnCSVMeta
 			log.Fatalw("os.Close", "error", err, "file", fnCSVTxs)
 		}
 	}
+	if writeBlobsCSV {
+		err = fCSVBlobs.Close()
+		if err != nil {
+			log.Fatalw("os.Close", "error", err, "file", fnCSVBlobs)
+		}
+	}
+	csvMeta.Flush()
+	if err = csvMeta.Error(); err != nil {
+		log.Fatalw("csvMeta.Flush", "error", err, "file", fnCSVMeta)
+	}
 	err = fCSVMeta.Close()
 	if err != nil {
 		log.Fatalw("os.Close", "error", err, "file", fnCSVMeta)
 	}
 
-	err = pw.WriteStop()
+	err = pw.Close()
 	if err != nil {
-		log.Fatalw("pw.WriteStop", "error", err, "file", fnParquetTxs)
+		log.Fatalw("pw.Close", "error", err, "file", fnParquetTxs)
 	}
 
-	fw.Close()
-
 	return cntTxWritten
 }
 
-func loadInputFiles(inputFiles, sourcelogFiles, txBlacklistFiles []string) (txs map[string]*common.TxSummaryEntry, sourcelog map[string]map[string]int64, err error) {
-	// Check input files
-	for _, fn := range append(inputFiles, sourcelogFiles...) {
+// writeReplacementFiles writes the replacements between the transactions (same sender and nonce) to CSV and parquet
+func writeReplacementFiles(txs iter.Seq[*common.TxSummaryEntry], fnCSV, fnParquet string) error {
+	seenTxs := func(yield func(*common.TxSummaryEntry) bool) {
+		for tx := range txs {
+			if !tx.WasIncludedBeforeReceived() && !yield(tx) {
+				return
+			}
+		}
+	}
+	replacements := common.FindSortedReplacements(seenTxs)
+
+	f, err := os.OpenFile(fnCSV, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write(common.ReplacementCSVHeader)
+	for _, r := range replacements {
+		_ = w.Write(r.ToCSVRow())
+	}
+	w.Flush()
+	if err = errors.Join(w.Error(), f.Close()); err != nil {
+		return err
+	}
+
+	err = common.WriteReplacementsParquet(fnParquet, replacements)
+	if err != nil {
+		return err
+	}
+	log.Infow("Wrote replacements", "cnt", printer.Sprintf("%d", len(replacements)))
+	return nil
+}
+
+func writeBlobSidecars(f *os.File, tx *common.TxSummaryEntry) {
+	rawTx, err := tx.RawTxBytes()
+	if err != nil {
+		log.Errorw("tx.RawTxBytes", "error", err, "tx", tx.Hash)
+		return
+	}
+	ethTx := new(types.Transaction)
+	if err = ethTx.UnmarshalBinary(rawTx); err != nil {
+		log.Errorw("tx.UnmarshalBinary", "error", err, "tx", tx.Hash)
+		return
+	}
+
+	rows := common.BlobSidecarCSVRows(tx.Timestamp, ethTx)
+	if len(rows) == 0 {
+		log.Warnw("blob transaction without sidecar", "tx", tx.Hash)
+	}
+	for _, row := range rows {
+		if _, err = fmt.Fprintf(f, "%s\n", strings.Join(row, ",")); err != nil {
+			log.Errorw("fCSVBlobs.WriteString", "error", err)
+			return
+		}
+	}
+}
+
+func loadInputFiles(inputFiles, sourcelogFiles, txBlacklistFiles []string) (txs map[string]*common.TxSummaryEntry, sourcelog, announcements map[string]map[string]int64, err error) {
+	// Check input files (transactions can also be merged parquet files, of any schema version)
+	var csvFiles, parquetFiles []string
+	for _, fn := range inputFiles {
+		if strings.HasSuffix(fn, ".parquet") {
+			common.MustBeParquetFile(log, fn)
+			parquetFiles = append(parquetFiles, fn)
+		} else {
+			common.MustBeCSVFile(log, fn)
+			csvFiles = append(csvFiles, fn)
+		}
+	}
+	for _, fn := range sourcelogFiles {
 		common.MustBeCSVFile(log, fn)
 	}
 
 	// Load sourcelog files
 	log.Infow("Loading sourcelog files...", "files", sourcelogFiles)
-	sourcelog, _ = common.LoadSourcelogFiles(log, sourcelogFiles)
+	sourcelog, announcements, _ = common.LoadSourcelogFilesWithAnnouncements(log, sourcelogFiles)
 	log.Infow("Loaded sourcelog files", "memUsed", common.GetMemUsageHuman())
 
 	//
 	// Load input files
 	//
-	txs, err = common.LoadTransactionCSVFiles(log, inputFiles, txBlacklistFiles)
+	txs, err = common.LoadTransactionCSVFiles(log, csvFiles, txBlacklistFiles)
 	if err != nil {
-		return nil, nil, fmt.Errorf("LoadTransactionCSVFiles: %w", err)
+		return nil, nil, nil, fmt.Errorf("LoadTransactionCSVFiles: %w", err)
 	}
 
-	return txs, sourcelog, nil
+	if len(parquetFiles) > 0 {
+		prevKnownTxs, err := common.LoadTxHashesFromMetadataCSVFiles(log, txBlacklistFiles)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("LoadTxHashesFromMetadataCSVFiles: %w", err)
+		}
+		parquetTxs, err := common.LoadTransactionParquetFiles(log, parquetFiles)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("LoadTransactionParquetFiles: %w", err)
+		}
+		addParquetTxs(txs, parquetTxs, prevKnownTxs)
+	}
+
+	return txs, sourcelog, announcements, nil
+}
+
+// addParquetTxs adds the transactions of parquet input files (except blacklisted ones), the earliest one wins
+func addParquetTxs(txs, parquetTxs map[string]*common.TxSummaryEntry, prevKnownTxs map[string]bool) {
+	for hash, tx := range parquetTxs {
+		if prevKnownTxs[hash] {
+			continue
+		}
+		if prev, ok := txs[hash]; !ok || tx.Timestamp < prev.Timestamp {
+			txs[hash] = tx
+		}
+	}
 }
 
 func loadDataFromClickhouse(clickhouseDSN string, timeStart, timeEnd time.Time) (txs map[string]*common.TxSummaryEntry) {
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// announcementTTL is how long a hash announcement is remembered while waiting for the full transaction
+	announcementTTL = 5 * time.Minute
+
+	// announcementCacheMaxSize is the max number of remembered announcements (the oldest are evicted first)
+	announcementCacheMaxSize = 50_000
+)
+
+type announcement struct {
+	t   time.Time
+	seq uint64 // insertion number, identifies the ring slot of the announcement
+}
+
+// announcementCache remembers when a source first announced a tx hash, until the full transaction is received. It's
+// bounded: announcements are kept in insertion order in a ring, and the oldest are evicted when it's full or when
+// they are older than announcementTTL.
+type announcementCache struct {
+	lock      sync.Mutex
+	announced map[string]announcement // lowercase hash -> first announcement
+	ring      []string                // hashes in insertion order (slot seq % len), may contain popped hashes
+	seq       uint64                  // number of announcements added
+	oldest    uint64                  // seq of the oldest slot in the ring
+}
+
+func newAnnouncementCache() *announcementCache {
+	return &announcementCache{ //nolint:exhaustruct
+		announced: make(map[string]announcement),
+		ring:      make([]string, announcementCacheMaxSize),
+	}
+}
+
+// Add records the announcement time of a hash, unless it was already announced before
+func (c *announcementCache) Add(hash string, t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.announced[hash]; ok {
+		return
+	}
+
+	// evict the oldest slot if the ring is full, and the expired announcements
+	if c.seq-c.oldest == uint64(len(c.ring)) {
+		c.evictOldest()
+	}
+	for c.oldest < c.seq {
+		a, ok := c.announced[c.ring[c.oldest%uint64(len(c.ring))]]
+		if ok && a.seq == c.oldest && t.Sub(a.t) <= announcementTTL {
+			break
+		}
+		c.evictOldest()
+	}
+
+	c.announced[hash] = announcement{t: t, seq: c.seq}
+	c.ring[c.seq%uint64(len(c.ring))] = hash
+	c.seq += 1
+}
+
+// evictOldest removes the oldest slot of the ring, and its announcement if it wasn't popped (or re-added) since
+func (c *announcementCache) evictOldest() {
+	slot := c.oldest % uint64(len(c.ring))
+	if a, ok := c.announced[c.ring[slot]]; ok && a.seq == c.oldest {
+		delete(c.announced, c.ring[slot])
+	}
+	c.ring[slot] = ""
+	c.oldest += 1
+}
+
+// Pop returns the announcement time of a hash (zero if not announced) and forgets it
+func (c *announcementCache) Pop(hash string) time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	a, ok := c.announced[hash]
+	if !ok {
+		return time.Time{}
+	}
+	delete(c.announced, hash)
+	return a.t
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnouncementCache(t *testing.T) {
+	c := newAnnouncementCache()
+	t0 := time.Unix(1693785600, 0)
+
+	c.Add("0x01", t0)
+	c.Add("0x01", t0.Add(time.Second)) // later announcements are ignored
+	require.Equal(t, t0, c.Pop("0x01"))
+
+	// popped entries are forgotten
+	require.True(t, c.Pop("0x01").IsZero())
+	require.True(t, c.Pop("0x02").IsZero())
+}
+
+func TestAnnouncementCache_bounded(t *testing.T) {
+	c := newAnnouncementCache()
+	t0 := time.Unix(1693785600, 0)
+
+	// the oldest announcements are evicted when the cache is full
+	for i := range announcementCacheMaxSize + 10 {
+		c.Add(fmt.Sprintf("0x%x", i), t0)
+	}
+	require.Len(t, c.announced, announcementCacheMaxSize)
+	require.True(t, c.Pop("0x0").IsZero())
+	require.Equal(t, t0, c.Pop(fmt.Sprintf("0x%x", announcementCacheMaxSize+9)))
+
+	// expired announcements are evicted
+	c.Add("0xnew", t0.Add(announcementTTL+time.Second))
+	require.Len(t, c.announced, 1)
+	require.False(t, c.Pop("0xnew").IsZero())
+}
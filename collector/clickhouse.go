@@ -22,10 +22,11 @@ type ClickhouseOpts struct {
 }
 
 type SourceLogEntry struct {
-	ReceivedAt time.Time
-	Hash       string
-	Source     string
-	Location   string
+	ReceivedAt  time.Time
+	AnnouncedAt *time.Time // nil if the source doesn't expose hash announcements
+	Hash        string
+	Source      string
+	Location    string
 }
 
 type Clickhouse struct {
@@ -34,18 +35,24 @@ type Clickhouse struct {
 	log  *zap.SugaredLogger
 	conn driver.Conn
 
-	currentTxBatch        []common.TxSummaryEntry // Batch of transactions to be inserted
-	currentSourcelogBatch []SourceLogEntry        // Batch of source logs to be inserted
-	batchLock             sync.RWMutex            // Mutex to protect access to the current batches
+	currentTxBatch          []common.TxSummaryEntry   // Batch of transactions to be inserted
+	currentSourcelogBatch   []SourceLogEntry          // Batch of source logs to be inserted
+	currentReplacementBatch []common.ReplacementEntry // Batch of replacements to be inserted
+	batchLock               sync.RWMutex              // Mutex to protect access to the current batches
+	savesWg                 sync.WaitGroup            // batches being saved in the background
+
+	replacements *common.ReplacementTracker // latest transaction per sender and nonce (txs are queued, workers add them out of order)
 }
 
 // NewClickhouse creates a new Clickhouse instance with a connection to the database.
 func NewClickhouse(opts ClickhouseOpts) (*Clickhouse, error) {
 	ch := &Clickhouse{
-		log:                   opts.Log,
-		opts:                  opts,
-		currentTxBatch:        make([]common.TxSummaryEntry, 0, clickhouseBatchSize),
-		currentSourcelogBatch: make([]SourceLogEntry, 0, clickhouseBatchSize),
+		log:                     opts.Log,
+		opts:                    opts,
+		currentTxBatch:          make([]common.TxSummaryEntry, 0, clickhouseBatchSize),
+		currentSourcelogBatch:   make([]SourceLogEntry, 0, clickhouseBatchSize),
+		currentReplacementBatch: make([]common.ReplacementEntry, 0, clickhouseBatchSize),
+		replacements:            common.NewReplacementTracker(),
 	}
 	if ch.opts.DSN == "" {
 		return nil, ErrNoDSN
@@ -88,7 +95,7 @@ func (ch *Clickhouse) connect() error {
 	return nil
 }
 
-// AddTransaction adds a transaction to the Clickhouse batch. If the batch size exceeds the configured limit, it sends the batch to Clickhouse. This function is thread-safe.
+// AddTransaction adds a transaction to the Clickhouse batch. If the batch size exceeds the configured limit, it sends the batch to Clickhouse, and adds the replacements (transactions with the same sender and nonce as an earlier one) of the queued transactions in the order they were first seen. This function is thread-safe.
 func (ch *Clickhouse) AddTransaction(tx common.TxIn) error {
 	txSummary, _, err := common.ParseTx(tx.T.UnixMilli(), tx.Tx)
 	if err != nil {
@@ -96,6 +103,8 @@ func (ch *Clickhouse) AddTransaction(tx common.TxIn) error {
 		return fmt.Errorf("failed to parse transaction: %w", err)
 	}
 
+	ch.replacements.Queue(&txSummary)
+
 	// Add item to current batch
 	ch.batchLock.Lock()
 	defer ch.batchLock.Unlock()
@@ -103,9 +112,17 @@ func (ch *Clickhouse) AddTransaction(tx common.TxIn) error {
 
 	// Saving if enough entries
 	if len(ch.currentTxBatch) >= clickhouseBatchSize {
+		for _, replacement := range ch.replacements.FlushQueue(clickhouseReplacementWindow) {
+			ch.addReplacement(*replacement)
+		}
+
 		txs := slices.Clone(ch.currentTxBatch)
 		ch.currentTxBatch = ch.currentTxBatch[:0] // Clear the slice without reallocating
-		go ch.saveTransactionBatch(txs)
+		ch.savesWg.Add(1)
+		go func() {
+			defer ch.savesWg.Done()
+			ch.saveTransactionBatch(txs)
+		}()
 	}
 	return nil
 }
@@ -141,6 +158,16 @@ func (ch *Clickhouse) saveTransactionBatch(txs []common.TxSummaryEntry) {
 			tx.DataSize,
 			tx.Data4Bytes,
 			txBytes,
+			tx.AuthorizationCount,
+			tx.Authorizations,
+			tx.InvalidAuthorizationCount,
+			tx.AccessListAddressCount,
+			tx.AccessListStorageKeyCount,
+			tx.DataZeroBytes,
+			tx.DataNonZeroBytes,
+			tx.IntrinsicGas,
+			tx.IsContractCreation,
+			tx.ContractAddress,
 		)
 		if err != nil {
 			metrics.IncClickhouseError()
@@ -152,23 +179,84 @@ func (ch *Clickhouse) saveTransactionBatch(txs []common.TxSummaryEntry) {
 	ch.sendBatchWithRetries("transactions", batch)
 }
 
+// addReplacement adds a replacement to the batch, the caller must hold batchLock
+func (ch *Clickhouse) addReplacement(replacement common.ReplacementEntry) {
+	ch.currentReplacementBatch = append(ch.currentReplacementBatch, replacement)
+	if len(ch.currentReplacementBatch) >= clickhouseBatchSize {
+		replacements := slices.Clone(ch.currentReplacementBatch)
+		ch.currentReplacementBatch = ch.currentReplacementBatch[:0]
+		ch.savesWg.Add(1)
+		go func() {
+			defer ch.savesWg.Done()
+			ch.saveReplacements(replacements)
+		}()
+	}
+}
+
+func (ch *Clickhouse) saveReplacements(replacements []common.ReplacementEntry) {
+	batch, err := ch.conn.PrepareBatch(context.Background(), "INSERT INTO replacements")
+	if err != nil {
+		metrics.IncClickhouseError()
+		ch.log.Errorw("Failed to prepare Clickhouse batch insert", "error", err)
+		return
+	}
+
+	for _, r := range replacements {
+		err := batch.Append(
+			r.Timestamp,
+			r.Hash,
+			r.ReplacedHash,
+			r.From,
+			r.Nonce,
+			r.ReplacedTimestamp,
+			r.TimeSinceReplacedMs,
+			r.ChainIndex,
+			r.GasFeeCapDelta,
+			r.GasTipCapDelta,
+			r.IsCancellation,
+		)
+		if err != nil {
+			metrics.IncClickhouseError()
+			ch.log.Errorw("Failed to append replacement to Clickhouse batch", "error", err, "txHash", r.Hash)
+		}
+	}
+
+	// Start trying to save the batch (with retries)
+	ch.sendBatchWithRetries("replacements", batch)
+}
+
+// PruneReplacements forgets the sender/nonce pairs whose latest transaction was seen before the given time
+func (ch *Clickhouse) PruneReplacements(before time.Time) int {
+	return ch.replacements.Prune(before)
+}
+
 // AddSourceLog adds a source log to the Clickhouse batch. If the batch size exceeds the configured limit, it sends the batch to Clickhouse. This function is thread-safe.
-func (ch *Clickhouse) AddSourceLog(timeReceived time.Time, hash, source, location string) {
+func (ch *Clickhouse) AddSourceLog(timeReceived, timeAnnounced time.Time, hash, source, location string) {
+	var announcedAt *time.Time
+	if !timeAnnounced.IsZero() {
+		announcedAt = &timeAnnounced
+	}
+
 	// Add item to current batch
 	ch.batchLock.Lock()
 	defer ch.batchLock.Unlock()
 	ch.currentSourcelogBatch = append(ch.currentSourcelogBatch, SourceLogEntry{
-		ReceivedAt: timeReceived,
-		Hash:       hash,
-		Source:     source,
-		Location:   location,
+		ReceivedAt:  timeReceived,
+		AnnouncedAt: announcedAt,
+		Hash:        hash,
+		Source:      source,
+		Location:    location,
 	})
 
 	// Save to Clickhouse (if full batch)
 	if len(ch.currentSourcelogBatch) >= clickhouseBatchSize {
 		sourcelogs := slices.Clone(ch.currentSourcelogBatch)
 		ch.currentSourcelogBatch = ch.currentSourcelogBatch[:0] // Clear the slice without reallocating
-		go ch.saveSourcelogs(sourcelogs)
+		ch.savesWg.Add(1)
+		go func() {
+			defer ch.savesWg.Done()
+			ch.saveSourcelogs(sourcelogs)
+		}()
 	}
 }
 
@@ -186,6 +274,7 @@ func (ch *Clickhouse) saveSourcelogs(sourcelogs []SourceLogEntry) {
 			log.Hash,
 			log.Source,
 			log.Location,
+			log.AnnouncedAt,
 		)
 		if err != nil {
 			metrics.IncClickhouseError()
@@ -233,10 +322,19 @@ func (ch *Clickhouse) sendBatchWithRetries(name string, batch driver.Batch) {
 	}
 }
 
+// FlushCurrentBatches saves the current batches, and waits for batches still being saved in the background
 func (ch *Clickhouse) FlushCurrentBatches() {
 	ch.log.Info("Flushing current Clickhouse batches...")
 	ch.batchLock.Lock()
+	for _, replacement := range ch.replacements.FlushQueue(0) {
+		ch.currentReplacementBatch = append(ch.currentReplacementBatch, *replacement)
+	}
 	ch.saveTransactionBatch(ch.currentTxBatch)
 	ch.saveSourcelogs(ch.currentSourcelogBatch)
+	ch.saveReplacements(ch.currentReplacementBatch)
+	ch.currentTxBatch = ch.currentTxBatch[:0]
+	ch.currentSourcelogBatch = ch.currentSourcelogBatch[:0]
+	ch.currentReplacementBatch = ch.currentReplacementBatch[:0]
 	ch.batchLock.Unlock()
+	ch.savesWg.Wait()
 }
@@ -2,13 +2,19 @@
 package collector
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/flashbots/mempool-dumpster/api"
-	"github.com/flashbots/mempool-dumpster/common"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"go.uber.org/zap"
@@ -23,17 +29,55 @@ type CollectorOpts struct {
 
 	CheckNodeURI  string
 	ClickhouseDSN string
+	TxCacheFile   string // persists the known transactions across restarts (optional)
+
+	OverflowPolicy string // block, drop or spill (see OverflowPolicies)
+	SpillDir       string // disk queue directory for the spill policy
+
+	OutputCompression string // none, gzip or zstd (see OutputCompressions)
+	NoTxsCSV          bool   // don't write the txs_*.csv files
+	WriteParquet      bool   // write a txs_*.parquet file per bucket
+	ParquetSchemaVer  int    // schema version of the parquet files (see common.ParquetSchemaVersions)
+	WriteBlobSidecars bool   // write the sidecars of blob transactions to blobs_*.csv
 
 	BloxrouteAuth  []string
 	EdenAuth       []string
 	ChainboundAuth []string
+	Sources        []string // additional source URIs, i.e. "bloxroute://<auth>" (see RegisterSource)
+
+	DevP2PPeers      []string // enode URLs of static devp2p peers
+	DevP2PNodeKey    string   // hex-encoded devp2p node key (default: random)
+	DevP2PListenAddr string   // devp2p listen address (default: no inbound connections)
+	DevP2PMaxPeers   int
+	DevP2PDiscovery  bool // find additional devp2p peers via discv4
+
+	// Replay re-emits transactions from recorded files (txs_*.csv, src_*.csv or merged parquet files)
+	ReplayFiles []string
+	ReplaySpeed float64 // 1 = original pace, 0 = as fast as possible
+
+	// TrackAnnouncements records when a tx hash was first announced, for sources supporting hash subscriptions
+	TrackAnnouncements bool
 
 	Receivers               []string
 	ReceiversAllowedSources []string
 
 	APIListenAddr     string
+	APIGRPCListenAddr string // gRPC StreamTransactions service
 	MetricsListenAddr string
 	EnablePprof       bool // if true, enables pprof on the metrics server
+
+	// APIReplayBufferSize and APIReplayWindow bound the recent transactions kept for SSE clients resuming with Last-Event-ID
+	APIReplayBufferSize int
+	APIReplayWindow     time.Duration
+
+	// MinHealthySources is the number of subscribed sources required for /readyz to report ready
+	MinHealthySources int
+
+	// SourceIdleTimeout reconnects sources that didn't send a transaction for this long (0 disables the watchdog)
+	SourceIdleTimeout time.Duration
+
+	// ShutdownTimeout is the deadline for draining and flushing everything on shutdown (0 means no deadline)
+	ShutdownTimeout time.Duration
 }
 
 type Collector struct {
@@ -41,6 +85,15 @@ type Collector struct {
 	log       *zap.SugaredLogger
 	processor *TxProcessor
 	isReady   atomic.Bool
+
+	sources     []TxSource
+	sourcesLock sync.RWMutex
+
+	stopWatchdog context.CancelFunc
+	replay       *ReplaySource
+
+	apiServer     *api.Server
+	metricsServer *http.Server
 }
 
 func New(opts CollectorOpts) *Collector {
@@ -54,7 +107,7 @@ func New(opts CollectorOpts) *Collector {
 func (c *Collector) Start() {
 	// Start API and metrics servers (if enabled)
 	c.StartMetricsServer()
-	apiServer := c.StartAPIServer()
+	c.apiServer = c.StartAPIServer()
 
 	// Initialize the transaction processor, which is the brain of the collector
 	c.processor = NewTxProcessor(TxProcessorOpts{
@@ -66,63 +119,144 @@ func (c *Collector) Start() {
 		ClickhouseDSN:           c.opts.ClickhouseDSN,
 		HTTPReceivers:           c.opts.Receivers,
 		ReceiversAllowedSources: c.opts.ReceiversAllowedSources,
-		APIServer:               apiServer,
+		APIServer:               c.apiServer,
+		TxCacheFile:             c.opts.TxCacheFile,
+		OverflowPolicy:          c.opts.OverflowPolicy,
+		SpillDir:                c.opts.SpillDir,
+		OutputCompression:       c.opts.OutputCompression,
+		NoTxsCSV:                c.opts.NoTxsCSV,
+		WriteParquet:            c.opts.WriteParquet,
+		ParquetSchemaVersion:    c.opts.ParquetSchemaVer,
+		WriteBlobSidecars:       c.opts.WriteBlobSidecars,
 	})
 
+	// Replayed transactions have their recorded timestamps, so the processor's housekeeping follows the replay clock
+	if len(c.opts.ReplayFiles) > 0 {
+		c.replay = NewReplaySource(ReplaySourceOpts{
+			TxC:   c.processor.txC,
+			Log:   c.log,
+			Files: c.opts.ReplayFiles,
+			Speed: c.opts.ReplaySpeed,
+		})
+		c.processor.now = c.replay.Now
+	}
+
 	// Start the transaction processor, which kicks off background goroutines
 	c.processor.Start()
 
-	// Connect to regular nodes
-	for _, node := range c.opts.Nodes {
-		conn := NewNodeConnection(c.log, node, c.processor.txC)
-		conn.StartInBackground()
+	// Connect to all sources
+	for _, uri := range c.sourceURIs() {
+		source, err := NewSource(SourceOpts{
+			TxC:                c.processor.txC,
+			Log:                c.log,
+			URI:                uri,
+			TrackAnnouncements: c.opts.TrackAnnouncements,
+		})
+		if err != nil {
+			c.log.Fatalw("failed to create source", "error", err)
+		}
+		c.addSource(source)
 	}
 
-	// Connect to Bloxroute
-	for _, auth := range c.opts.BloxrouteAuth {
-		token, url := common.GetAuthTokenAndURL(auth)
-		startBloxrouteConnection(BlxNodeOpts{
+	// Join the devp2p network as eth/68 peer (a single p2p server for all peers)
+	if len(c.opts.DevP2PPeers) > 0 || c.opts.DevP2PDiscovery {
+		c.addSource(NewDevP2PNodeConnection(DevP2PNodeOpts{
 			TxC:        c.processor.txC,
 			Log:        c.log,
-			AuthHeader: token,
-			URL:        url,
-		})
+			Peers:      c.opts.DevP2PPeers,
+			NodeKey:    c.opts.DevP2PNodeKey,
+			ListenAddr: c.opts.DevP2PListenAddr,
+			MaxPeers:   c.opts.DevP2PMaxPeers,
+			Discovery:  c.opts.DevP2PDiscovery,
+		}))
 	}
 
-	// Connect to Eden
-	for _, auth := range c.opts.EdenAuth {
-		token, url := common.GetAuthTokenAndURL(auth)
-		startEdenConnection(EdenNodeOpts{
-			TxC:        c.processor.txC,
-			Log:        c.log,
-			AuthHeader: token,
-			URL:        url,
-		})
+	// Replay recorded transactions
+	if c.replay != nil {
+		c.addSource(c.replay)
 	}
 
-	// Connect to Chainbound
-	for _, auth := range c.opts.ChainboundAuth {
-		token, url := common.GetAuthTokenAndURL(auth)
-		chainboundConn := NewChainboundNodeConnection(ChainboundNodeOpts{
-			TxC:    c.processor.txC,
-			Log:    c.log,
-			APIKey: token,
-			URL:    url,
-		})
-		go chainboundConn.Start()
+	for _, source := range c.getSources() {
+		source.Start()
 	}
 
-	// Mark the collector as ready. TODO: improve to only mark true when at least one connection is established.
+	// Reconnect sources that silently stopped streaming
+	if c.opts.SourceIdleTimeout > 0 {
+		var ctx context.Context
+		ctx, c.stopWatchdog = context.WithCancel(context.Background())
+		go c.startSourceWatchdog(ctx, c.opts.SourceIdleTimeout)
+	}
+
+	// Mark the collector as started. /readyz additionally requires MinHealthySources subscribed sources.
 	c.isReady.Store(true)
 }
 
+// ReplayDone is closed once all recorded transactions were replayed (never, if not replaying)
+func (c *Collector) ReplayDone() <-chan struct{} {
+	if c.replay == nil {
+		return nil
+	}
+	return c.replay.Done()
+}
+
+func (c *Collector) addSource(source TxSource) {
+	c.sourcesLock.Lock()
+	c.sources = append(c.sources, source)
+	c.sourcesLock.Unlock()
+}
+
+func (c *Collector) getSources() []TxSource {
+	c.sourcesLock.RLock()
+	defer c.sourcesLock.RUnlock()
+	return slices.Clone(c.sources)
+}
+
+// SourcesHealth returns the connection health of all sources
+func (c *Collector) SourcesHealth() []SourceHealth {
+	sources := c.getSources()
+	health := make([]SourceHealth, 0, len(sources))
+	for _, source := range sources {
+		health = append(health, source.Health())
+	}
+	return health
+}
+
+// numHealthySources returns the number of sources with an established subscription
+func (c *Collector) numHealthySources() (n int) {
+	for _, source := range c.getSources() {
+		if source.Status() == SourceStateSubscribed {
+			n += 1
+		}
+	}
+	return n
+}
+
+// sourceURIs returns the URIs of all configured sources, for the source registry
+func (c *Collector) sourceURIs() []string {
+	uris := slices.Clone(c.opts.Nodes)
+	for _, auth := range c.opts.BloxrouteAuth {
+		uris = append(uris, "bloxroute://"+auth)
+	}
+	for _, auth := range c.opts.EdenAuth {
+		uris = append(uris, "eden://"+auth)
+	}
+	for _, auth := range c.opts.ChainboundAuth {
+		uris = append(uris, "chainbound://"+auth)
+	}
+	return append(uris, c.opts.Sources...)
+}
+
 func (c *Collector) StartAPIServer() *api.Server {
-	if c.opts.APIListenAddr == "" {
+	if c.opts.APIListenAddr == "" && c.opts.APIGRPCListenAddr == "" {
 		return nil
 	}
-	apiServer := api.New(&api.HTTPServerConfig{
-		Log:        c.log,
-		ListenAddr: c.opts.APIListenAddr,
+	apiServer := api.New(&api.HTTPServerConfig{ //nolint:exhaustruct
+		Log:                      c.log,
+		ListenAddr:               c.opts.APIListenAddr,
+		GRPCListenAddr:           c.opts.APIGRPCListenAddr,
+		GracefulShutdownDuration: apiShutdownTimeout,
+		ReplayBufferSize:         c.opts.APIReplayBufferSize,
+		ReplayWindow:             c.opts.APIReplayWindow,
 	})
 	go apiServer.RunInBackground()
 	return apiServer
@@ -132,6 +266,21 @@ func (c *Collector) StartMetricsServer() {
 	if c.opts.MetricsListenAddr == "" {
 		return
 	}
+	c.metricsServer = &http.Server{
+		Addr:              c.opts.MetricsListenAddr,
+		ReadHeaderTimeout: 5 * time.Second,
+		Handler:           c.metricsRouter(),
+	}
+	go func() {
+		c.log.Infow("Starting metrics server", "listenAddr", c.opts.MetricsListenAddr, "pprofEnabled", c.opts.EnablePprof)
+		err := c.metricsServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.log.Fatal("Failed to start metrics server", zap.Error(err))
+		}
+	}()
+}
+
+func (c *Collector) metricsRouter() http.Handler {
 	mux := chi.NewRouter()
 
 	// Add regular routes
@@ -148,33 +297,107 @@ func (c *Collector) StartMetricsServer() {
 			_, _ = w.Write([]byte("not ready"))
 			return
 		}
+		if healthy := c.numHealthySources(); healthy < c.opts.MinHealthySources {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprintf(w, "not ready: %d of %d required sources healthy", healthy, c.opts.MinHealthySources)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	mux.HandleFunc("/sources", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(c.SourcesHealth())
+		if err != nil {
+			c.log.Errorw("failed to encode sources health", "error", err)
+		}
+	})
 
 	// Enable pprof if requested
 	if c.opts.EnablePprof {
 		mux.Mount("/debug", middleware.Profiler())
 	}
 
-	metricsServer := &http.Server{
-		Addr:              c.opts.MetricsListenAddr,
-		ReadHeaderTimeout: 5 * time.Second,
-		Handler:           mux,
+	return mux
+}
+
+// stopSources stops all sources in parallel, and returns an error if some didn't stop before ctx is done (i.e. a hung
+// connection close)
+func (c *Collector) stopSources(ctx context.Context) error {
+	var (
+		wg          sync.WaitGroup
+		runningLock sync.Mutex
+		running     = make(map[string]bool) // names of the sources which are still stopping
+	)
+	for _, source := range c.getSources() {
+		runningLock.Lock()
+		running[source.Name()] = true
+		runningLock.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			source.Stop()
+			runningLock.Lock()
+			delete(running, source.Name())
+			runningLock.Unlock()
+		}()
 	}
+
+	stopped := make(chan struct{})
 	go func() {
-		c.log.Infow("Starting metrics server", "listenAddr", c.opts.MetricsListenAddr, "pprofEnabled", c.opts.EnablePprof)
-		err := metricsServer.ListenAndServe()
-		if err != nil {
-			c.log.Fatal("Failed to start metrics server", zap.Error(err))
-		}
+		wg.Wait()
+		close(stopped)
 	}()
+
+	select {
+	case <-stopped:
+		c.log.Info("All sources stopped")
+		return nil
+	case <-ctx.Done():
+		runningLock.Lock()
+		defer runningLock.Unlock()
+		return fmt.Errorf("stopping sources %v: %w", slices.Sorted(maps.Keys(running)), ctx.Err())
+	}
 }
 
-// Shutdown stops the collector and flush all pending transactions
-func (c *Collector) Shutdown() {
+// Shutdown stops the collector in order: it stops all sources, drains and flushes all pending transactions (receivers,
+// Clickhouse, fsync of output files), and finally stops the API and metrics servers. Returns an error if the
+// ShutdownTimeout deadline is exceeded.
+func (c *Collector) Shutdown() error {
+	ctx := context.Background()
+	sourcesCtx := ctx
+	if c.opts.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.ShutdownTimeout)
+		defer cancel()
+
+		// the sources get half of the timeout, so a hung source leaves time to drain the pipeline
+		sourcesCtx, cancel = context.WithTimeout(ctx, c.opts.ShutdownTimeout/2)
+		defer cancel()
+	}
+
+	// 1. stop receiving transactions
 	c.isReady.Store(false)
+	if c.stopWatchdog != nil {
+		c.stopWatchdog()
+	}
+	err := c.stopSources(sourcesCtx)
+
+	// 2. drain the pipeline, and flush receivers, Clickhouse and output files. If a source is still stopping, it could
+	// still send to the pipeline: then the ingest channel stays open, and only the transactions queued so far are drained.
 	if c.processor != nil {
-		c.processor.Shutdown()
+		err = errors.Join(err, c.processor.shutdown(ctx, err == nil))
+	}
+
+	// 3. stop the servers
+	if c.apiServer != nil {
+		c.apiServer.Shutdown()
+	}
+	if c.metricsServer != nil {
+		if err := c.metricsServer.Shutdown(ctx); err != nil {
+			c.log.Errorw("failed to shut down metrics server", "error", err)
+		}
 	}
+	return err
 }
@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestCollector_readyzAndSources(t *testing.T) {
+	c := New(CollectorOpts{ //nolint:exhaustruct
+		Log:               common.GetLogger(true, false),
+		MinHealthySources: 1,
+	})
+	router := c.metricsRouter()
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	// not started yet
+	require.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+
+	// started, but no source subscribed
+	connected := make(chan struct{})
+	source := newSourceSupervisor(c.log, "test", func(ctx context.Context, onConnected func()) error {
+		<-connected
+		onConnected()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c.addSource(source)
+	source.Start()
+	defer source.Stop()
+	c.isReady.Store(true)
+	require.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+
+	// source subscribed
+	close(connected)
+	require.Eventually(t, func() bool { return get("/readyz").Code == http.StatusOK }, time.Second, 10*time.Millisecond)
+
+	rr := get("/sources")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var health []SourceHealth
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &health))
+	require.Len(t, health, 1)
+	require.Equal(t, "test", health[0].Name)
+	require.Equal(t, SourceStateSubscribed, health[0].State)
+	require.Nil(t, health[0].LastTxTime)
+}
+
+func TestCollector_sourceWatchdog(t *testing.T) {
+	c := New(CollectorOpts{ //nolint:exhaustruct
+		Log: common.GetLogger(true, false),
+	})
+
+	// a source which subscribes, but never sends a transaction
+	source := newSourceSupervisor(c.log, "test", func(ctx context.Context, onConnected func()) error {
+		onConnected()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	source.backoffSec = 0
+	c.addSource(source)
+	source.Start()
+	defer source.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.startSourceWatchdog(ctx, 50*time.Millisecond)
+
+	require.Eventually(t, func() bool { return source.Health().Reconnects > 0 }, time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, source.LastError(), errSourceStalled)
+}
+
+// idleTestSource handles idle connections itself
+type idleTestSource struct {
+	*sourceSupervisor
+	idleCalls atomic.Int64
+}
+
+func (s *idleTestSource) HandleIdle(idleTimeout time.Duration) {
+	s.idleCalls.Inc()
+}
+
+func TestCollector_sourceWatchdog_idleHandler(t *testing.T) {
+	c := New(CollectorOpts{ //nolint:exhaustruct
+		Log: common.GetLogger(true, false),
+	})
+
+	source := &idleTestSource{} //nolint:exhaustruct
+	source.sourceSupervisor = newSourceSupervisor(c.log, "test", func(ctx context.Context, onConnected func()) error {
+		onConnected()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c.addSource(source)
+	source.Start()
+	defer source.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.startSourceWatchdog(ctx, 50*time.Millisecond)
+
+	// the source's idle action is called instead of reconnecting
+	require.Eventually(t, func() bool { return source.idleCalls.Load() > 0 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, uint64(0), source.Health().Reconnects)
+}
+
+func TestCollector_stopSourcesTimeout(t *testing.T) {
+	c := New(CollectorOpts{ //nolint:exhaustruct
+		Log: common.GetLogger(true, false),
+	})
+
+	// a source whose connection close hangs
+	release := make(chan struct{})
+	defer close(release)
+	hung := newSourceSupervisor(c.log, "hung", func(ctx context.Context, onConnected func()) error {
+		onConnected()
+		<-ctx.Done()
+		<-release
+		return ctx.Err()
+	})
+	c.addSource(hung)
+	hung.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.stopSources(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, err.Error(), "[hung]")
+}
@@ -13,6 +13,12 @@ const (
 	// exponential backoff settings
 	initialBackoffSec = 5
 	maxBackoffSec     = 120
+
+	// apiShutdownTimeout is how long open API connections are given to finish on shutdown
+	apiShutdownTimeout = 5 * time.Second
+
+	// devp2pDefaultMaxPeers is the default maximum number of devp2p peers
+	devp2pDefaultMaxPeers = 50
 )
 
 var (
@@ -34,4 +40,12 @@ var (
 	// https://clickhouse.com/docs/best-practices/selecting-an-insert-strategy
 	clickhouseBatchSize   = common.GetEnvInt("CLICKHOUSE_BATCH_SIZE", 1_000)
 	clickhouseSaveRetries = common.GetEnvInt("CLICKHOUSE_SAVE_RETRIES", 5)
+
+	// clickhouseReplacementWindow - transactions seen within the window stay queued for the replacements, as the
+	// workers may process earlier transactions after them
+	clickhouseReplacementWindow = time.Duration(common.GetEnvInt("CLICKHOUSE_REPLACEMENT_WINDOW_SEC", 10)) * time.Second
+
+	// TxProcessor workers (sharded by tx hash) and the size of each worker queue
+	txProcessorWorkers         = common.GetEnvInt("TX_PROCESSOR_WORKERS", 8)
+	txProcessorWorkerQueueSize = common.GetEnvInt("TX_PROCESSOR_WORKER_QUEUE_SIZE", 1_000)
 )
@@ -0,0 +1,336 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/flashbots/mempool-dumpster/metrics"
+)
+
+// Overflow policies, i.e. what happens to incoming transactions when the processing queue is full
+const (
+	OverflowPolicyBlock = "block" // sources wait until there is space in the queue
+	OverflowPolicyDrop  = "drop"  // the newest transactions are dropped (and counted, and written to the trash)
+	OverflowPolicySpill = "spill" // transactions are written to a disk queue, and processed once there is space again
+
+	// spillSegmentSize is the number of transactions per spill file
+	spillSegmentSize = 10_000
+)
+
+var OverflowPolicies = []string{OverflowPolicyBlock, OverflowPolicyDrop, OverflowPolicySpill}
+
+// startIngestLoop moves transactions from the sources (txC) into the processing queue, applying the overflow policy
+func (p *TxProcessor) startIngestLoop() {
+	defer close(p.ingestDone)
+	for {
+		select {
+		case txIn, ok := <-p.txC:
+			if !ok {
+				return
+			}
+			p.ingestTx(txIn)
+
+		case <-p.ingestStop:
+			// process what's already queued, later transactions of still running sources are lost
+			for {
+				select {
+				case txIn := <-p.txC:
+					p.ingestTx(txIn)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// ingestTx moves a transaction into the processing queue, applying the overflow policy
+func (p *TxProcessor) ingestTx(txIn common.TxIn) {
+	if txIn.Tx == nil {
+		return
+	}
+
+	switch p.overflowPolicy {
+	case OverflowPolicyDrop:
+		select {
+		case p.queueC <- txIn:
+		default:
+			p.dropTx(txIn)
+		}
+	case OverflowPolicySpill:
+		if p.spill.Len() > 0 { // keep the order while there are spilled transactions
+			p.spillTx(txIn)
+			return
+		}
+		select {
+		case p.queueC <- txIn:
+		default:
+			p.spillTx(txIn)
+		}
+	default:
+		p.queueC <- txIn
+	}
+}
+
+func (p *TxProcessor) dropTx(txIn common.TxIn) {
+	metrics.IncTxDropped(txIn.Source)
+	p.srcMetrics.Inc(KeyStatsTxDropped, txIn.Source)
+
+	if p.outDir == "" {
+		return
+	}
+	outFiles, _, err := p.getOutputCSVFiles(txIn.T.Unix())
+	if err != nil {
+		p.log.Errorw("getOutputFiles", "error", err)
+		return
+	}
+	defer outFiles.release()
+	p.writeTrash(outFiles.FTrash, txIn, common.TrashTxDropped, "processing queue full")
+}
+
+func (p *TxProcessor) spillTx(txIn common.TxIn) {
+	err := p.spill.Push(txIn)
+	if err != nil {
+		p.log.Errorw("failed to spill transaction", "error", err, "source", txIn.Source)
+		p.dropTx(txIn)
+		return
+	}
+	metrics.IncTxSpilled(txIn.Source)
+}
+
+// startSpillDrainLoop moves spilled transactions back into the processing queue, until spillDrainStop is closed
+func (p *TxProcessor) startSpillDrainLoop() {
+	defer close(p.spillDrainDone)
+	for {
+		select {
+		case <-p.spillDrainStop:
+			return
+		default:
+		}
+
+		txs, err := p.spill.PopSegment()
+		if err != nil {
+			p.log.Errorw("failed to read spilled transactions", "error", err)
+		}
+		if len(txs) == 0 {
+			select {
+			case <-p.spillDrainStop:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+		for _, txIn := range txs {
+			p.queueC <- txIn
+			p.spill.Done(1) // counted until queued, so ingest keeps spilling newer txs meanwhile
+		}
+	}
+}
+
+// spillQueue is a FIFO disk queue of transactions, stored in segment files (one line per tx: <t_ns>,<announced_ns>,<raw_tx>,<source>)
+type spillQueue struct {
+	dir string
+
+	lock     sync.Mutex
+	segments []spillSegment  // closed segments, oldest first
+	popped   []*spillSegment // popped segments, oldest first, removed once all their transactions are Done
+	f        *os.File        // segment currently being written
+	fCnt     int             // number of transactions in the current segment
+	cnt      int             // number of transactions in the queue, including popped ones until they are Done
+	seq      int
+}
+
+type spillSegment struct {
+	fn   string
+	cnt  int
+	done int // number of popped transactions marked Done
+}
+
+// newSpillQueue creates the spill directory, and picks up segment files left over from a previous run
+func newSpillQueue(dir string) (*spillQueue, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "spill_*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	q := &spillQueue{dir: dir} //nolint:exhaustruct
+	for _, fn := range files {
+		content, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		cnt := bytes.Count(content, []byte("\n"))
+		q.segments = append(q.segments, spillSegment{fn: fn, cnt: cnt})
+		q.cnt += cnt
+	}
+	return q, nil
+}
+
+// Len returns the number of spilled transactions
+func (q *spillQueue) Len() int {
+	if q == nil {
+		return 0
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.cnt
+}
+
+// Push appends a transaction to the current segment
+func (q *spillQueue) Push(txIn common.TxIn) error {
+	rawTx, err := txIn.Tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	var announcedNs int64
+	if !txIn.AnnouncedAt.IsZero() {
+		announcedNs = txIn.AnnouncedAt.UnixNano()
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.f == nil {
+		q.seq += 1
+		fn := filepath.Join(q.dir, fmt.Sprintf("spill_%d_%06d.csv", time.Now().UnixNano(), q.seq))
+		q.f, err = os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(q.f, "%d,%d,%s,%s\n", txIn.T.UnixNano(), announcedNs, hexutil.Encode(rawTx), txIn.Source)
+	if err != nil {
+		return err
+	}
+	q.cnt += 1
+	q.fCnt += 1
+
+	if q.fCnt >= spillSegmentSize {
+		q.closeSegment()
+	}
+	return nil
+}
+
+// Close closes the current segment, which keeps it on disk for the next start
+func (q *spillQueue) Close() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.closeSegment()
+}
+
+func (q *spillQueue) closeSegment() {
+	if q.f == nil {
+		return
+	}
+	_ = q.f.Close()
+	q.segments = append(q.segments, spillSegment{fn: q.f.Name(), cnt: q.fCnt})
+	q.f = nil
+	q.fCnt = 0
+}
+
+// Done marks popped transactions (in the order they were popped) as moved back into the processing queue. A segment
+// file is removed once all its transactions are Done, until then it's picked up again after a restart.
+func (q *spillQueue) Done(n int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.cnt -= n
+	for n > 0 && len(q.popped) > 0 {
+		segment := q.popped[0]
+		cnt := min(n, segment.cnt-segment.done)
+		segment.done += cnt
+		n -= cnt
+		if segment.done == segment.cnt {
+			_ = os.Remove(segment.fn)
+			q.popped = q.popped[1:]
+		}
+	}
+}
+
+// PopSegment reads the oldest segment (the current segment is closed first if it's the only one). The transactions
+// still count for Len until they are marked Done, so that newer transactions are spilled behind them, and the segment
+// file is kept until then.
+func (q *spillQueue) PopSegment() (txs []common.TxIn, err error) {
+	q.lock.Lock()
+	if len(q.segments) == 0 {
+		q.closeSegment()
+	}
+	if len(q.segments) == 0 {
+		q.lock.Unlock()
+		return nil, nil
+	}
+	segment := q.segments[0]
+	q.segments = q.segments[1:]
+	q.lock.Unlock()
+
+	txs, err = readSpillSegment(segment.fn)
+
+	// the unreadable lines (i.e. incomplete after a crash) won't be Done
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.cnt -= segment.cnt - len(txs)
+	if len(txs) == 0 {
+		_ = os.Remove(segment.fn)
+	} else {
+		q.popped = append(q.popped, &spillSegment{fn: segment.fn, cnt: len(txs)}) //nolint:exhaustruct
+	}
+	return txs, err
+}
+
+func readSpillSegment(fn string) (txs []common.TxIn, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // blob transactions with sidecars are large
+	for scanner.Scan() {
+		items := strings.SplitN(scanner.Text(), ",", 4)
+		if len(items) != 4 {
+			continue // incomplete line
+		}
+
+		ts, err := strconv.ParseInt(items[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		announcedNs, err := strconv.ParseInt(items[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		rawTx, err := hexutil.Decode(items[2])
+		if err != nil {
+			continue
+		}
+		tx := new(types.Transaction)
+		if err = tx.UnmarshalBinary(rawTx); err != nil {
+			continue
+		}
+
+		txIn := common.TxIn{T: time.Unix(0, ts).UTC(), Tx: tx, Source: items[3]} //nolint:exhaustruct
+		if announcedNs > 0 {
+			txIn.AnnouncedAt = time.Unix(0, announcedNs).UTC()
+		}
+		txs = append(txs, txIn)
+	}
+	return txs, scanner.Err()
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := newSpillQueue(dir)
+	require.NoError(t, err)
+	require.Equal(t, 0, q.Len())
+
+	now := time.Unix(1693785600, 123456789).UTC()
+	tx1, tx2 := newTestTx(t, 0), newTestTx(t, 1)
+	require.NoError(t, q.Push(common.TxIn{T: now, Tx: tx1, Source: "source1", AnnouncedAt: now.Add(-time.Second)}))
+	require.NoError(t, q.Push(common.TxIn{T: now, Tx: tx2, Source: "ws://node,1"})) //nolint:exhaustruct
+	require.Equal(t, 2, q.Len())
+
+	// segments left over from a previous run are picked up again
+	q.closeSegment()
+	q, err = newSpillQueue(dir)
+	require.NoError(t, err)
+	require.Equal(t, 2, q.Len())
+
+	// popped transactions are counted until they are done
+	txs, err := q.PopSegment()
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	require.Equal(t, 2, q.Len())
+	q.Done(2)
+	require.Equal(t, 0, q.Len())
+	require.Equal(t, tx1.Hash(), txs[0].Tx.Hash())
+	require.Equal(t, now, txs[0].T)
+	require.Equal(t, now.Add(-time.Second), txs[0].AnnouncedAt)
+	require.Equal(t, "source1", txs[0].Source)
+	require.Equal(t, tx2.Hash(), txs[1].Tx.Hash())
+	require.True(t, txs[1].AnnouncedAt.IsZero())
+	require.Equal(t, "ws://node,1", txs[1].Source)
+
+	txs, err = q.PopSegment()
+	require.NoError(t, err)
+	require.Empty(t, txs)
+}
+
+func TestSpillQueue_restartBeforeDone(t *testing.T) {
+	dir := t.TempDir()
+	q, err := newSpillQueue(dir)
+	require.NoError(t, err)
+
+	now := time.Unix(1693785600, 0).UTC()
+	tx1, tx2 := newTestTx(t, 0), newTestTx(t, 1)
+	require.NoError(t, q.Push(common.TxIn{T: now, Tx: tx1, Source: "source1"})) //nolint:exhaustruct
+	require.NoError(t, q.Push(common.TxIn{T: now, Tx: tx2, Source: "source1"})) //nolint:exhaustruct
+
+	// a popped segment is kept on disk until all its transactions are done
+	txs, err := q.PopSegment()
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	q.Done(1)
+
+	// restart: the transactions of the segment are still there
+	q, err = newSpillQueue(dir)
+	require.NoError(t, err)
+	require.Equal(t, 2, q.Len())
+	txs, err = q.PopSegment()
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	require.Equal(t, tx1.Hash(), txs[0].Tx.Hash())
+	require.Equal(t, tx2.Hash(), txs[1].Tx.Hash())
+
+	// once done, the segment file is removed
+	q.Done(2)
+	q, err = newSpillQueue(dir)
+	require.NoError(t, err)
+	require.Equal(t, 0, q.Len())
+}
+
+func TestTxProcessor_overflowDrop(t *testing.T) {
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:            common.GetLogger(true, false),
+		OutDir:         t.TempDir(),
+		UID:            "test",
+		OverflowPolicy: OverflowPolicyDrop,
+	})
+	processor.queueC = make(chan common.TxIn) // nothing is processing, the queue is always full
+	go processor.startIngestLoop()
+
+	now := time.Now()
+	processor.txC <- common.TxIn{T: now, Tx: newTestTx(t, 0), Source: "source1"} //nolint:exhaustruct
+
+	outFiles, _, err := processor.getOutputCSVFiles(now.Unix())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		trash, err := os.ReadFile(outFiles.FTrash.Name())
+		return err == nil && strings.Contains(string(trash), ",source1,"+common.TrashTxDropped+",")
+	}, 2*time.Second, 10*time.Millisecond)
+}
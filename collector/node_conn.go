@@ -2,117 +2,151 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/flashbots/mempool-dumpster/common"
 	"go.uber.org/zap"
 )
 
-type NodeConnection struct {
-	log        *zap.SugaredLogger
-	uri        string
-	uriTag     string // identifier of tx source (i.e. "infura", "alchemy", "ws://localhost:8546")
-	txC        chan common.TxIn
-	isAlchemy  bool
-	backoffSec int
-}
+type NodeConnectionOpts struct {
+	TxC chan common.TxIn
+	Log *zap.SugaredLogger
+	URI string
 
-func NewNodeConnection(log *zap.SugaredLogger, nodeURI string, txC chan common.TxIn) *NodeConnection {
-	srcAlias := common.TxSourcName(nodeURI)
-	return &NodeConnection{
-		log:        log.With("src", srcAlias),
-		uri:        nodeURI,
-		uriTag:     srcAlias,
-		txC:        txC,
-		isAlchemy:  strings.Contains(nodeURI, "alchemy.com/"),
-		backoffSec: initialBackoffSec,
-	}
+	// TrackAnnouncements additionally subscribes to pending tx hashes, to record when a tx was first announced
+	TrackAnnouncements bool
 }
 
-func (nc *NodeConnection) StartInBackground() {
-	go nc.connect()
+type NodeConnection struct {
+	*sourceSupervisor
+
+	log       *zap.SugaredLogger
+	uri       string
+	uriTag    string // identifier of tx source (i.e. "infura", "alchemy", "ws://localhost:8546")
+	txC       chan common.TxIn
+	isAlchemy bool
+
+	trackAnnouncements bool
+	announcements      *announcementCache
 }
 
-func (nc *NodeConnection) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting in %s sec ...", backoffDuration.String())
-	time.Sleep(backoffDuration)
+func init() {
+	factory := func(opts SourceOpts) (TxSource, error) {
+		return NewNodeConnection(NodeConnectionOpts(opts)), nil
+	}
+	RegisterSource("ws", factory)
+	RegisterSource("wss", factory)
+	RegisterSource("ipc", factory)
+}
 
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
+func NewNodeConnection(opts NodeConnectionOpts) *NodeConnection {
+	srcAlias := common.TxSourcName(opts.URI)
+	isAlchemy := strings.Contains(opts.URI, "alchemy.com/")
+	nc := &NodeConnection{ //nolint:exhaustruct
+		log:       opts.Log.With("src", srcAlias),
+		uri:       opts.URI,
+		uriTag:    srcAlias,
+		txC:       opts.TxC,
+		isAlchemy: isAlchemy,
+
+		// Alchemy doesn't support the regular hash subscription (and it would burn even more credits)
+		trackAnnouncements: opts.TrackAnnouncements && !isAlchemy,
+		announcements:      newAnnouncementCache(),
 	}
+	nc.sourceSupervisor = newSourceSupervisor(nc.log, srcAlias, nc.run)
+	return nc
+}
 
-	nc.connect()
+func (nc *NodeConnection) StartInBackground() {
+	nc.Start()
 }
 
-func (nc *NodeConnection) connect() {
+func (nc *NodeConnection) run(ctx context.Context, onConnected func()) error {
 	var err error
-	var sub *rpc.ClientSubscription
+	var sub, hashSub *rpc.ClientSubscription
 	localC := make(chan *types.Transaction)
+	hashC := make(chan ethcommon.Hash)
+
+	nc.log.Infow("connecting...", "uri", nc.uri)
+	rpcClient, err := rpc.DialContext(ctx, nc.uri)
+	if err != nil {
+		return err
+	}
+	defer rpcClient.Close()
 
 	if nc.isAlchemy {
-		sub, err = nc.connectAlchemy(localC)
+		sub, err = nc.connectAlchemy(ctx, rpcClient, localC)
 	} else {
-		sub, err = nc.connectGeneric(localC)
+		sub, hashSub, err = nc.connectGeneric(ctx, rpcClient, localC, hashC)
 	}
-
 	if err != nil {
-		nc.log.Errorw("failed to connect, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
-		return
+		return err
+	}
+	nc.log.Infow("connection successful", "uri", nc.uri, "trackAnnouncements", nc.trackAnnouncements)
+	onConnected()
+
+	defer sub.Unsubscribe()
+	if hashSub != nil {
+		defer hashSub.Unsubscribe()
+	}
+
+	// hashSubErrC stays nil (blocks forever) if not tracking announcements
+	var hashSubErrC <-chan error
+	if hashSub != nil {
+		hashSubErrC = hashSub.Err()
 	}
 
 	for {
 		select {
+		case <-ctx.Done():
+			return ctx.Err()
 		case err := <-sub.Err():
-			nc.log.Errorw("subscription error, reconnecting...", "error", err)
-			go nc.reconnect()
+			return fmt.Errorf("subscription error: %w", err)
+		case err := <-hashSubErrC:
+			return fmt.Errorf("hash subscription error: %w", err)
+		case hash := <-hashC:
+			nc.announcements.Add(strings.ToLower(hash.Hex()), time.Now().UTC())
 		case tx := <-localC:
-			nc.txC <- common.TxIn{
-				T:      time.Now().UTC(),
-				Tx:     tx,
-				Source: nc.uriTag,
+			err = nc.sendTx(ctx, nc.txC, common.TxIn{
+				T:           time.Now().UTC(),
+				Tx:          tx,
+				Source:      nc.uriTag,
+				AnnouncedAt: nc.announcements.Pop(strings.ToLower(tx.Hash().Hex())),
+			})
+			if err != nil {
+				return err
 			}
 		}
 	}
 }
 
-func (nc *NodeConnection) connectGeneric(txC chan *types.Transaction) (*rpc.ClientSubscription, error) {
-	nc.log.Infow("connecting...", "uri", nc.uri)
-	rpcClient, err := rpc.Dial(nc.uri)
-	if err != nil {
-		return nil, err
+func (nc *NodeConnection) connectGeneric(ctx context.Context, rpcClient *rpc.Client, txC chan *types.Transaction, hashC chan ethcommon.Hash) (sub, hashSub *rpc.ClientSubscription, err error) {
+	client := gethclient.New(rpcClient)
+	if nc.trackAnnouncements {
+		// subscribe to hashes first, so the announcement is usually recorded before the full tx arrives
+		hashSub, err = client.SubscribePendingTransactions(ctx, hashC)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	sub, err := gethclient.New(rpcClient).SubscribeFullPendingTransactions(context.Background(), txC)
+	sub, err = client.SubscribeFullPendingTransactions(ctx, txC)
 	if err != nil {
-		return nil, err
+		if hashSub != nil {
+			hashSub.Unsubscribe()
+		}
+		return nil, nil, err
 	}
-
-	nc.log.Infow("connection successful", "uri", nc.uri)
-	return sub, nil
+	return sub, hashSub, nil
 }
 
 // connectAlchemy connects to Alchemy's pendingTransactions subscription (warning -- burns _a lot_ of CU credits)
-func (nc *NodeConnection) connectAlchemy(txC chan *types.Transaction) (*rpc.ClientSubscription, error) {
-	nc.log.Infow("connecting...", "uri", nc.uri)
-	client, err := ethclient.Dial(nc.uri)
-	if err != nil {
-		return nil, err
-	}
-
-	sub, err := client.Client().Subscribe(context.Background(), "eth", txC, "alchemy_pendingTransactions")
-	if err != nil {
-		return nil, err
-	}
-
-	nc.log.Infow("connection successful", "uri", nc.uri)
-	return sub, nil
+func (nc *NodeConnection) connectAlchemy(ctx context.Context, rpcClient *rpc.Client, txC chan *types.Transaction) (*rpc.ClientSubscription, error) {
+	return rpcClient.Subscribe(ctx, "eth", txC, "alchemy_pendingTransactions")
 }
@@ -10,6 +10,7 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -29,26 +30,47 @@ type BlxNodeOpts struct {
 	AuthHeader string
 	URL        string // optional override, default: blxDefaultURL
 	SourceTag  string // optional override, default: "blx" (common.BloxrouteTag)
+
+	// TrackAnnouncements additionally subscribes to pendingTxs hashes (websocket only), to record when a tx was first announced
+	TrackAnnouncements bool
+}
+
+func init() {
+	// bloxroute://<auth-header>[@<url>]
+	RegisterSource("bloxroute", func(opts SourceOpts) (TxSource, error) {
+		token, url := sourceAuthFromURI(opts.URI)
+		return NewBloxrouteSource(BlxNodeOpts{ //nolint:exhaustruct
+			TxC:                opts.TxC,
+			Log:                opts.Log,
+			AuthHeader:         token,
+			URL:                url,
+			TrackAnnouncements: opts.TrackAnnouncements,
+		}), nil
+	})
 }
 
-// startBloxrouteConnection starts a Websocket or gRPC subscription (depending on URL) in the background
-func startBloxrouteConnection(opts BlxNodeOpts) {
+// NewBloxrouteSource returns a Websocket or gRPC connection, depending on the URL
+func NewBloxrouteSource(opts BlxNodeOpts) TxSource {
+	if opts.URL == "" {
+		opts.URL = blxDefaultURL
+	}
 	if common.IsWebsocketProtocol(opts.URL) {
-		blxConn := NewBlxNodeConnection(opts)
-		go blxConn.Start()
-	} else {
-		blxConn := NewBlxNodeConnectionGRPC(opts)
-		go blxConn.Start()
+		return NewBlxNodeConnection(opts)
 	}
+	return NewBlxNodeConnectionGRPC(opts)
 }
 
 type BlxNodeConnection struct {
+	*sourceSupervisor
+
 	log        *zap.SugaredLogger
 	authHeader string
 	url        string
 	srcTag     string
 	txC        chan common.TxIn
-	backoffSec int
+
+	trackAnnouncements bool
+	announcements      *announcementCache
 }
 
 func NewBlxNodeConnection(opts BlxNodeOpts) *BlxNodeConnection {
@@ -62,57 +84,52 @@ func NewBlxNodeConnection(opts BlxNodeOpts) *BlxNodeConnection {
 		srcTag = common.SourceTagBloxroute
 	}
 
-	return &BlxNodeConnection{
+	nc := &BlxNodeConnection{
 		log:        opts.Log.With("src", srcTag),
 		authHeader: opts.AuthHeader,
 		url:        url,
 		srcTag:     srcTag,
 		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
-	}
-}
-
-func (nc *BlxNodeConnection) Start() {
-	nc.connect()
-}
-
-func (nc *BlxNodeConnection) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting to %s in %s sec ...", nc.srcTag, backoffDuration.String())
-	time.Sleep(backoffDuration)
 
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
+		trackAnnouncements: opts.TrackAnnouncements,
+		announcements:      newAnnouncementCache(),
 	}
-
-	nc.connect()
+	nc.sourceSupervisor = newSourceSupervisor(nc.log, srcTag, nc.run)
+	return nc
 }
 
 //nolint:dupl
-func (nc *BlxNodeConnection) connect() {
+func (nc *BlxNodeConnection) run(ctx context.Context, onConnected func()) error {
 	nc.log.Infow("connecting...", "uri", nc.url)
 	dialer := websocket.DefaultDialer
-	wsSubscriber, resp, err := dialer.Dial(nc.url, http.Header{"Authorization": []string{nc.authHeader}})
+	wsSubscriber, resp, err := dialer.DialContext(ctx, nc.url, http.Header{"Authorization": []string{nc.authHeader}})
 	if err != nil {
-		nc.log.Errorw("failed to connect to bloxroute, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
-		return
+		return fmt.Errorf("failed to connect to bloxroute: %w", err)
 	}
 	defer wsSubscriber.Close()
 	defer resp.Body.Close()
 
+	// unblock ReadMessage when the source is stopped
+	stop := context.AfterFunc(ctx, func() { _ = wsSubscriber.Close() })
+	defer stop()
+
+	if nc.trackAnnouncements {
+		// pendingTxs only carries the hash, the full transaction is received via newTxs
+		subRequest := `{"id": 2, "method": "subscribe", "params": ["pendingTxs", {"include": ["tx_hash"]}]}`
+		err = wsSubscriber.WriteMessage(websocket.TextMessage, []byte(subRequest))
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to bloxroute pendingTxs: %w", err)
+		}
+	}
+
 	subRequest := `{"id": 1, "method": "subscribe", "params": ["newTxs", {"include": ["raw_tx"]}]}`
 	err = wsSubscriber.WriteMessage(websocket.TextMessage, []byte(subRequest))
 	if err != nil {
-		nc.log.Errorw("failed to subscribe to bloxroute", "error", err)
-		go nc.reconnect()
-		return
+		return fmt.Errorf("failed to subscribe to bloxroute: %w", err)
 	}
 
 	nc.log.Infow("connection successful", "uri", nc.url)
-	nc.backoffSec = initialBackoffSec // reset backoff timeout
+	onConnected()
 
 	for {
 		_, nextNotification, err := wsSubscriber.ReadMessage()
@@ -120,13 +137,9 @@ func (nc *BlxNodeConnection) connect() {
 			// Handle websocket errors, by closing and reconnecting. Errors seen previously:
 			// - "websocket: close 1006 (abnormal closure): unexpected EOF"
 			if strings.Contains(err.Error(), "failed parsing the authorization header") {
-				nc.log.Errorw("invalid bloxroute auth header", "error", err)
-			} else {
-				nc.log.Errorw("failed to read message, reconnecting", "error", err)
+				return fmt.Errorf("invalid bloxroute auth header: %w", err)
 			}
-
-			go nc.reconnect()
-			return
+			return fmt.Errorf("failed to read message: %w", err)
 		}
 
 		// fmt.Println("got message", string(nextNotification))
@@ -139,6 +152,10 @@ func (nc *BlxNodeConnection) connect() {
 		rlp := txMsg.Params.Result.RawTx
 
 		if len(rlp) == 0 {
+			// hash-only notification from pendingTxs
+			if txHash := txMsg.Params.Result.TxHash; txHash != "" {
+				nc.announcements.Add(strings.ToLower(txHash), time.Now().UTC())
+			}
 			continue
 		}
 
@@ -156,21 +173,26 @@ func (nc *BlxNodeConnection) connect() {
 			continue
 		}
 
-		nc.txC <- common.TxIn{
-			T:      time.Now().UTC(),
-			Tx:     &tx,
-			Source: nc.srcTag,
+		err = nc.sendTx(ctx, nc.txC, common.TxIn{
+			T:           time.Now().UTC(),
+			Tx:          &tx,
+			Source:      nc.srcTag,
+			AnnouncedAt: nc.announcements.Pop(strings.ToLower(tx.Hash().Hex())),
+		})
+		if err != nil {
+			return err
 		}
 	}
 }
 
 type BlxNodeConnectionGRPC struct {
+	*sourceSupervisor
+
 	log        *zap.SugaredLogger
 	authHeader string
 	url        string
 	srcTag     string
 	txC        chan common.TxIn
-	backoffSec int
 }
 
 func NewBlxNodeConnectionGRPC(opts BlxNodeOpts) *BlxNodeConnectionGRPC {
@@ -179,55 +201,35 @@ func NewBlxNodeConnectionGRPC(opts BlxNodeOpts) *BlxNodeConnectionGRPC {
 		url = blxDefaultURL
 	}
 
-	return &BlxNodeConnectionGRPC{
+	nc := &BlxNodeConnectionGRPC{
 		log:        opts.Log.With("src", common.SourceTagBloxroute),
 		authHeader: opts.AuthHeader,
 		url:        url,
 		srcTag:     common.SourceTagBloxroute,
 		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
 	}
+	nc.sourceSupervisor = newSourceSupervisor(nc.log, nc.srcTag, nc.run)
+	return nc
 }
 
-func (nc *BlxNodeConnectionGRPC) Start() {
-	nc.connect()
-}
-
-func (nc *BlxNodeConnectionGRPC) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting to %s in %s sec ...", nc.srcTag, backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
-	}
-
-	nc.connect()
-}
-
-func (nc *BlxNodeConnectionGRPC) connect() {
+func (nc *BlxNodeConnectionGRPC) run(ctx context.Context, onConnected func()) error {
 	nc.log.Infow("connecting...", "uri", nc.url)
 
 	conn, err := grpc.NewClient(nc.url, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithInitialWindowSize(common.GRPCWindowSize))
 	if err != nil {
-		nc.log.Errorw("failed to connect to bloxroute gRPC, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
-		return
+		return fmt.Errorf("failed to connect to bloxroute gRPC: %w", err)
 	}
+	defer conn.Close()
 
 	client := pb.NewGatewayClient(conn)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	stream, err := client.NewTxs(ctx, &pb.TxsRequest{ //nolint:exhaustruct
 		AuthHeader: nc.authHeader,
 	})
 	if err != nil {
-		nc.log.Errorw("failed to invoke NewTxs stream on bloxroute gRPC client", "error", err)
-		go nc.reconnect()
-		return
+		return fmt.Errorf("failed to invoke NewTxs stream on bloxroute gRPC client: %w", err)
 	}
 
 	defer func() {
@@ -237,14 +239,12 @@ func (nc *BlxNodeConnectionGRPC) connect() {
 	}()
 
 	nc.log.Infow("connection successful", "uri", nc.url)
-	nc.backoffSec = initialBackoffSec // reset backoff timeout
+	onConnected()
 
 	for {
 		msg, err := stream.Recv()
 		if err != nil {
-			nc.log.Errorw("failed to read message from gRPC stream", "error", err)
-			go nc.reconnect()
-			return
+			return fmt.Errorf("failed to read message from gRPC stream: %w", err)
 		}
 
 		for _, tx := range msg.GetTx() {
@@ -257,10 +257,13 @@ func (nc *BlxNodeConnectionGRPC) connect() {
 				continue
 			}
 
-			nc.txC <- common.TxIn{
+			err = nc.sendTx(ctx, nc.txC, common.TxIn{
 				T:      time.Now().UTC(),
 				Tx:     &tx,
 				Source: nc.srcTag,
+			})
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -5,6 +5,7 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	fiber "github.com/chainbound/fiber-go"
@@ -20,13 +21,27 @@ type ChainboundNodeOpts struct {
 	SourceTag string // optional override, default: "Chainbound"
 }
 
+func init() {
+	// chainbound://<api-key>[@<url>]
+	RegisterSource("chainbound", func(opts SourceOpts) (TxSource, error) {
+		apiKey, url := sourceAuthFromURI(opts.URI)
+		return NewChainboundNodeConnection(ChainboundNodeOpts{ //nolint:exhaustruct
+			TxC:    opts.TxC,
+			Log:    opts.Log,
+			APIKey: apiKey,
+			URL:    url,
+		}), nil
+	})
+}
+
 type ChainboundNodeConnection struct {
-	log        *zap.SugaredLogger
-	apiKey     string
-	url        string
-	srcTag     string
-	txC        chan common.TxIn
-	backoffSec int
+	*sourceSupervisor
+
+	log    *zap.SugaredLogger
+	apiKey string
+	url    string
+	srcTag string
+	txC    chan common.TxIn
 }
 
 func NewChainboundNodeConnection(opts ChainboundNodeOpts) *ChainboundNodeConnection {
@@ -40,73 +55,85 @@ func NewChainboundNodeConnection(opts ChainboundNodeOpts) *ChainboundNodeConnect
 		srcTag = common.SourceTagChainbound
 	}
 
-	return &ChainboundNodeConnection{
-		log:        opts.Log.With("src", srcTag),
-		apiKey:     opts.APIKey,
-		url:        url,
-		srcTag:     srcTag,
-		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
+	cbc := &ChainboundNodeConnection{
+		log:    opts.Log.With("src", srcTag),
+		apiKey: opts.APIKey,
+		url:    url,
+		srcTag: srcTag,
+		txC:    opts.TxC,
 	}
+	cbc.sourceSupervisor = newSourceSupervisor(cbc.log, srcTag, cbc.run)
+	return cbc
 }
 
-func (cbc *ChainboundNodeConnection) Start() {
-	cbc.log.Debug("chainbound stream starting...")
-
-	for {
-		// (Re)create incoming-tx channel
-		ch := make(chan *fiber.TransactionWithSender)
-
-		// Fire off connect (will close ch on error)
-		go cbc.connect(ch)
-
-		// Forward transactions to collector
-		for fiberTx := range ch {
-			cbc.txC <- common.TxIn{
-				T:      time.Now().UTC(),
-				Tx:     fiberTx.Transaction,
-				Source: cbc.srcTag,
-			}
-		}
-
-		backoffDuration := time.Duration(cbc.backoffSec) * time.Second
-		cbc.log.Infof("chainbound stream closed, reconnecting in %s sec ...", backoffDuration.String())
-		time.Sleep(backoffDuration)
-		cbc.backoffSec *= 2
-		if cbc.backoffSec > maxBackoffSec {
-			cbc.backoffSec = maxBackoffSec
-		}
-	}
-}
-
-func (cbc *ChainboundNodeConnection) connect(ch chan *fiber.TransactionWithSender) {
+func (cbc *ChainboundNodeConnection) run(ctx context.Context, onConnected func()) error {
 	cbc.log.Infow("connecting...", "uri", cbc.url)
 
 	config := fiber.NewConfig().SetIdleTimeout(10 * time.Second).SetHealthCheckInterval(10 * time.Second)
 
-	client := fiber.NewClientWithConfig(chainboundDefaultURL, cbc.apiKey, config)
+	client := fiber.NewClientWithConfig(cbc.url, cbc.apiKey, config)
 
 	// Connect
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	if err := client.Connect(ctx); err != nil {
-		cbc.log.Errorw("failed to connect to chainbound", "error", err)
-		close(ch)
-		return
+	if err := client.Connect(connectCtx); err != nil {
+		return fmt.Errorf("failed to connect to chainbound: %w", err)
 	}
 
 	// Only close the client when the connection was successful
 	defer client.Close()
 
 	cbc.log.Infow("connection successful", "uri", cbc.url)
-	cbc.backoffSec = initialBackoffSec
-
-	// First make a sink channel on which to receive the transactions
-	// This is a blocking call, so it needs to run in a Goroutine
-	err := client.SubscribeNewTxs(nil, ch)
-	if err != nil {
-		cbc.log.Errorw("chainbound subscription error", "error", err)
-		close(ch)
-		return
+	onConnected()
+
+	// SubscribeNewTxs is blocking, so it runs in a goroutine
+	ch := make(chan *fiber.TransactionWithSender)
+	subErrC := make(chan error, 1)
+	go func() {
+		subErrC <- client.SubscribeNewTxs(nil, ch)
+	}()
+
+	// On return, ch is drained until SubscribeNewTxs ends (once the client is closed), so it isn't blocked on sending
+	defer func() {
+		go func() {
+			for {
+				select {
+				case _, ok := <-ch:
+					if !ok {
+						return
+					}
+				case <-subErrC:
+					return
+				}
+			}
+		}()
+	}()
+
+	// Forward transactions to collector
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-subErrC:
+			if err != nil {
+				return fmt.Errorf("chainbound subscription error: %w", err)
+			}
+			return errSourceStreamClosed
+		case fiberTx, ok := <-ch:
+			if !ok {
+				if err := <-subErrC; err != nil {
+					return fmt.Errorf("chainbound subscription error: %w", err)
+				}
+				return errSourceStreamClosed
+			}
+			err := cbc.sendTx(ctx, cbc.txC, common.TxIn{
+				T:      time.Now().UTC(),
+				Tx:     fiberTx.Transaction,
+				Source: cbc.srcTag,
+			})
+			if err != nil {
+				return err
+			}
+		}
 	}
 }
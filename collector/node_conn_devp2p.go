@@ -0,0 +1,567 @@
+package collector
+
+// Plug into the Ethereum devp2p network as mempool data source (eth/68 peer)
+//
+// The collector joins the network as a regular eth/68 peer, listens for
+// NewPooledTransactionHashes announcements (and full Transactions broadcasts),
+// and fetches unknown transaction bodies via GetPooledTransactions.
+//
+// - https://github.com/ethereum/devp2p/blob/master/caps/eth.md
+//
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/flashbots/mempool-dumpster/metrics"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+const (
+	// devp2pProtocolLength is the number of message codes used by eth/68
+	devp2pProtocolLength = 17
+
+	// devp2pMaxMessageSize is the maximum cap on the size of a protocol message (same as geth)
+	devp2pMaxMessageSize = 10 * 1024 * 1024
+
+	// devp2pMaxTxRequest is the maximum number of hashes per GetPooledTransactions request
+	devp2pMaxTxRequest = 256
+
+	// devp2pHandshakeTimeout is the maximum time for the eth status exchange
+	devp2pHandshakeTimeout = 5 * time.Second
+
+	// devp2pTxCacheSize is the number of recently received transaction bodies shared between peers
+	devp2pTxCacheSize = 32_768
+
+	// devp2pMaxPendingRequests limits the number of in-flight hashes per peer
+	devp2pMaxPendingRequests = 4_096
+
+	// devp2pPendingRequestTimeout is how long a requested hash stays pending, if the peer doesn't deliver it
+	devp2pPendingRequestTimeout = 30 * time.Second
+)
+
+var (
+	errDevP2PNoStatus         = errors.New("first message is not a status message")
+	errDevP2PMsgTooLarge      = errors.New("message too large")
+	errDevP2PNetworkMismatch  = errors.New("network id mismatch")
+	errDevP2PGenesisMismatch  = errors.New("genesis mismatch")
+	errDevP2PVersionMismatch  = errors.New("protocol version mismatch")
+	errDevP2PHandshakeTimeout = errors.New("handshake timeout")
+)
+
+type DevP2PNodeOpts struct {
+	TxC        chan common.TxIn
+	Log        *zap.SugaredLogger
+	Peers      []string // enode URLs of static peers
+	NodeKey    string   // optional hex-encoded secp256k1 private key, default: random key
+	ListenAddr string   // optional, default: no inbound connections (and no discovery)
+	MaxPeers   int      // optional, default: devp2pDefaultMaxPeers
+	Discovery  bool     // use discv4 with mainnet bootnodes to find more peers (requires ListenAddr)
+	SourceTag  string   // optional prefix, default: "devp2p" (common.SourceTagDevP2P)
+}
+
+type DevP2PNodeConnection struct {
+	*sourceSupervisor
+
+	log    *zap.SugaredLogger
+	opts   DevP2PNodeOpts
+	srcTag string
+	txC    chan common.TxIn
+
+	server *p2p.Server
+	ctx    context.Context // cancelled when the source is stopped
+
+	// chain parameters for the status handshake (mainnet)
+	networkID  uint64
+	genesis    ethcommon.Hash
+	forkID     forkid.ID
+	forkFilter forkid.Filter
+
+	// recently received transaction bodies, shared between all peers
+	txCache *lru.Cache[ethcommon.Hash, *types.Transaction]
+
+	// sourceAliases maps enode URLs to source tags
+	sourceAliases map[string]string
+
+	requestID atomic.Uint64
+	peers     atomic.Int64 // number of peers after the status handshake
+
+	// connected peers, for the idle check
+	peerSet     map[*devp2pPeer]struct{}
+	peerSetLock sync.Mutex
+}
+
+func NewDevP2PNodeConnection(opts DevP2PNodeOpts) *DevP2PNodeConnection {
+	srcTag := opts.SourceTag
+	if srcTag == "" {
+		srcTag = common.SourceTagDevP2P
+	}
+
+	genesis := core.DefaultGenesisBlock().ToBlock()
+	nc := &DevP2PNodeConnection{ //nolint:exhaustruct
+		log:    opts.Log.With("src", srcTag),
+		opts:   opts,
+		srcTag: srcTag,
+		txC:    opts.TxC,
+		ctx:    context.Background(),
+
+		networkID:  params.MainnetChainConfig.ChainID.Uint64(),
+		genesis:    genesis.Hash(),
+		forkID:     forkid.NewID(params.MainnetChainConfig, genesis, math.MaxUint64, uint64(time.Now().Unix())), //nolint:gosec
+		forkFilter: forkid.NewStaticFilter(params.MainnetChainConfig, genesis),
+
+		txCache:       lru.NewCache[ethcommon.Hash, *types.Transaction](devp2pTxCacheSize),
+		sourceAliases: common.SourceAliasesFromEnv(),
+		peerSet:       make(map[*devp2pPeer]struct{}),
+	}
+	nc.sourceSupervisor = newSourceSupervisor(nc.log, srcTag, nc.run)
+	return nc
+}
+
+// Status reports the source as subscribed only while at least one peer is connected (it's "connecting" while the p2p
+// server is running without peers), so that /readyz doesn't count a peerless devp2p source as healthy
+func (nc *DevP2PNodeConnection) Status() SourceState {
+	state := nc.sourceSupervisor.Status()
+	if state == SourceStateSubscribed && nc.peers.Load() == 0 {
+		return SourceStateConnecting
+	}
+	return state
+}
+
+func (nc *DevP2PNodeConnection) Health() SourceHealth {
+	health := nc.sourceSupervisor.Health()
+	if health.State == SourceStateSubscribed && nc.peers.Load() == 0 {
+		health.State = SourceStateConnecting
+	}
+	return health
+}
+
+// PeerCount returns the number of connected peers (after the status handshake)
+func (nc *DevP2PNodeConnection) PeerCount() int {
+	return int(nc.peers.Load())
+}
+
+// run starts the p2p server and keeps it running until the source is stopped. Static peers are redialed by the server when they disconnect.
+func (nc *DevP2PNodeConnection) run(ctx context.Context, onConnected func()) error {
+	nc.ctx = ctx
+	if err := nc.startServer(); err != nil {
+		return err
+	}
+	onConnected()
+
+	<-ctx.Done()
+	nc.server.Stop()
+	return ctx.Err()
+}
+
+func (nc *DevP2PNodeConnection) startServer() error {
+	var err error
+	var key *ecdsa.PrivateKey
+	if nc.opts.NodeKey != "" {
+		key, err = crypto.HexToECDSA(nc.opts.NodeKey)
+	} else {
+		key, err = crypto.GenerateKey()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid devp2p node key: %w", err)
+	}
+
+	staticNodes := make([]*enode.Node, 0, len(nc.opts.Peers))
+	for _, url := range nc.opts.Peers {
+		node, err := enode.Parse(enode.ValidSchemes, url)
+		if err != nil {
+			return fmt.Errorf("invalid enode %s: %w", url, err)
+		}
+		staticNodes = append(staticNodes, node)
+	}
+
+	maxPeers := nc.opts.MaxPeers
+	if maxPeers == 0 {
+		maxPeers = devp2pDefaultMaxPeers
+	}
+
+	cfg := p2p.Config{ //nolint:exhaustruct
+		PrivateKey:  key,
+		MaxPeers:    max(maxPeers, len(staticNodes)),
+		Name:        "mempool-dumpster/" + common.Version,
+		StaticNodes: staticNodes,
+		ListenAddr:  nc.opts.ListenAddr,
+		NoDiscovery: !nc.opts.Discovery || nc.opts.ListenAddr == "",
+		Protocols: []p2p.Protocol{{ //nolint:exhaustruct
+			Name:    eth.ProtocolName,
+			Version: eth.ETH68,
+			Length:  devp2pProtocolLength,
+			Run:     nc.runPeer,
+		}},
+	}
+
+	if !cfg.NoDiscovery {
+		cfg.DiscoveryV4 = true
+		for _, url := range params.MainnetBootnodes {
+			node, err := enode.Parse(enode.ValidSchemes, url)
+			if err != nil {
+				return fmt.Errorf("invalid bootnode %s: %w", url, err)
+			}
+			cfg.BootstrapNodes = append(cfg.BootstrapNodes, node)
+		}
+	}
+
+	nc.server = &p2p.Server{Config: cfg} //nolint:exhaustruct
+	nc.log.Infow("starting devp2p server...", "staticPeers", len(staticNodes), "listenAddr", nc.opts.ListenAddr, "discovery", !cfg.NoDiscovery, "forkID", fmt.Sprintf("%x", nc.forkID.Hash))
+	if err := nc.server.Start(); err != nil {
+		return err
+	}
+
+	nc.log.Infow("devp2p server started", "enode", nc.server.Self().URLv4())
+	return nil
+}
+
+// peerSourceTag returns the source tag for a given peer: an alias from SRC_ALIASES (by enode URL) or "<tag>-<short peer id>"
+func (nc *DevP2PNodeConnection) peerSourceTag(peer *p2p.Peer) string {
+	if peer.Node() != nil {
+		if alias, ok := nc.sourceAliases[peer.Node().URLv4()]; ok {
+			return alias
+		}
+	}
+	return fmt.Sprintf("%s-%s", nc.srcTag, peer.ID().TerminalString())
+}
+
+// runPeer is the eth/68 protocol handler, called by the p2p server for every connected peer
+func (nc *DevP2PNodeConnection) runPeer(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+	srcTag := nc.peerSourceTag(peer)
+	log := nc.log.With("peer", srcTag, "name", peer.Name())
+
+	if err := nc.handshake(rw); err != nil {
+		log.Debugw("devp2p handshake failed", "error", err)
+		return err
+	}
+	log.Infow("devp2p peer connected", "remoteAddr", peer.RemoteAddr().String())
+
+	p := &devp2pPeer{ //nolint:exhaustruct
+		nc:          nc,
+		log:         log,
+		peer:        peer,
+		rw:          rw,
+		srcTag:      srcTag,
+		connectedAt: time.Now(),
+		pending:     make(map[ethcommon.Hash]time.Time),
+	}
+	nc.addPeer(p)
+	defer nc.removePeer(p)
+
+	err := p.readLoop()
+	log.Infow("devp2p peer disconnected", "error", err)
+	return err
+}
+
+// handshake exchanges the eth status message and validates the remote status
+func (nc *DevP2PNodeConnection) handshake(rw p2p.MsgReadWriter) error {
+	errC := make(chan error, 2)
+
+	go func() {
+		errC <- p2p.Send(rw, eth.StatusMsg, &eth.StatusPacket{
+			ProtocolVersion: eth.ETH68,
+			NetworkID:       nc.networkID,
+			TD:              new(big.Int), // unknown, we don't track the chain
+			Head:            nc.genesis,
+			Genesis:         nc.genesis,
+			ForkID:          nc.forkID,
+		})
+	}()
+
+	go func() {
+		errC <- nc.readStatus(rw)
+	}()
+
+	timeout := time.NewTimer(devp2pHandshakeTimeout)
+	defer timeout.Stop()
+	for range 2 {
+		select {
+		case err := <-errC:
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return errDevP2PHandshakeTimeout
+		}
+	}
+	return nil
+}
+
+func (nc *DevP2PNodeConnection) readStatus(rw p2p.MsgReadWriter) error {
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Code != eth.StatusMsg {
+		return fmt.Errorf("%w: got code %d", errDevP2PNoStatus, msg.Code)
+	}
+	if msg.Size > devp2pMaxMessageSize {
+		return fmt.Errorf("%w: %d", errDevP2PMsgTooLarge, msg.Size)
+	}
+
+	var status eth.StatusPacket
+	if err := msg.Decode(&status); err != nil {
+		return err
+	}
+	if status.NetworkID != nc.networkID {
+		return fmt.Errorf("%w: %d", errDevP2PNetworkMismatch, status.NetworkID)
+	}
+	if status.ProtocolVersion != eth.ETH68 {
+		return fmt.Errorf("%w: %d", errDevP2PVersionMismatch, status.ProtocolVersion)
+	}
+	if status.Genesis != nc.genesis {
+		return fmt.Errorf("%w: %x", errDevP2PGenesisMismatch, status.Genesis)
+	}
+	return nc.forkFilter(status.ForkID)
+}
+
+func (nc *DevP2PNodeConnection) addPeer(p *devp2pPeer) {
+	nc.peerSetLock.Lock()
+	defer nc.peerSetLock.Unlock()
+	nc.peerSet[p] = struct{}{}
+	nc.peers.Inc()
+}
+
+func (nc *DevP2PNodeConnection) removePeer(p *devp2pPeer) {
+	nc.peerSetLock.Lock()
+	defer nc.peerSetLock.Unlock()
+	delete(nc.peerSet, p)
+	nc.peers.Dec()
+}
+
+// idlePeers returns the peers which haven't sent a transaction for idleTimeout (or since connecting)
+func (nc *DevP2PNodeConnection) idlePeers(idleTimeout time.Duration) []*devp2pPeer {
+	nc.peerSetLock.Lock()
+	defer nc.peerSetLock.Unlock()
+
+	peers := make([]*devp2pPeer, 0)
+	for p := range nc.peerSet {
+		if time.Since(p.lastActivity()) >= idleTimeout {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// HandleIdle disconnects the idle peers, instead of restarting the p2p server with all peers. Static peers are redialed
+// by the server, and discovery finds other peers.
+func (nc *DevP2PNodeConnection) HandleIdle(idleTimeout time.Duration) {
+	for _, p := range nc.idlePeers(idleTimeout) {
+		idle := time.Since(p.lastActivity()).Round(time.Second)
+		metrics.IncSourceStalled(p.srcTag)
+		p.log.Warnw("devp2p peer stalled, disconnecting", "idle", idle.String())
+		p.peer.Disconnect(p2p.DiscUselessPeer)
+	}
+}
+
+// devp2pPeer holds the per-peer state of a connected eth/68 peer
+type devp2pPeer struct {
+	nc     *DevP2PNodeConnection
+	log    *zap.SugaredLogger
+	peer   *p2p.Peer
+	rw     p2p.MsgReadWriter
+	srcTag string
+
+	connectedAt time.Time
+	lastTxTime  atomic.Int64 // unix ms of when the last tx was received from this peer
+
+	// hashes requested from this peer which were not yet delivered, with the time they were announced
+	pending     map[ethcommon.Hash]time.Time
+	pendingLock sync.Mutex
+}
+
+func (p *devp2pPeer) readLoop() error {
+	for {
+		msg, err := p.rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		err = p.handleMsg(msg)
+		_ = msg.Discard()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *devp2pPeer) handleMsg(msg p2p.Msg) error {
+	if msg.Size > devp2pMaxMessageSize {
+		return fmt.Errorf("%w: %d", errDevP2PMsgTooLarge, msg.Size)
+	}
+
+	switch msg.Code {
+	case eth.NewPooledTransactionHashesMsg:
+		var ann eth.NewPooledTransactionHashesPacket
+		if err := msg.Decode(&ann); err != nil {
+			return err
+		}
+		return p.handleAnnouncement(ann.Hashes)
+
+	case eth.PooledTransactionsMsg:
+		var res eth.PooledTransactionsPacket
+		if err := msg.Decode(&res); err != nil {
+			return err
+		}
+		p.handleTransactions(res.PooledTransactionsResponse, true)
+
+	case eth.TransactionsMsg:
+		var txs eth.TransactionsPacket
+		if err := msg.Decode(&txs); err != nil {
+			return err
+		}
+		p.handleTransactions(txs, false)
+
+	// We don't serve any data, but answer requests with empty responses to not get dropped for timeouts
+	case eth.GetPooledTransactionsMsg:
+		var req eth.GetPooledTransactionsPacket
+		if err := msg.Decode(&req); err != nil {
+			return err
+		}
+		return p2p.Send(p.rw, eth.PooledTransactionsMsg, &eth.PooledTransactionsRLPPacket{RequestId: req.RequestId}) //nolint:exhaustruct
+
+	case eth.GetBlockHeadersMsg:
+		var req eth.GetBlockHeadersPacket
+		if err := msg.Decode(&req); err != nil {
+			return err
+		}
+		return p2p.Send(p.rw, eth.BlockHeadersMsg, &eth.BlockHeadersRLPPacket{RequestId: req.RequestId}) //nolint:exhaustruct
+
+	case eth.GetBlockBodiesMsg:
+		var req eth.GetBlockBodiesPacket
+		if err := msg.Decode(&req); err != nil {
+			return err
+		}
+		return p2p.Send(p.rw, eth.BlockBodiesMsg, &eth.BlockBodiesRLPPacket{RequestId: req.RequestId}) //nolint:exhaustruct
+
+	case eth.GetReceiptsMsg:
+		var req eth.GetReceiptsPacket
+		if err := msg.Decode(&req); err != nil {
+			return err
+		}
+		return p2p.Send(p.rw, eth.ReceiptsMsg, &eth.ReceiptsRLPPacket{RequestId: req.RequestId}) //nolint:exhaustruct
+	}
+
+	// all other messages (block announcements, etc.) are ignored
+	return nil
+}
+
+// handleAnnouncement processes announced hashes: known bodies are emitted right away, unknown ones are requested from the peer
+func (p *devp2pPeer) handleAnnouncement(hashes []ethcommon.Hash) error {
+	announcedAt := time.Now().UTC()
+	known, request := p.addPending(hashes, announcedAt)
+	for _, tx := range known {
+		p.sendTx(tx, announcedAt)
+	}
+
+	for len(request) > 0 {
+		n := min(len(request), devp2pMaxTxRequest)
+		err := p2p.Send(p.rw, eth.GetPooledTransactionsMsg, &eth.GetPooledTransactionsPacket{
+			RequestId:                    p.nc.requestID.Inc(),
+			GetPooledTransactionsRequest: request[:n],
+		})
+		if err != nil {
+			return err
+		}
+		request = request[n:]
+	}
+	return nil
+}
+
+// addPending returns the announced hashes whose bodies are already known, and records the others as pending and
+// returns them for requesting (unless already pending). Pending hashes the peer didn't deliver within
+// devp2pPendingRequestTimeout expire, and no more hashes are requested while devp2pMaxPendingRequests are pending.
+func (p *devp2pPeer) addPending(hashes []ethcommon.Hash, now time.Time) (known []*types.Transaction, request []ethcommon.Hash) {
+	p.pendingLock.Lock()
+	defer p.pendingLock.Unlock()
+
+	if len(p.pending)+len(hashes) > devp2pMaxPendingRequests {
+		for hash, t := range p.pending {
+			if now.Sub(t) > devp2pPendingRequestTimeout {
+				delete(p.pending, hash)
+			}
+		}
+	}
+
+	skipped := 0
+	for _, hash := range hashes {
+		if tx, ok := p.nc.txCache.Get(hash); ok {
+			known = append(known, tx)
+			continue
+		}
+		if _, ok := p.pending[hash]; ok {
+			continue
+		}
+		if len(p.pending) >= devp2pMaxPendingRequests {
+			skipped += 1
+			continue
+		}
+		p.pending[hash] = now
+		request = append(request, hash)
+	}
+	if skipped > 0 {
+		p.log.Debugw("too many pending requests, skipping announced hashes", "skipped", skipped)
+	}
+	return known, request
+}
+
+// handleTransactions processes full transaction bodies (either requested, or broadcast by the peer)
+func (p *devp2pPeer) handleTransactions(txs []*types.Transaction, requested bool) {
+	announcedAt := make([]time.Time, len(txs)) // broadcast transactions were never announced
+	if requested {
+		p.pendingLock.Lock()
+		for i, tx := range txs {
+			if tx != nil {
+				announcedAt[i] = p.pending[tx.Hash()]
+				delete(p.pending, tx.Hash())
+			}
+		}
+		p.pendingLock.Unlock()
+	}
+
+	for i, tx := range txs {
+		if tx == nil {
+			continue
+		}
+		p.nc.txCache.Add(tx.Hash(), tx)
+		p.sendTx(tx, announcedAt[i])
+	}
+}
+
+// lastActivity returns the time of the last tx from the peer, or when it connected if it didn't send one yet
+func (p *devp2pPeer) lastActivity() time.Time {
+	if lastTxMs := p.lastTxTime.Load(); lastTxMs > 0 {
+		return time.UnixMilli(lastTxMs)
+	}
+	return p.connectedAt
+}
+
+func (p *devp2pPeer) sendTx(tx *types.Transaction, announcedAt time.Time) {
+	p.lastTxTime.Store(time.Now().UnixMilli())
+	_ = p.nc.sendTx(p.nc.ctx, p.nc.txC, common.TxIn{
+		T:           time.Now().UTC(),
+		Tx:          tx,
+		Source:      p.srcTag,
+		AnnouncedAt: announcedAt,
+	})
+}
@@ -0,0 +1,235 @@
+package collector
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTx(t *testing.T, nonce uint64) *types.Transaction {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	to := ethcommon.HexToAddress("0x0ed1bcc400acd34593451e76f854992198995f52")
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.DynamicFeeTx{ //nolint:exhaustruct
+		ChainID:   big.NewInt(1),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1_000_000_000),
+		GasFeeCap: big.NewInt(20_000_000_000),
+		Gas:       21_000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+// startTestDevP2PPeer connects a simulated remote peer over an in-process message pipe and completes the status handshake
+func startTestDevP2PPeer(t *testing.T, nc *DevP2PNodeConnection, id enode.ID) (remote *p2p.MsgPipeRW, errC chan error) {
+	t.Helper()
+	local, remote := p2p.MsgPipe()
+	t.Cleanup(func() { _ = remote.Close() })
+
+	errC = make(chan error, 1)
+	peer := p2p.NewPeer(id, "test-peer", nil)
+	go func() { errC <- nc.runPeer(peer, local) }()
+
+	// read the collector status
+	msg, err := remote.ReadMsg()
+	require.NoError(t, err)
+	require.Equal(t, uint64(eth.StatusMsg), msg.Code)
+	var status eth.StatusPacket
+	require.NoError(t, msg.Decode(&status))
+	require.Equal(t, nc.genesis, status.Genesis)
+	require.Equal(t, uint64(1), status.NetworkID)
+
+	// reply with our own status
+	err = p2p.Send(remote, eth.StatusMsg, &status)
+	require.NoError(t, err)
+	return remote, errC
+}
+
+func TestDevP2PNodeConnection_AnnounceAndFetch(t *testing.T) {
+	txC := make(chan common.TxIn, 10)
+	nc := NewDevP2PNodeConnection(DevP2PNodeOpts{ //nolint:exhaustruct
+		TxC: txC,
+		Log: common.GetLogger(true, false),
+	})
+
+	tx := newTestTx(t, 0)
+	peerID := enode.ID{1}
+	remote, _ := startTestDevP2PPeer(t, nc, peerID)
+
+	// announce the tx hash
+	err := p2p.Send(remote, eth.NewPooledTransactionHashesMsg, &eth.NewPooledTransactionHashesPacket{
+		Types:  []byte{tx.Type()},
+		Sizes:  []uint32{uint32(tx.Size())}, //nolint:gosec
+		Hashes: []ethcommon.Hash{tx.Hash()},
+	})
+	require.NoError(t, err)
+
+	// collector should request the body
+	msg, err := remote.ReadMsg()
+	require.NoError(t, err)
+	require.Equal(t, uint64(eth.GetPooledTransactionsMsg), msg.Code)
+	var req eth.GetPooledTransactionsPacket
+	require.NoError(t, msg.Decode(&req))
+	require.Equal(t, []ethcommon.Hash{tx.Hash()}, []ethcommon.Hash(req.GetPooledTransactionsRequest))
+
+	// deliver the body
+	err = p2p.Send(remote, eth.PooledTransactionsMsg, &eth.PooledTransactionsPacket{
+		RequestId:                  req.RequestId,
+		PooledTransactionsResponse: []*types.Transaction{tx},
+	})
+	require.NoError(t, err)
+
+	select {
+	case txIn := <-txC:
+		require.Equal(t, tx.Hash(), txIn.Tx.Hash())
+		require.Equal(t, "devp2p-"+peerID.TerminalString(), txIn.Source)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for transaction")
+	}
+
+	// a second peer announcing the same hash is served from the cache, without a request
+	peerID2 := enode.ID{2}
+	remote2, _ := startTestDevP2PPeer(t, nc, peerID2)
+	err = p2p.Send(remote2, eth.NewPooledTransactionHashesMsg, &eth.NewPooledTransactionHashesPacket{
+		Types:  []byte{tx.Type()},
+		Sizes:  []uint32{uint32(tx.Size())}, //nolint:gosec
+		Hashes: []ethcommon.Hash{tx.Hash()},
+	})
+	require.NoError(t, err)
+
+	select {
+	case txIn := <-txC:
+		require.Equal(t, tx.Hash(), txIn.Tx.Hash())
+		require.Equal(t, "devp2p-"+peerID2.TerminalString(), txIn.Source)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for transaction")
+	}
+}
+
+func TestDevP2PNodeConnection_Broadcast(t *testing.T) {
+	txC := make(chan common.TxIn, 10)
+	nc := NewDevP2PNodeConnection(DevP2PNodeOpts{ //nolint:exhaustruct
+		TxC:       txC,
+		Log:       common.GetLogger(true, false),
+		SourceTag: "p2p",
+	})
+
+	tx := newTestTx(t, 1)
+	remote, _ := startTestDevP2PPeer(t, nc, enode.ID{3})
+	err := p2p.Send(remote, eth.TransactionsMsg, eth.TransactionsPacket{tx})
+	require.NoError(t, err)
+
+	txIn := <-txC
+	require.Equal(t, tx.Hash(), txIn.Tx.Hash())
+	require.Equal(t, "p2p-"+enode.ID{3}.TerminalString(), txIn.Source)
+}
+
+func TestDevP2PNodeConnection_HandshakeGenesisMismatch(t *testing.T) {
+	nc := NewDevP2PNodeConnection(DevP2PNodeOpts{ //nolint:exhaustruct
+		TxC: make(chan common.TxIn),
+		Log: common.GetLogger(true, false),
+	})
+
+	local, remote := p2p.MsgPipe()
+	defer remote.Close()
+	errC := make(chan error, 1)
+	go func() { errC <- nc.runPeer(p2p.NewPeer(enode.ID{4}, "test-peer", nil), local) }()
+
+	msg, err := remote.ReadMsg()
+	require.NoError(t, err)
+	var status eth.StatusPacket
+	require.NoError(t, msg.Decode(&status))
+
+	status.Genesis = ethcommon.Hash{0x01}
+	require.NoError(t, p2p.Send(remote, eth.StatusMsg, &status))
+	require.ErrorIs(t, <-errC, errDevP2PGenesisMismatch)
+}
+
+func TestDevP2PNodeConnection_StatusWithoutPeers(t *testing.T) {
+	nc := NewDevP2PNodeConnection(DevP2PNodeOpts{ //nolint:exhaustruct
+		TxC: make(chan common.TxIn, 10),
+		Log: common.GetLogger(true, false),
+	})
+
+	// the p2p server is running, but without peers the source isn't healthy
+	nc.onConnected()
+	require.Equal(t, SourceStateConnecting, nc.Status())
+	require.Equal(t, SourceStateConnecting, nc.Health().State)
+
+	remote, errC := startTestDevP2PPeer(t, nc, enode.ID{1})
+	require.Eventually(t, func() bool { return nc.Status() == SourceStateSubscribed }, time.Second, time.Millisecond)
+	require.Equal(t, 1, nc.PeerCount())
+
+	// the last peer disconnects
+	require.NoError(t, remote.Close())
+	<-errC
+	require.Equal(t, SourceStateConnecting, nc.Status())
+}
+
+func TestDevP2PNodeConnection_idlePeers(t *testing.T) {
+	nc := NewDevP2PNodeConnection(DevP2PNodeOpts{ //nolint:exhaustruct
+		TxC: make(chan common.TxIn, 10),
+		Log: common.GetLogger(true, false),
+	})
+	startTestDevP2PPeer(t, nc, enode.ID{1})
+	require.Eventually(t, func() bool { return nc.PeerCount() == 1 }, time.Second, time.Millisecond)
+
+	// idle since connecting
+	require.Empty(t, nc.idlePeers(time.Hour))
+	idle := nc.idlePeers(0)
+	require.Len(t, idle, 1)
+
+	// a tx from the peer resets the idle time
+	idle[0].connectedAt = time.Now().Add(-2 * time.Hour)
+	require.Len(t, nc.idlePeers(time.Hour), 1)
+	idle[0].sendTx(newTestTx(t, 0), time.Time{})
+	require.Empty(t, nc.idlePeers(time.Hour))
+}
+
+func TestDevP2PPeer_addPending(t *testing.T) {
+	nc := NewDevP2PNodeConnection(DevP2PNodeOpts{ //nolint:exhaustruct
+		TxC: make(chan common.TxIn, 10),
+		Log: common.GetLogger(true, false),
+	})
+	p := &devp2pPeer{nc: nc, log: nc.log, pending: make(map[ethcommon.Hash]time.Time)} //nolint:exhaustruct
+
+	tx := newTestTx(t, 0)
+	nc.txCache.Add(tx.Hash(), tx)
+	t0 := time.Now()
+	hashes := make([]ethcommon.Hash, devp2pMaxPendingRequests)
+	for i := range hashes {
+		hashes[i] = ethcommon.BigToHash(big.NewInt(int64(i + 1)))
+	}
+
+	// known bodies aren't requested
+	known, request := p.addPending(append([]ethcommon.Hash{tx.Hash()}, hashes[:devp2pMaxPendingRequests-1]...), t0)
+	require.Equal(t, []*types.Transaction{tx}, known)
+	require.Len(t, request, devp2pMaxPendingRequests-1)
+
+	// pending hashes aren't requested again, and no more than devp2pMaxPendingRequests are pending
+	newHash := ethcommon.BytesToHash([]byte("new"))
+	_, request = p.addPending([]ethcommon.Hash{hashes[0], hashes[devp2pMaxPendingRequests-1], newHash}, t0.Add(time.Second))
+	require.Equal(t, []ethcommon.Hash{hashes[devp2pMaxPendingRequests-1]}, request)
+	require.Len(t, p.pending, devp2pMaxPendingRequests)
+
+	// the pending hashes expire individually
+	p.pending[hashes[0]] = t0.Add(-devp2pPendingRequestTimeout - time.Second)
+	_, request = p.addPending([]ethcommon.Hash{newHash}, t0.Add(time.Second))
+	require.Equal(t, []ethcommon.Hash{newHash}, request)
+	require.Len(t, p.pending, devp2pMaxPendingRequests)
+	require.NotContains(t, p.pending, hashes[0])
+	require.Contains(t, p.pending, hashes[1])
+}
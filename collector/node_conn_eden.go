@@ -8,6 +8,7 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -29,24 +30,38 @@ type EdenNodeOpts struct {
 	SourceTag  string // optional override, default: "eden" (common.SourceTagEden)
 }
 
-// startEdenConnection starts a Websocket or gRPC subscription (depending on URL) in the background
-func startEdenConnection(opts EdenNodeOpts) {
+func init() {
+	// eden://<auth-header>[@<url>]
+	RegisterSource("eden", func(opts SourceOpts) (TxSource, error) {
+		token, url := sourceAuthFromURI(opts.URI)
+		return NewEdenSource(EdenNodeOpts{ //nolint:exhaustruct
+			TxC:        opts.TxC,
+			Log:        opts.Log,
+			AuthHeader: token,
+			URL:        url,
+		}), nil
+	})
+}
+
+// NewEdenSource returns a Websocket or gRPC connection, depending on the URL
+func NewEdenSource(opts EdenNodeOpts) TxSource {
+	if opts.URL == "" {
+		opts.URL = edenDefaultURL
+	}
 	if common.IsWebsocketProtocol(opts.URL) {
-		edenConn := NewEdenNodeConnection(opts)
-		go edenConn.Start()
-	} else {
-		edenConn := NewEdenNodeConnectionGRPC(opts)
-		go edenConn.Start()
+		return NewEdenNodeConnection(opts)
 	}
+	return NewEdenNodeConnectionGRPC(opts)
 }
 
 type EdenNodeConnection struct {
+	*sourceSupervisor
+
 	log        *zap.SugaredLogger
 	authHeader string
 	url        string
 	srcTag     string
 	txC        chan common.TxIn
-	backoffSec int
 }
 
 func NewEdenNodeConnection(opts EdenNodeOpts) *EdenNodeConnection {
@@ -60,57 +75,40 @@ func NewEdenNodeConnection(opts EdenNodeOpts) *EdenNodeConnection {
 		srcTag = common.SourceTagEden
 	}
 
-	return &EdenNodeConnection{
+	nc := &EdenNodeConnection{
 		log:        opts.Log.With("src", srcTag),
 		authHeader: opts.AuthHeader,
 		url:        url,
 		srcTag:     srcTag,
 		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
-	}
-}
-
-func (nc *EdenNodeConnection) Start() {
-	nc.connect()
-}
-
-func (nc *EdenNodeConnection) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting to %s in %s sec ...", nc.srcTag, backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
 	}
-
-	nc.connect()
+	nc.sourceSupervisor = newSourceSupervisor(nc.log, srcTag, nc.run)
+	return nc
 }
 
 //nolint:dupl
-func (nc *EdenNodeConnection) connect() {
+func (nc *EdenNodeConnection) run(ctx context.Context, onConnected func()) error {
 	nc.log.Infow("connecting...", "uri", nc.url)
 	dialer := websocket.DefaultDialer
-	wsSubscriber, resp, err := dialer.Dial(nc.url, http.Header{"Authorization": []string{nc.authHeader}})
+	wsSubscriber, resp, err := dialer.DialContext(ctx, nc.url, http.Header{"Authorization": []string{nc.authHeader}})
 	if err != nil {
-		nc.log.Errorw("failed to connect to eden, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
-		return
+		return fmt.Errorf("failed to connect to eden: %w", err)
 	}
 	defer wsSubscriber.Close()
 	defer resp.Body.Close()
 
+	// unblock ReadMessage when the source is stopped
+	stop := context.AfterFunc(ctx, func() { _ = wsSubscriber.Close() })
+	defer stop()
+
 	subRequest := `{"jsonrpc": "2.0", "id": 1, "method": "subscribe", "params": ["rawTxs"]}`
 	err = wsSubscriber.WriteMessage(websocket.TextMessage, []byte(subRequest))
 	if err != nil {
-		nc.log.Errorw("failed to subscribe to eden", "error", err)
-		go nc.reconnect()
-		return
+		return fmt.Errorf("failed to subscribe to eden: %w", err)
 	}
 
 	nc.log.Infow("connection successful", "uri", nc.url)
-	nc.backoffSec = initialBackoffSec // reset backoff timeout
+	onConnected()
 
 	for {
 		_, nextNotification, err := wsSubscriber.ReadMessage()
@@ -118,13 +116,9 @@ func (nc *EdenNodeConnection) connect() {
 			// Handle websocket errors, by closing and reconnecting. Errors seen previously:
 			// - "websocket: close 1006 (abnormal closure): unexpected EOF"
 			if strings.Contains(err.Error(), "failed parsing the authorization header") {
-				nc.log.Errorw("invalid eden auth header", "error", err)
-			} else {
-				nc.log.Errorw("failed to read message, reconnecting", "error", err)
+				return fmt.Errorf("invalid eden auth header: %w", err)
 			}
-
-			go nc.reconnect()
-			return
+			return fmt.Errorf("failed to read message: %w", err)
 		}
 
 		// fmt.Println("got message", string(nextNotification))
@@ -154,21 +148,25 @@ func (nc *EdenNodeConnection) connect() {
 			continue
 		}
 
-		nc.txC <- common.TxIn{
+		err = nc.sendTx(ctx, nc.txC, common.TxIn{
 			T:      time.Now().UTC(),
 			Tx:     &tx,
 			Source: nc.srcTag,
+		})
+		if err != nil {
+			return err
 		}
 	}
 }
 
 type EdenNodeConnectionGRPC struct {
+	*sourceSupervisor
+
 	log        *zap.SugaredLogger
 	authHeader string
 	url        string
 	srcTag     string
 	txC        chan common.TxIn
-	backoffSec int
 }
 
 func NewEdenNodeConnectionGRPC(opts EdenNodeOpts) *EdenNodeConnectionGRPC {
@@ -177,55 +175,35 @@ func NewEdenNodeConnectionGRPC(opts EdenNodeOpts) *EdenNodeConnectionGRPC {
 		url = edenDefaultURL
 	}
 
-	return &EdenNodeConnectionGRPC{
+	nc := &EdenNodeConnectionGRPC{
 		log:        opts.Log.With("src", common.SourceTagEden),
 		authHeader: opts.AuthHeader,
 		url:        url,
 		srcTag:     common.SourceTagEden,
 		txC:        opts.TxC,
-		backoffSec: initialBackoffSec,
-	}
-}
-
-func (nc *EdenNodeConnectionGRPC) Start() {
-	nc.connect()
-}
-
-func (nc *EdenNodeConnectionGRPC) reconnect() {
-	backoffDuration := time.Duration(nc.backoffSec) * time.Second
-	nc.log.Infof("reconnecting to %s in %s sec ...", nc.srcTag, backoffDuration.String())
-	time.Sleep(backoffDuration)
-
-	// increase backoff timeout for next try
-	nc.backoffSec *= 2
-	if nc.backoffSec > maxBackoffSec {
-		nc.backoffSec = maxBackoffSec
 	}
-
-	nc.connect()
+	nc.sourceSupervisor = newSourceSupervisor(nc.log, nc.srcTag, nc.run)
+	return nc
 }
 
-func (nc *EdenNodeConnectionGRPC) connect() {
+func (nc *EdenNodeConnectionGRPC) run(ctx context.Context, onConnected func()) error {
 	nc.log.Infow("connecting...", "uri", nc.url)
 
 	conn, err := grpc.NewClient(nc.url, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithInitialWindowSize(common.GRPCWindowSize))
 	if err != nil {
-		nc.log.Errorw("failed to connect to eden gRPC, reconnecting in a bit...", "error", err)
-		go nc.reconnect()
-		return
+		return fmt.Errorf("failed to connect to eden gRPC: %w", err)
 	}
+	defer conn.Close()
 
 	client := pb.NewStreamServiceClient(conn)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	stream, err := client.StreamRawTransactions(ctx, &pb.StreamRawTransactionsRequest{ //nolint:exhaustruct
 		AuthHeader: nc.authHeader,
 	})
 	if err != nil {
-		nc.log.Errorw("failed to invoke StreamRawTransactions stream on eden gRPC client", "error", err)
-		go nc.reconnect()
-		return
+		return fmt.Errorf("failed to invoke StreamRawTransactions stream on eden gRPC client: %w", err)
 	}
 
 	defer func() {
@@ -235,14 +213,12 @@ func (nc *EdenNodeConnectionGRPC) connect() {
 	}()
 
 	nc.log.Infow("connection successful", "uri", nc.url)
-	nc.backoffSec = initialBackoffSec // reset backoff timeout
+	onConnected()
 
 	for {
 		msg, err := stream.Recv()
 		if err != nil {
-			nc.log.Errorw("failed to read message from gRPC stream", "error", err)
-			go nc.reconnect()
-			return
+			return fmt.Errorf("failed to read message from gRPC stream: %w", err)
 		}
 
 		rlp := msg.GetRlp()
@@ -254,10 +230,13 @@ func (nc *EdenNodeConnectionGRPC) connect() {
 			continue
 		}
 
-		nc.txC <- common.TxIn{
+		err = nc.sendTx(ctx, nc.txC, common.TxIn{
 			T:      time.Now().UTC(),
 			Tx:     &tx,
 			Source: nc.srcTag,
+		})
+		if err != nil {
+			return err
 		}
 	}
 }
@@ -0,0 +1,358 @@
+package collector
+
+//
+// OutputWriter writes the output files of a bucket (txs, sourcelog and trash CSVs), optionally compressed.
+//
+// Closing a writer seals the file: the compression stream is finished, the file is fsynced, and a manifest with the
+// row count, sizes and checksum is written next to it (<file>.manifest.json). A file without manifest is incomplete.
+//
+// Sealed files stay sealed: rows of a bucket written after its files were closed (late or replayed transactions) go to
+// a late file next to it (<name>_late<N>.csv[.gz|.zst]). An unsealed file which is opened again (i.e. after a restart
+// within the same bucket) is continued with a new compression stream, after a partially written tail (i.e. from a
+// crash) was cut off. The existing file is read as a stream, and only rewritten if it has such a tail.
+//
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression of the output files
+const (
+	OutputCompressionNone = "none"
+	OutputCompressionGzip = "gzip"
+	OutputCompressionZstd = "zstd"
+)
+
+var OutputCompressions = []string{OutputCompressionNone, OutputCompressionGzip, OutputCompressionZstd}
+
+type OutputWriter interface {
+	io.Writer
+	Name() string
+
+	// Flush writes buffered compressed data to the file, so it's readable up to here after a crash
+	Flush() error
+
+	// Close seals the file, see OutputManifest
+	Close() error
+}
+
+// OutputManifest is written next to an output file when it's sealed
+type OutputManifest struct {
+	File        string    `json:"file"`
+	Compression string    `json:"compression"`
+	Rows        int64     `json:"rows"`
+	Bytes       int64     `json:"bytes"`     // uncompressed
+	FileBytes   int64     `json:"fileBytes"` // on disk
+	SHA256      string    `json:"sha256"`    // of the file on disk
+	SealedAt    time.Time `json:"sealedAt"`
+}
+
+// outputFileExtension returns the extension appended to ".csv" for a compression
+func outputFileExtension(compression string) string {
+	switch compression {
+	case OutputCompressionGzip:
+		return ".gz"
+	case OutputCompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+func outputManifestFilename(fn string) string {
+	return fn + ".manifest.json"
+}
+
+type fileOutputWriter struct {
+	lock        sync.Mutex
+	fn          string
+	f           *os.File
+	compression string
+	hash        hash.Hash      // checksum of the bytes written to the file
+	enc         io.WriteCloser // compression stream (nil if uncompressed)
+	w           io.Writer      // enc, or the file
+	rows        int64
+	bytes       int64
+	closed      bool
+}
+
+// openOutputWriter opens an output file for appending, and continues an existing file if it's not sealed yet. If it
+// is sealed, the writer appends to its first unsealed late file instead (see Name).
+func openOutputWriter(fn, compression string) (*fileOutputWriter, error) {
+	fn, err := unsealedOutputFilename(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	scan, err := scanOutputFile(fn, compression)
+	if err != nil {
+		return nil, err
+	}
+	if !scan.complete {
+		return rewriteOutputFile(fn, compression, scan)
+	}
+
+	f, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newFileOutputWriter(fn, f, compression, scan.hash)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w.rows = scan.rows
+	w.bytes = scan.bytes
+	return w, nil
+}
+
+// lateOutputFilename returns the name of the n-th late file of an output file (i.e. txs_<bucket>_<uid>_late1.csv.zst)
+func lateOutputFilename(fn string, n int) string {
+	i := strings.LastIndex(fn, ".csv")
+	if i < 0 {
+		i = len(fn)
+	}
+	return fmt.Sprintf("%s_late%d%s", fn[:i], n, fn[i:])
+}
+
+// unsealedOutputFilename returns the file, or its first late file which isn't sealed yet
+func unsealedOutputFilename(fn string) (string, error) {
+	candidate := fn
+	for n := 1; ; n++ {
+		_, err := os.Stat(outputManifestFilename(candidate))
+		if errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+		candidate = lateOutputFilename(fn, n)
+	}
+}
+
+// outputFileScan is the state of an existing output file
+type outputFileScan struct {
+	hash     hash.Hash // checksum of the file
+	rows     int64     // complete lines
+	bytes    int64     // uncompressed size of the complete lines
+	complete bool      // false if the file ends with a partially written line or compression stream
+}
+
+// scanOutputFile reads an existing output file as a stream (a missing file is empty)
+func scanOutputFile(fn, compression string) (*outputFileScan, error) {
+	scan := &outputFileScan{hash: sha256.New(), complete: true} //nolint:exhaustruct
+	f, err := os.Open(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return scan, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil || stat.Size() == 0 {
+		return scan, err
+	}
+
+	in := io.TeeReader(f, scan.hash)
+	r, err := newOutputReader(in, compression)
+	if err != nil {
+		scan.complete = false // i.e. a truncated compression header
+		return scan, nil
+	}
+	defer r.Close()
+
+	var total int64
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		for i, b := range buf[:n] {
+			if b == '\n' {
+				scan.rows += 1
+				scan.bytes = total + int64(i) + 1
+			}
+		}
+		total += int64(n)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			scan.complete = false // truncated compression stream
+			return scan, nil
+		}
+	}
+	scan.complete = total == scan.bytes
+
+	// the checksum covers the whole file, also if the decoder didn't read up to the end
+	_, err = io.Copy(io.Discard, in)
+	return scan, err
+}
+
+// rewriteOutputFile replaces the file with its complete lines, and returns a writer to continue it
+func rewriteOutputFile(fn, compression string, scan *outputFileScan) (*fileOutputWriter, error) {
+	src, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmpFn := fn + ".tmp"
+	f, err := os.OpenFile(tmpFn, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newFileOutputWriter(fn, f, compression, sha256.New())
+	if err == nil && scan.bytes > 0 {
+		var r io.ReadCloser
+		r, err = newOutputReader(src, compression)
+		if err == nil {
+			_, err = io.CopyN(w, r, scan.bytes)
+			_ = r.Close()
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = os.Rename(tmpFn, fn)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpFn)
+		return nil, err
+	}
+	return w, nil
+}
+
+// newFileOutputWriter returns a writer which starts a new compression stream at the end of f. The hash continues the
+// checksum of the existing content of the file.
+func newFileOutputWriter(fn string, f *os.File, compression string, h hash.Hash) (*fileOutputWriter, error) {
+	w := &fileOutputWriter{ //nolint:exhaustruct
+		fn:          fn,
+		f:           f,
+		compression: compression,
+		hash:        h,
+	}
+	out := io.MultiWriter(f, w.hash)
+
+	var err error
+	switch compression {
+	case OutputCompressionGzip:
+		w.enc = gzip.NewWriter(out)
+	case OutputCompressionZstd:
+		w.enc, err = zstd.NewWriter(out)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w.w = out
+	if w.enc != nil {
+		w.w = w.enc
+	}
+	return w, nil
+}
+
+// newOutputReader returns a reader of the uncompressed content of an output file
+func newOutputReader(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case OutputCompressionGzip:
+		return gzip.NewReader(r)
+	case OutputCompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+func (w *fileOutputWriter) Name() string {
+	return w.fn
+}
+
+func (w *fileOutputWriter) Write(p []byte) (n int, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	n, err = w.w.Write(p)
+	w.rows += int64(bytes.Count(p[:n], []byte("\n")))
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *fileOutputWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (w *fileOutputWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.closed = true
+
+	var errs []error
+	if w.enc != nil {
+		errs = append(errs, w.enc.Close())
+	}
+	errs = append(errs, w.f.Sync())
+	stat, err := w.f.Stat()
+	errs = append(errs, err, w.f.Close())
+	if err = errors.Join(errs...); err != nil {
+		return err
+	}
+
+	return writeOutputManifest(w.fn, OutputManifest{
+		File:        filepath.Base(w.fn),
+		Compression: w.compression,
+		Rows:        w.rows,
+		Bytes:       w.bytes,
+		FileBytes:   stat.Size(),
+		SHA256:      hex.EncodeToString(w.hash.Sum(nil)),
+		SealedAt:    time.Now().UTC(),
+	})
+}
+
+func writeOutputManifest(fn string, manifest OutputManifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	manifestFn := outputManifestFilename(fn)
+	tmpFn := manifestFn + ".tmp"
+	err = os.WriteFile(tmpFn, append(content, '\n'), 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFn, manifestFn)
+}
@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func readOutputManifest(t *testing.T, fn string) OutputManifest {
+	t.Helper()
+	content, err := os.ReadFile(outputManifestFilename(fn))
+	require.NoError(t, err)
+	var manifest OutputManifest
+	require.NoError(t, json.Unmarshal(content, &manifest))
+	return manifest
+}
+
+func TestOutputWriter(t *testing.T) {
+	for _, compression := range OutputCompressions {
+		t.Run(compression, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "txs_2023-08-07_10-00_test.csv"+outputFileExtension(compression))
+
+			w, err := openOutputWriter(fn, compression)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("1,0xaa,rlp1\n2,0xbb,rlp2\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Flush())
+			require.NoError(t, w.Close())
+			require.ErrorIs(t, w.Close(), os.ErrClosed)
+
+			// sealed with manifest
+			content, err := os.ReadFile(fn)
+			require.NoError(t, err)
+			checksum := sha256.Sum256(content)
+			manifest := readOutputManifest(t, fn)
+			require.Equal(t, filepath.Base(fn), manifest.File)
+			require.Equal(t, int64(2), manifest.Rows)
+			require.Equal(t, int64(24), manifest.Bytes)
+			require.Equal(t, int64(len(content)), manifest.FileBytes)
+			require.Equal(t, hex.EncodeToString(checksum[:]), manifest.SHA256)
+
+			// reopening a sealed file writes to a late file, and keeps the file sealed
+			w, err = openOutputWriter(fn, compression)
+			require.NoError(t, err)
+			lateFn := lateOutputFilename(fn, 1)
+			require.Equal(t, lateFn, w.Name())
+			_, err = w.Write([]byte("3,0xcc,rlp3\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			require.Equal(t, manifest, readOutputManifest(t, fn))
+			require.Equal(t, int64(1), readOutputManifest(t, lateFn).Rows)
+
+			rows, err := common.GetCSV(lateFn)
+			require.NoError(t, err)
+			require.Equal(t, [][]string{{"3", "0xcc", "rlp3"}}, rows)
+
+			// the next late file once the first one is sealed
+			w, err = openOutputWriter(fn, compression)
+			require.NoError(t, err)
+			require.Equal(t, lateOutputFilename(fn, 2), w.Name())
+			require.NoError(t, w.Close())
+		})
+	}
+}
+
+func TestOutputWriter_continue(t *testing.T) {
+	for _, compression := range OutputCompressions {
+		t.Run(compression, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "txs_2023-08-07_10-00_test.csv"+outputFileExtension(compression))
+
+			// restart without sealing the file (the compression stream is finished)
+			w, err := openOutputWriter(fn, compression)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("1,0xaa,rlp1\n2,0xbb,rlp2\n"))
+			require.NoError(t, err)
+			if w.enc != nil {
+				require.NoError(t, w.enc.Close())
+			}
+			require.NoError(t, w.f.Close())
+
+			// reopening continues the file with a new compression stream
+			w, err = openOutputWriter(fn, compression)
+			require.NoError(t, err)
+			require.Equal(t, fn, w.Name())
+			_, err = w.Write([]byte("3,0xcc,rlp3\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			content, err := os.ReadFile(fn)
+			require.NoError(t, err)
+			checksum := sha256.Sum256(content)
+			manifest := readOutputManifest(t, fn)
+			require.Equal(t, int64(3), manifest.Rows)
+			require.Equal(t, int64(36), manifest.Bytes)
+			require.Equal(t, hex.EncodeToString(checksum[:]), manifest.SHA256)
+
+			rows, err := common.GetCSV(fn)
+			require.NoError(t, err)
+			require.Len(t, rows, 3)
+			require.Equal(t, "0xcc", rows[2][1])
+		})
+	}
+}
+
+func TestOutputWriter_truncated(t *testing.T) {
+	for _, compression := range []string{OutputCompressionNone, OutputCompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			fn := filepath.Join(t.TempDir(), "src_2023-08-07_10-00_test.csv"+outputFileExtension(compression))
+
+			// crash after flushing, in the middle of a line (the compression stream is not finished)
+			w, err := openOutputWriter(fn, compression)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("1,0xaa,source1\n2,0xbb,sou"))
+			require.NoError(t, err)
+			require.NoError(t, w.Flush())
+			require.NoError(t, w.f.Close())
+
+			// the incomplete line is cut off when reopening
+			w, err = openOutputWriter(fn, compression)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("3,0xcc,source1\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			require.Equal(t, int64(2), readOutputManifest(t, fn).Rows)
+
+			rows, err := common.GetCSV(fn)
+			require.NoError(t, err)
+			require.Equal(t, [][]string{{"1", "0xaa", "source1"}, {"3", "0xcc", "source1"}}, rows)
+		})
+	}
+}
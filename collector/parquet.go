@@ -0,0 +1,256 @@
+package collector
+
+//
+// Per-bucket parquet file (--write-parquet).
+//
+// The transactions of a bucket and their sources are appended to a spool file (<file>.spool.csv) as they arrive, so
+// they are on disk before the bucket is closed (also without the txs CSV), and only a small index is kept in memory.
+// The parquet file is written from the spool when the bucket is closed, and the spool is removed afterwards. After a
+// restart within the bucket, the index is loaded from the spool again (a partially written last line is cut off).
+//
+// A written parquet file isn't changed anymore: transactions of the bucket which arrive after it was closed (late or
+// replayed transactions) go to a late file next to it (<name>_late<N>.parquet).
+//
+// Spool lines: <tx_hash>,<source>,<timestamp_ms>,<raw_tx_hex> (first seen) or <tx_hash>,<source> (another source)
+//
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"go.uber.org/zap"
+)
+
+// parquetBucket collects the unique transactions of a bucket, with all sources seen so far, and writes them to a
+// parquet file (same schema as the merged parquet files) when the bucket is closed
+type parquetBucket struct {
+	log           *zap.SugaredLogger
+	fn            string
+	schemaVersion int
+
+	lock      sync.Mutex
+	spool     *os.File // nil once closed
+	spoolSize int64
+	txs       map[string]*parquetTx // lowercase hash -> tx
+}
+
+// parquetTx is a transaction of the bucket, its raw tx is read from the spool file when the parquet file is written
+type parquetTx struct {
+	timestamp int64
+	hash      string
+	sources   []string
+	offset    int64 // position of the raw tx hex in the spool file
+	size      int
+}
+
+// lateParquetFilename returns the name of the n-th late file of a parquet file (i.e. txs_<bucket>_<uid>_late1.parquet)
+func lateParquetFilename(fn string, n int) string {
+	return fmt.Sprintf("%s_late%d.parquet", strings.TrimSuffix(fn, ".parquet"), n)
+}
+
+// openParquetBucket opens the parquet file of a bucket, or its first late file if it was already written. The
+// transactions of an existing spool file (i.e. written before a restart within the bucket) are loaded.
+func openParquetBucket(log *zap.SugaredLogger, fn string, schemaVersion int) (*parquetBucket, error) {
+	candidate := fn
+	for n := 1; ; n++ {
+		_, err := os.Stat(candidate)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		_ = os.Remove(candidate + ".spool.csv") // the file was written, but the spool not removed anymore
+		candidate = lateParquetFilename(fn, n)
+	}
+
+	spool, err := os.OpenFile(candidate+".spool.csv", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	b := &parquetBucket{ //nolint:exhaustruct
+		log:           log,
+		fn:            candidate,
+		schemaVersion: schemaVersion,
+		spool:         spool,
+		txs:           make(map[string]*parquetTx),
+	}
+	if err = b.loadSpool(); err != nil {
+		_ = spool.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// loadSpool loads the index from the spool file, and cuts off a partially written last line
+func (b *parquetBucket) loadSpool() error {
+	r := bufio.NewReader(b.spool)
+	for {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			break // without newline, the line is incomplete
+		} else if err != nil {
+			return err
+		}
+
+		fields := strings.SplitN(strings.TrimSuffix(line, "\n"), ",", 4)
+		switch len(fields) {
+		case 2:
+			b.addSource(fields[0], fields[1])
+		case 4:
+			timestamp, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				b.log.Warnw("invalid parquet spool line", "file", b.spool.Name(), "error", err)
+				break
+			}
+			b.txs[fields[0]] = &parquetTx{
+				timestamp: timestamp,
+				hash:      fields[0],
+				sources:   []string{fields[1]},
+				offset:    b.spoolSize + int64(len(line)-1-len(fields[3])),
+				size:      len(fields[3]),
+			}
+		default:
+			b.log.Warnw("invalid parquet spool line", "file", b.spool.Name(), "line", line)
+		}
+		b.spoolSize += int64(len(line))
+	}
+	return b.spool.Truncate(b.spoolSize)
+}
+
+// appendSpool writes a line to the spool file, and returns its position
+func (b *parquetBucket) appendSpool(line string) (int64, error) {
+	if b.spool == nil {
+		return 0, os.ErrClosed
+	}
+	offset := b.spoolSize
+	n, err := b.spool.WriteString(line)
+	b.spoolSize += int64(n)
+	return offset, err
+}
+
+// Add adds a transaction the first time it is seen
+func (b *parquetBucket) Add(txIn common.TxIn) error {
+	if txIn.Tx.ChainId().Sign() <= 0 {
+		return common.ErrChainIDNotSet
+	}
+	rlpHex, err := common.TxToRLPString(txIn.Tx)
+	if err != nil {
+		return err
+	}
+	txHashLower := strings.ToLower(txIn.Tx.Hash().Hex())
+	prefix := fmt.Sprintf("%s,%s,%d,", txHashLower, txIn.Source, txIn.T.UnixMilli())
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	offset, err := b.appendSpool(prefix + rlpHex + "\n")
+	if err != nil {
+		return err
+	}
+	b.txs[txHashLower] = &parquetTx{
+		timestamp: txIn.T.UnixMilli(),
+		hash:      txHashLower,
+		sources:   []string{txIn.Source},
+		offset:    offset + int64(len(prefix)),
+		size:      len(rlpHex),
+	}
+	return nil
+}
+
+// AddSource records another source of a transaction (sources are kept in the order they delivered the tx)
+func (b *parquetBucket) AddSource(txHashLower, source string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	tx, ok := b.txs[txHashLower]
+	if !ok || slices.Contains(tx.sources, source) {
+		return nil
+	}
+	_, err := b.appendSpool(txHashLower + "," + source + "\n")
+	if err != nil {
+		return err
+	}
+	b.addSource(txHashLower, source)
+	return nil
+}
+
+func (b *parquetBucket) addSource(txHashLower, source string) {
+	if tx, ok := b.txs[txHashLower]; ok && !slices.Contains(tx.sources, source) {
+		tx.sources = append(tx.sources, source)
+	}
+}
+
+// Close writes the parquet file (to a temporary file first, so a file is always complete), and removes the spool file.
+// If writing fails, the spool file is kept.
+func (b *parquetBucket) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.spool == nil {
+		return os.ErrClosed
+	}
+	spool := b.spool
+	b.spool = nil
+	defer spool.Close()
+
+	txs := make([]*parquetTx, 0, len(b.txs))
+	for _, tx := range b.txs {
+		txs = append(txs, tx)
+	}
+	slices.SortFunc(txs, func(a, b *parquetTx) int {
+		return cmp.Or(cmp.Compare(a.timestamp, b.timestamp), cmp.Compare(a.hash, b.hash))
+	})
+
+	tmpFn := b.fn + ".tmp"
+	pw, err := common.NewTxParquetWriter(tmpFn, b.schemaVersion)
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err = b.writeTx(pw, spool, tx); err != nil {
+			break
+		}
+	}
+	err = errors.Join(err, pw.Close())
+	if err == nil {
+		err = syncFile(tmpFn)
+	}
+	if err != nil {
+		_ = os.Remove(tmpFn)
+		return err
+	}
+	if err = os.Rename(tmpFn, b.fn); err != nil {
+		return err
+	}
+	return os.Remove(spool.Name())
+}
+
+// writeTx reads the raw tx from the spool file, and writes the tx to the parquet file
+func (b *parquetBucket) writeTx(pw *common.TxParquetWriter, spool *os.File, tx *parquetTx) error {
+	rlpHex := make([]byte, tx.size)
+	_, err := spool.ReadAt(rlpHex, tx.offset)
+	if err != nil {
+		return err
+	}
+	summary, _, err := common.ParseTxRLP(tx.timestamp, string(rlpHex))
+	if err != nil {
+		b.log.Warnw("failed to parse tx for parquet", "hash", tx.hash, "error", err)
+		return nil
+	}
+	summary.Sources = tx.sources
+	return pw.Write(&summary)
+}
+
+func syncFile(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	return errors.Join(f.Sync(), f.Close())
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParquetBucket(t *testing.T) {
+	log := common.GetLogger(true, false)
+	fn := filepath.Join(t.TempDir(), "txs_2023-08-07_10-00_test.parquet")
+	now := time.Now()
+	tx1, tx2 := newTestTx(t, 0), newTestTx(t, 1)
+	hash1, hash2 := strings.ToLower(tx1.Hash().Hex()), strings.ToLower(tx2.Hash().Hex())
+
+	b, err := openParquetBucket(log, fn, common.ParquetSchemaV1)
+	require.NoError(t, err)
+	require.NoError(t, b.Add(common.TxIn{T: now, Tx: tx1, Source: "source1"})) //nolint:exhaustruct
+	require.NoError(t, b.AddSource(hash1, "source2"))
+	require.NoError(t, b.Add(common.TxIn{T: now.Add(time.Millisecond), Tx: tx2, Source: "source2"})) //nolint:exhaustruct
+
+	// crash in the middle of a line: the transactions are in the spool file, and the incomplete line is cut off
+	_, err = b.spool.WriteString(hash2 + ",sou")
+	require.NoError(t, err)
+	require.NoError(t, b.spool.Close())
+	require.NoFileExists(t, fn)
+
+	b, err = openParquetBucket(log, fn, common.ParquetSchemaV1)
+	require.NoError(t, err)
+	require.Len(t, b.txs, 2)
+	require.Equal(t, []string{"source1", "source2"}, b.txs[hash1].sources)
+	require.NoError(t, b.AddSource(hash2, "source3"))
+	require.NoError(t, b.Close())
+	require.ErrorIs(t, b.Close(), os.ErrClosed)
+	require.NoFileExists(t, fn+".spool.csv")
+
+	txs, err := common.LoadTransactionParquetFiles(log, []string{fn})
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	require.Equal(t, []string{"source1", "source2"}, txs[hash1].Sources)
+	require.Equal(t, []string{"source2", "source3"}, txs[hash2].Sources)
+	require.Equal(t, now.UnixMilli(), txs[hash1].Timestamp)
+
+	// a written parquet file isn't changed anymore, later transactions go to a late file
+	b, err = openParquetBucket(log, fn, common.ParquetSchemaV1)
+	require.NoError(t, err)
+	require.Equal(t, lateParquetFilename(fn, 1), b.fn)
+	require.Empty(t, b.txs)
+	require.NoError(t, b.Close())
+}
@@ -0,0 +1,233 @@
+package collector
+
+//
+// ReplaySource re-emits transactions from previously recorded files (txs_*.csv, src_*.csv or merged parquet files),
+// with the original timestamps and relative pacing. This allows reproducing incidents, load-testing receivers and the
+// SSE API, and regression-testing the validation and trash classification without any live providers.
+//
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/flashbots/mempool-dumpster/common"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+const replaySourceName = "replay" // source tag of replayed transactions without recorded source
+
+var errUnknownReplayFile = errors.New("unknown replay file (expected txs_*.csv, src_*.csv or *.parquet)")
+
+type ReplaySourceOpts struct {
+	TxC   chan common.TxIn
+	Log   *zap.SugaredLogger
+	Files []string // txs_*.csv, src_*.csv (or .csv.zip) and merged *.parquet files
+	Speed float64  // pacing speedup relative to the recording (1 = original pace, 0 = as fast as possible)
+}
+
+type ReplaySource struct {
+	*sourceSupervisor
+
+	log   *zap.SugaredLogger
+	txC   chan common.TxIn
+	files []string
+	speed float64
+
+	events []common.TxIn // loaded on the first connection, sorted by time
+	pos    int           // next event, so a reconnect resumes instead of starting over
+	done   chan struct{}
+
+	clock atomic.Int64 // unix ns of the last replayed tx
+}
+
+func NewReplaySource(opts ReplaySourceOpts) *ReplaySource {
+	r := &ReplaySource{ //nolint:exhaustruct
+		log:   opts.Log.With("src", replaySourceName),
+		txC:   opts.TxC,
+		files: opts.Files,
+		speed: opts.Speed,
+		done:  make(chan struct{}),
+	}
+	r.sourceSupervisor = newSourceSupervisor(r.log, replaySourceName, r.run)
+	return r
+}
+
+// IdleExempt keeps the watchdog from reconnecting the replay (recordings can have longer gaps, and a replay isn't
+// restarted anyway)
+func (r *ReplaySource) IdleExempt() bool {
+	return true
+}
+
+// Now is the replay clock: the time of the last replayed transaction while the replay is running, and the wall clock
+// before and after. The collector's housekeeping uses it, so a replay of old data doesn't continuously expire the known
+// transactions and close the files of the replayed buckets.
+func (r *ReplaySource) Now() time.Time {
+	if clock := r.clock.Load(); clock > 0 && !r.isFinished() {
+		return time.Unix(0, clock)
+	}
+	return time.Now()
+}
+
+// Done is closed once all transactions were replayed
+func (r *ReplaySource) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *ReplaySource) run(ctx context.Context, onConnected func()) error {
+	if r.events == nil {
+		events, err := loadReplayEvents(r.log, r.files)
+		if err != nil {
+			return err
+		}
+		r.events = events
+		r.log.Infow("Loaded replay files", "files", len(r.files), "txs", common.Printer.Sprint(len(r.events)), "speed", r.speed)
+	}
+	onConnected()
+
+	if !r.isFinished() {
+		err := r.replay(ctx)
+		if err != nil {
+			return err
+		}
+		close(r.done)
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (r *ReplaySource) isFinished() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// replay sends the remaining events, paced relative to the first one
+func (r *ReplaySource) replay(ctx context.Context) error {
+	if r.pos >= len(r.events) {
+		return nil
+	}
+
+	timeStarted := time.Now()
+	recordingStart := r.events[r.pos].T
+	for ; r.pos < len(r.events); r.pos++ {
+		txIn := r.events[r.pos]
+
+		// wait until the tx is due
+		if r.speed > 0 {
+			due := time.Duration(float64(txIn.T.Sub(recordingStart)) / r.speed)
+			if wait := due - time.Since(timeStarted); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		if err := r.sendTx(ctx, r.txC, txIn); err != nil {
+			return err
+		}
+		r.clock.Store(txIn.T.UnixNano())
+		if (r.pos+1)%100_000 == 0 {
+			r.log.Infow("Replay progress", "txs", common.Printer.Sprintf("%d / %d", r.pos+1, len(r.events)))
+		}
+	}
+	r.log.Infow("Replay finished", "txs", common.Printer.Sprint(len(r.events)), "timeTaken", time.Since(timeStarted).String())
+	return nil
+}
+
+// loadReplayEvents returns the transactions to replay, sorted by time. With sourcelog files, there is one event per
+// recorded (tx, source) pair, otherwise one per tx (and source, for parquet files).
+func loadReplayEvents(log *zap.SugaredLogger, files []string) ([]common.TxIn, error) {
+	var txFiles, sourcelogFiles, parquetFiles []string
+	for _, fn := range files {
+		base := filepath.Base(fn)
+		switch {
+		case strings.HasSuffix(base, ".parquet"):
+			parquetFiles = append(parquetFiles, fn)
+		case strings.HasPrefix(base, "src_"):
+			sourcelogFiles = append(sourcelogFiles, fn)
+		case strings.HasPrefix(base, "txs_"):
+			txFiles = append(txFiles, fn)
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnknownReplayFile, fn)
+		}
+	}
+
+	txs, err := common.LoadTransactionCSVFiles(log, txFiles, nil)
+	if err != nil {
+		return nil, err
+	}
+	parquetTxs, err := common.LoadTransactionParquetFiles(log, parquetFiles)
+	if err != nil {
+		return nil, err
+	}
+	for hash, entry := range parquetTxs {
+		txs[hash] = entry
+	}
+
+	decoded := make(map[string]*types.Transaction, len(txs))
+	for hash, entry := range txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary([]byte(entry.RawTx)); err != nil {
+			log.Errorw("failed to decode transaction", "hash", hash, "error", err)
+			continue
+		}
+		decoded[hash] = tx
+	}
+
+	events := make([]common.TxIn, 0, len(decoded))
+	if len(sourcelogFiles) > 0 {
+		sourcelog, announcements, _ := common.LoadSourcelogFilesWithAnnouncements(log, sourcelogFiles)
+		cntMissing := 0
+		for hash, sources := range sourcelog {
+			tx, ok := decoded[hash]
+			if !ok {
+				cntMissing += 1 // i.e. trash transactions, which are not in the transactions files
+				continue
+			}
+			for source, ts := range sources {
+				txIn := common.TxIn{T: time.UnixMilli(ts).UTC(), Tx: tx, Source: source} //nolint:exhaustruct
+				if announcedMs, ok := announcements[hash][source]; ok {
+					txIn.AnnouncedAt = time.UnixMilli(announcedMs).UTC()
+				}
+				events = append(events, txIn)
+			}
+		}
+		if cntMissing > 0 {
+			log.Warnw("Skipping sourcelog entries without transaction", "txs", common.Printer.Sprint(cntMissing))
+		}
+	} else {
+		for hash, tx := range decoded {
+			sources := txs[hash].Sources
+			if len(sources) == 0 {
+				sources = []string{replaySourceName}
+			}
+			for _, source := range sources {
+				events = append(events, common.TxIn{T: time.UnixMilli(txs[hash].Timestamp).UTC(), Tx: tx, Source: source}) //nolint:exhaustruct
+			}
+		}
+	}
+
+	// sort by time, and make the order of simultaneous events deterministic
+	slices.SortFunc(events, func(a, b common.TxIn) int {
+		return cmp.Or(
+			a.T.Compare(b.T),
+			cmp.Compare(a.Tx.Hash().Hex(), b.Tx.Hash().Hex()),
+			cmp.Compare(a.Source, b.Source),
+		)
+	})
+	return events, nil
+}
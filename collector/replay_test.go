@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaySource(t *testing.T) {
+	dir := t.TempDir()
+	tx1, tx2 := newTestTx(t, 0), newTestTx(t, 1)
+	hash1, hash2 := strings.ToLower(tx1.Hash().Hex()), strings.ToLower(tx2.Hash().Hex())
+	rlp1, err := common.TxToRLPString(tx1)
+	require.NoError(t, err)
+	rlp2, err := common.TxToRLPString(tx2)
+	require.NoError(t, err)
+
+	// recording: tx1 from two sources (one with announcement), tx2 200ms later, and a sourcelog entry without tx
+	t0 := int64(1_700_000_000_000)
+	txsFn := filepath.Join(dir, "txs_2023-11-14_22-00_test.csv")
+	srcFn := filepath.Join(dir, "src_2023-11-14_22-00_test.csv")
+	txs := fmt.Sprintf("%d,%s,%s\n%d,%s,%s\n", t0, hash1, rlp1, t0+200, hash2, rlp2)
+	src := fmt.Sprintf("%d,%s,source1\n%d,%s,source2,%d\n%d,%s,source1\n%d,0x%064x,source1\n", t0, hash1, t0+10, hash1, t0+5, t0+200, hash2, t0+300, 1)
+	require.NoError(t, os.WriteFile(txsFn, []byte(txs), 0o600))
+	require.NoError(t, os.WriteFile(srcFn, []byte(src), 0o600))
+
+	txC := make(chan common.TxIn, 10)
+	r := NewReplaySource(ReplaySourceOpts{
+		TxC:   txC,
+		Log:   common.GetLogger(true, false),
+		Files: []string{txsFn, srcFn},
+		Speed: 2,
+	})
+	timeStarted := time.Now()
+	r.Start()
+	defer r.Stop()
+
+	select {
+	case <-r.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay didn't finish")
+	}
+	require.GreaterOrEqual(t, time.Since(timeStarted), 100*time.Millisecond) // 200ms recording at 2x speed
+	require.Len(t, txC, 3)
+
+	txIn := <-txC
+	require.Equal(t, "source1", txIn.Source)
+	require.Equal(t, hash1, strings.ToLower(txIn.Tx.Hash().Hex()))
+	require.Equal(t, t0, txIn.T.UnixMilli())
+	require.True(t, txIn.AnnouncedAt.IsZero())
+
+	txIn = <-txC
+	require.Equal(t, "source2", txIn.Source)
+	require.Equal(t, t0+10, txIn.T.UnixMilli())
+	require.Equal(t, t0+5, txIn.AnnouncedAt.UnixMilli())
+
+	txIn = <-txC
+	require.Equal(t, hash2, strings.ToLower(txIn.Tx.Hash().Hex()))
+	require.Equal(t, t0+200, txIn.T.UnixMilli())
+}
+
+func TestReplaySource_Now(t *testing.T) {
+	dir := t.TempDir()
+	tx1, tx2 := newTestTx(t, 0), newTestTx(t, 1)
+	rlp1, err := common.TxToRLPString(tx1)
+	require.NoError(t, err)
+	rlp2, err := common.TxToRLPString(tx2)
+	require.NoError(t, err)
+
+	t0 := int64(1_700_000_000_000)
+	txsFn := filepath.Join(dir, "txs_2023-11-14_22-00_test.csv")
+	txs := fmt.Sprintf("%d,%s,%s\n%d,%s,%s\n", t0, strings.ToLower(tx1.Hash().Hex()), rlp1, t0+200, strings.ToLower(tx2.Hash().Hex()), rlp2)
+	require.NoError(t, os.WriteFile(txsFn, []byte(txs), 0o600))
+
+	txC := make(chan common.TxIn) // unbuffered, so the replay waits for every tx to be read
+	r := NewReplaySource(ReplaySourceOpts{
+		TxC:   txC,
+		Log:   common.GetLogger(true, false),
+		Files: []string{txsFn},
+		Speed: 0,
+	})
+
+	// wall clock before the replay
+	require.WithinDuration(t, time.Now(), r.Now(), time.Minute)
+	r.Start()
+	defer r.Stop()
+
+	// the time of the last replayed tx while the replay runs
+	<-txC
+	require.Eventually(t, func() bool { return r.Now().UnixMilli() == t0 }, time.Second, time.Millisecond)
+
+	// and the wall clock again once finished
+	<-txC
+	<-r.Done()
+	require.WithinDuration(t, time.Now(), r.Now(), time.Minute)
+}
@@ -0,0 +1,313 @@
+package collector
+
+//
+// TxSource is the common interface of all mempool data sources (nodes, bloXroute, Eden, Chainbound, devp2p).
+//
+// Each source only implements a single connection attempt (run), and embeds a sourceSupervisor which takes care of
+// the lifecycle: connecting in the background, reconnecting with exponential backoff, and stopping.
+//
+// Sources are created from URIs through a registry keyed by URI scheme (i.e. "wss://node:8546" or "bloxroute://<auth>").
+// Adding a provider means adding one file, which registers its scheme in init().
+//
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"go.uber.org/zap"
+)
+
+var (
+	errSourceStreamClosed = errors.New("stream closed")
+	errSourceStalled      = errors.New("source stalled")
+	errUnknownSource      = errors.New("unknown source scheme")
+)
+
+type SourceState string
+
+const (
+	SourceStateConnecting SourceState = "connecting"
+	SourceStateSubscribed SourceState = "subscribed"
+	SourceStateBackingOff SourceState = "backing-off" // waiting for the backoff timeout after an error
+	SourceStateStopped    SourceState = "stopped"
+)
+
+// SourceHealth is the connection health of a source, as reported on the /sources endpoint
+type SourceHealth struct {
+	Name       string      `json:"name"`
+	State      SourceState `json:"state"`
+	StateSince time.Time   `json:"stateSince"`
+	LastTxTime *time.Time  `json:"lastTxTime"` // nil if no tx was received yet
+	Reconnects uint64      `json:"reconnects"`
+	LastError  string      `json:"lastError,omitempty"`
+}
+
+type TxSource interface {
+	// Name returns the source tag, as used in the sourcelog (i.e. "bloxroute" or "ws://localhost:8546")
+	Name() string
+
+	// Start connects in the background, and keeps reconnecting until Stop is called
+	Start()
+
+	// Stop closes the connection, and waits for the background goroutine to exit
+	Stop()
+
+	Status() SourceState
+	LastError() error
+	Health() SourceHealth
+
+	// Reconnect closes the current connection (recording reason as last error), and reconnects after the backoff timeout
+	Reconnect(reason error)
+}
+
+// SourceOpts are the options passed to a source factory
+type SourceOpts struct {
+	TxC chan common.TxIn
+	Log *zap.SugaredLogger
+	URI string
+
+	// TrackAnnouncements records when a tx hash was first announced, for sources supporting hash subscriptions
+	TrackAnnouncements bool
+}
+
+type SourceFactory func(opts SourceOpts) (TxSource, error)
+
+var (
+	sourceRegistryLock sync.RWMutex
+	sourceRegistry     = make(map[string]SourceFactory)
+)
+
+// RegisterSource registers a factory for source URIs with the given scheme (i.e. "wss" or "bloxroute")
+func RegisterSource(scheme string, factory SourceFactory) {
+	sourceRegistryLock.Lock()
+	defer sourceRegistryLock.Unlock()
+	sourceRegistry[scheme] = factory
+}
+
+// RegisteredSourceSchemes returns all URI schemes with a registered source
+func RegisteredSourceSchemes() []string {
+	sourceRegistryLock.RLock()
+	defer sourceRegistryLock.RUnlock()
+	schemes := make([]string, 0, len(sourceRegistry))
+	for scheme := range sourceRegistry {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// NewSource creates a source from a URI, using the factory registered for its scheme. URIs without a scheme are IPC paths.
+func NewSource(opts SourceOpts) (TxSource, error) {
+	scheme, _, found := strings.Cut(opts.URI, "://")
+	if !found {
+		scheme = "ipc"
+	}
+
+	sourceRegistryLock.RLock()
+	factory, ok := sourceRegistry[scheme]
+	sourceRegistryLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownSource, scheme)
+	}
+	return factory(opts)
+}
+
+// sourceAuthFromURI returns the auth token and optional URL of provider URIs like "bloxroute://<token>[@<url>]"
+func sourceAuthFromURI(uri string) (token, url string) {
+	_, auth, _ := strings.Cut(uri, "://")
+	return common.GetAuthTokenAndURL(auth)
+}
+
+// sourceConnectFunc runs a single connection, until it fails or ctx is cancelled. It calls onConnected once the
+// subscription is established.
+type sourceConnectFunc func(ctx context.Context, onConnected func()) error
+
+// sourceSupervisor runs a source connection in the background, and reconnects with exponential backoff
+type sourceSupervisor struct {
+	log     *zap.SugaredLogger
+	name    string
+	connect sourceConnectFunc
+
+	lock       sync.RWMutex
+	state      SourceState
+	stateSince time.Time
+	lastErr    error
+	reconnects uint64
+	backoffSec int
+	cancel     context.CancelFunc
+	done       chan struct{}
+	connCancel context.CancelCauseFunc // cancels the current connection attempt
+
+	lastTxTime atomic.Int64 // unix ms of when the last tx was received
+}
+
+func newSourceSupervisor(log *zap.SugaredLogger, name string, connect sourceConnectFunc) *sourceSupervisor {
+	return &sourceSupervisor{ //nolint:exhaustruct
+		log:        log,
+		name:       name,
+		connect:    connect,
+		state:      SourceStateStopped,
+		stateSince: time.Now().UTC(),
+		backoffSec: initialBackoffSec,
+	}
+}
+
+func (s *sourceSupervisor) Name() string {
+	return s.name
+}
+
+func (s *sourceSupervisor) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cancel != nil {
+		return // already running
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.loop(ctx, s.done)
+}
+
+func (s *sourceSupervisor) Stop() {
+	s.lock.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.lock.Unlock()
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+func (s *sourceSupervisor) Status() SourceState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.state
+}
+
+func (s *sourceSupervisor) LastError() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.lastErr
+}
+
+func (s *sourceSupervisor) Health() SourceHealth {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	health := SourceHealth{
+		Name:       s.name,
+		State:      s.state,
+		StateSince: s.stateSince,
+		LastTxTime: nil,
+		Reconnects: s.reconnects,
+		LastError:  "",
+	}
+	if lastTxMs := s.lastTxTime.Load(); lastTxMs > 0 {
+		t := time.UnixMilli(lastTxMs).UTC()
+		health.LastTxTime = &t
+	}
+	if s.lastErr != nil {
+		health.LastError = s.lastErr.Error()
+	}
+	return health
+}
+
+func (s *sourceSupervisor) Reconnect(reason error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.connCancel != nil {
+		s.connCancel(reason)
+	}
+}
+
+func (s *sourceSupervisor) setState(state SourceState) {
+	s.lock.Lock()
+	s.setStateLocked(state)
+	s.lock.Unlock()
+}
+
+func (s *sourceSupervisor) setStateLocked(state SourceState) {
+	s.state = state
+	s.stateSince = time.Now().UTC()
+}
+
+// onConnected marks the source as subscribed, and resets the backoff timeout
+func (s *sourceSupervisor) onConnected() {
+	s.lock.Lock()
+	s.setStateLocked(SourceStateSubscribed)
+	s.backoffSec = initialBackoffSec
+	s.lock.Unlock()
+}
+
+func (s *sourceSupervisor) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	defer s.setState(SourceStateStopped)
+
+	for {
+		connCtx, connCancel := context.WithCancelCause(ctx)
+		s.lock.Lock()
+		s.setStateLocked(SourceStateConnecting)
+		s.connCancel = connCancel
+		s.lock.Unlock()
+
+		err := s.connect(connCtx, s.onConnected)
+		if ctx.Err() != nil {
+			connCancel(nil)
+			s.log.Infow("source stopped")
+			return
+		}
+		if cause := context.Cause(connCtx); cause != nil {
+			err = cause // closed through Reconnect
+		} else if err == nil {
+			err = errSourceStreamClosed
+		}
+		connCancel(nil)
+
+		s.lock.Lock()
+		s.setStateLocked(SourceStateBackingOff)
+		s.connCancel = nil
+		s.lastErr = err
+		s.reconnects += 1
+		backoffDuration := time.Duration(s.backoffSec) * time.Second
+
+		// increase backoff timeout for next try
+		s.backoffSec *= 2
+		if s.backoffSec > maxBackoffSec {
+			s.backoffSec = maxBackoffSec
+		}
+		s.lock.Unlock()
+
+		s.log.Errorw("source connection failed, reconnecting in a bit...", "error", err, "backoff", backoffDuration.String())
+		select {
+		case <-ctx.Done():
+			s.log.Infow("source stopped")
+			return
+		case <-time.After(backoffDuration):
+		}
+	}
+}
+
+// sendTx hands a transaction to the collector (unless the source is stopped), and records the time of the last tx
+func (s *sourceSupervisor) sendTx(ctx context.Context, txC chan common.TxIn, txIn common.TxIn) error {
+	s.lastTxTime.Store(time.Now().UnixMilli())
+	if err := ctx.Err(); err != nil {
+		return err // stopped, the collector may already be draining txC
+	}
+	select {
+	case txC <- txIn:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
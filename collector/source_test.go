@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSource(t *testing.T) {
+	opts := SourceOpts{ //nolint:exhaustruct
+		TxC: make(chan common.TxIn),
+		Log: common.GetLogger(true, false),
+	}
+
+	opts.URI = "ws://localhost:8546"
+	source, err := NewSource(opts)
+	require.NoError(t, err)
+	require.IsType(t, &NodeConnection{}, source) //nolint:exhaustruct
+	require.Equal(t, "ws://localhost:8546", source.Name())
+	require.Equal(t, SourceStateStopped, source.Status())
+
+	opts.URI = "/tmp/geth.ipc"
+	source, err = NewSource(opts)
+	require.NoError(t, err)
+	require.IsType(t, &NodeConnection{}, source) //nolint:exhaustruct
+
+	opts.URI = "bloxroute://token"
+	source, err = NewSource(opts)
+	require.NoError(t, err)
+	require.IsType(t, &BlxNodeConnection{}, source) //nolint:exhaustruct
+	require.Equal(t, "token", source.(*BlxNodeConnection).authHeader)
+
+	opts.URI = "eden://token@eden.example.com:443"
+	source, err = NewSource(opts)
+	require.NoError(t, err)
+	require.IsType(t, &EdenNodeConnectionGRPC{}, source) //nolint:exhaustruct
+	require.Equal(t, "eden.example.com:443", source.(*EdenNodeConnectionGRPC).url)
+
+	opts.URI = "chainbound://key"
+	source, err = NewSource(opts)
+	require.NoError(t, err)
+	require.IsType(t, &ChainboundNodeConnection{}, source) //nolint:exhaustruct
+
+	opts.URI = "foo://bar"
+	_, err = NewSource(opts)
+	require.ErrorIs(t, err, errUnknownSource)
+}
+
+func TestSourceSupervisor(t *testing.T) {
+	errTest := errors.New("test error")
+	attempts := make(chan int, 10)
+	cnt := 0
+	s := newSourceSupervisor(common.GetLogger(true, false), "test", func(ctx context.Context, onConnected func()) error {
+		cnt += 1
+		attempts <- cnt
+		if cnt == 1 {
+			return errTest // first attempt fails
+		}
+		onConnected()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s.backoffSec = 0
+
+	s.Start()
+	require.Equal(t, 1, <-attempts)
+	require.Equal(t, 2, <-attempts)
+	require.Eventually(t, func() bool { return s.Status() == SourceStateSubscribed }, time.Second, 10*time.Millisecond)
+	require.ErrorIs(t, s.LastError(), errTest)
+
+	s.Stop()
+	require.Equal(t, SourceStateStopped, s.Status())
+	require.Len(t, attempts, 0)
+}
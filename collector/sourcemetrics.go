@@ -13,6 +13,7 @@ const (
 	KeyStatsUnique    = "unique"
 	KeyStatsTxOnChain = "tx-onchain"
 	KeyStatsTxTrash   = "tx-trash"
+	KeyStatsTxDropped = "tx-dropped"
 )
 
 type SourceMetrics struct {
@@ -1,14 +1,16 @@
 package collector
 
 import (
+	"cmp"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
-	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -43,6 +45,14 @@ type TxProcessorOpts struct {
 	HTTPReceivers           []string
 	ReceiversAllowedSources []string
 	APIServer               *api.Server
+	TxCacheFile             string // if set, known transactions are persisted to this file and reloaded on start
+	OverflowPolicy          string // what to do with incoming transactions when the processing queue is full (default: block)
+	SpillDir                string // directory for the disk queue of the spill overflow policy
+	OutputCompression       string // compression of the output files: none (default), gzip or zstd
+	NoTxsCSV                bool   // don't write the txs_*.csv files (i.e. if only writing parquet)
+	WriteParquet            bool   // write a txs_*.parquet file per bucket, with all sources seen so far (spooled to disk, written when the bucket is closed)
+	ParquetSchemaVersion    int    // schema version of the parquet files (default: common.ParquetSchemaV1)
+	WriteBlobSidecars       bool   // write the sidecars of blob transactions to blobs_*.csv (one row per blob)
 }
 
 type TxProcessor struct {
@@ -51,8 +61,31 @@ type TxProcessor struct {
 	uid      string
 	location string
 
-	outDir string
-	txC    chan common.TxIn // note: it's important that the value is sent in here instead of a pointer, otherwise there are memory race conditions
+	outDir            string
+	outputCompression string
+	writeTxsCSV       bool
+	writeParquet      bool
+	parquetSchemaVer  int
+	writeBlobSidecars bool
+	txC               chan common.TxIn // note: it's important that the value is sent in here instead of a pointer, otherwise there are memory race conditions
+
+	// queueC holds the transactions waiting for processTx, the overflow policy applies when it's full
+	queueC         chan common.TxIn
+	overflowPolicy string
+	spillDir       string
+	spill          *spillQueue
+
+	// workers process unique transactions (validation, inclusion check, tx file and Clickhouse writes), sharded by tx hash
+	workerC []chan txJob
+
+	// used to drain the pipeline on shutdown, stage by stage
+	ingestStop       chan struct{} // ends the ingest loop without closing txC (sources may still send)
+	ingestDone       chan struct{}
+	receiverLoopDone chan struct{}
+	spillDrainStop   chan struct{}
+	spillDrainDone   chan struct{}
+	workersWg        sync.WaitGroup
+	receiversWg      sync.WaitGroup // in-flight sendTxToReceivers calls
 
 	outFilesLock sync.RWMutex
 	outFiles     map[int64]OutFiles
@@ -60,26 +93,81 @@ type TxProcessor struct {
 	knownTxs     map[string]time.Time
 	knownTxsLock sync.RWMutex
 
+	txCacheFn   string
+	txCacheFile *txCacheFile
+
 	txCnt      atomic.Uint64
 	srcMetrics SourceMetrics
 
 	checkNodeURI string
 	ethClient    *ethclient.Client
 
-	receivers                []TxReceiver
-	receiversAllowedSources  []string
-	receiversAllowAllSources bool
+	receivers               []TxReceiver
+	receiversAllowedSources *common.SourceAllowList
 
 	lastHealthCheckCall time.Time
 
+	// now is the clock of the housekeeper (expiring known txs, closing old files), the replay clock during a replay
+	now func() time.Time
+
 	clickhouseDSN string
 	clickhouse    *Clickhouse
 }
 
+// txJob is a transaction handed from the receiver loop to a worker
+type txJob struct {
+	txIn        common.TxIn
+	txHashLower string
+	outFiles    OutFiles
+}
+
 type OutFiles struct {
-	FTxs       *os.File
-	FSourcelog *os.File
-	FTrash     *os.File
+	FTxs       OutputWriter // nil if not writing the txs CSV
+	FSourcelog OutputWriter
+	FTrash     OutputWriter
+	FBlobs     OutputWriter   // nil if not writing blob sidecars
+	Parquet    *parquetBucket // nil if not writing parquet
+
+	// refs counts the transactions of the bucket that are still being written (i.e. queued for a worker). The
+	// housekeeper only closes the files when it's 0.
+	refs *atomic.Int64
+}
+
+// release drops a reference taken by getOutputCSVFiles
+func (f OutFiles) release() {
+	if f.refs != nil {
+		f.refs.Dec()
+	}
+}
+
+func (f OutFiles) writers() (writers []OutputWriter) {
+	for _, w := range []OutputWriter{f.FTxs, f.FSourcelog, f.FTrash, f.FBlobs} {
+		if w != nil {
+			writers = append(writers, w)
+		}
+	}
+	return writers
+}
+
+// Flush writes buffered data of all files to disk
+func (f OutFiles) Flush() error {
+	var errs []error
+	for _, w := range f.writers() {
+		errs = append(errs, w.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+// Close seals all files (fsync, and manifest with row count and checksum), and writes the parquet file
+func (f OutFiles) Close() error {
+	var errs []error
+	for _, w := range f.writers() {
+		errs = append(errs, w.Close())
+	}
+	if f.Parquet != nil {
+		errs = append(errs, f.Parquet.Close())
+	}
+	return errors.Join(errs...)
 }
 
 func NewTxProcessor(opts TxProcessorOpts) *TxProcessor {
@@ -94,33 +182,124 @@ func NewTxProcessor(opts TxProcessorOpts) *TxProcessor {
 		receivers = append(receivers, opts.APIServer)
 	}
 
+	workerC := make([]chan txJob, max(txProcessorWorkers, 1))
+	for i := range workerC {
+		workerC[i] = make(chan txJob, txProcessorWorkerQueueSize)
+	}
+
 	return &TxProcessor{ //nolint:exhaustruct
-		log: opts.Log,
-		txC: make(chan common.TxIn, 100),
+		log:     opts.Log,
+		txC:     make(chan common.TxIn, 100),
+		workerC: workerC,
+
+		queueC:         make(chan common.TxIn, 100),
+		overflowPolicy: opts.OverflowPolicy,
+		spillDir:       opts.SpillDir,
+
+		ingestStop:       make(chan struct{}),
+		ingestDone:       make(chan struct{}),
+		receiverLoopDone: make(chan struct{}),
+		spillDrainStop:   make(chan struct{}),
+		spillDrainDone:   make(chan struct{}),
 
 		uid:      opts.UID,
 		location: opts.Location,
 
-		outDir:   opts.OutDir,
-		outFiles: make(map[int64]OutFiles),
+		outDir:            opts.OutDir,
+		outputCompression: opts.OutputCompression,
+		writeTxsCSV:       !opts.NoTxsCSV,
+		writeParquet:      opts.WriteParquet,
+		parquetSchemaVer:  cmp.Or(opts.ParquetSchemaVersion, common.ParquetSchemaV1),
+		writeBlobSidecars: opts.WriteBlobSidecars,
+		outFiles:          make(map[int64]OutFiles),
 
 		knownTxs:   make(map[string]time.Time),
+		txCacheFn:  opts.TxCacheFile,
 		srcMetrics: NewMetricsCounter(),
 
 		checkNodeURI:  opts.CheckNodeURI,
 		clickhouseDSN: opts.ClickhouseDSN,
 
-		receivers:                receivers,
-		receiversAllowedSources:  opts.ReceiversAllowedSources,
-		receiversAllowAllSources: len(opts.ReceiversAllowedSources) == 1 && opts.ReceiversAllowedSources[0] == "all",
+		receivers:               receivers,
+		receiversAllowedSources: common.NewSourceAllowList(opts.ReceiversAllowedSources),
+
+		now: time.Now,
 	}
 }
 
-func (p *TxProcessor) Shutdown() {
+// Shutdown drains all queued transactions, flushes the receivers and Clickhouse, and syncs and closes all files.
+// All sources must be stopped before, because txC is closed. Returns an error if ctx is done before everything is flushed.
+func (p *TxProcessor) Shutdown(ctx context.Context) error {
+	return p.shutdown(ctx, true)
+}
+
+// shutdown drains and flushes the pipeline. If closeIngest is false (a source may still send), txC isn't closed, and
+// only the transactions already in it are processed.
+func (p *TxProcessor) shutdown(ctx context.Context, closeIngest bool) error {
 	p.log.Info("Shutting down TxProcessor ...")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.drain(closeIngest)
+		p.flush()
+	}()
+
+	select {
+	case <-done:
+		p.log.Info("TxProcessor shut down")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("TxProcessor shutdown: %w", ctx.Err())
+	}
+}
+
+// drain processes all transactions still in the pipeline, in the order of the stages
+func (p *TxProcessor) drain(closeIngest bool) {
+	if closeIngest {
+		close(p.txC)
+	} else {
+		close(p.ingestStop)
+	}
+	<-p.ingestDone
+
+	// remaining spilled transactions stay on disk, and are processed on the next start
+	if p.spill != nil {
+		close(p.spillDrainStop)
+		<-p.spillDrainDone
+		p.spill.Close()
+		p.log.Infow("Spilled transactions left on disk", "dir", p.spillDir, "spilledTxs", common.Printer.Sprint(p.spill.Len()))
+	}
+
+	close(p.queueC)
+	<-p.receiverLoopDone
+
+	for _, c := range p.workerC {
+		close(c)
+	}
+	p.workersWg.Wait()
+	p.receiversWg.Wait()
+}
+
+// flush writes out Clickhouse batches, and syncs and closes all output files
+func (p *TxProcessor) flush() {
 	if p.clickhouse != nil {
 		p.clickhouse.FlushCurrentBatches()
 	}
+
+	p.outFilesLock.Lock()
+	for timestamp, outFiles := range p.outFiles {
+		if err := outFiles.Close(); err != nil {
+			p.log.Errorw("failed to close output files", "timestamp", timestamp, "error", err)
+		}
+		delete(p.outFiles, timestamp)
+	}
+	p.outFilesLock.Unlock()
+
+	if p.txCacheFile != nil {
+		if err := p.txCacheFile.Close(); err != nil {
+			p.log.Errorw("failed to close tx cache file", "error", err)
+		}
+	}
 }
 
 func (p *TxProcessor) Start() {
@@ -155,11 +334,37 @@ func (p *TxProcessor) Start() {
 		}
 	}
 
+	// Write the parquet files of buckets which ended while not running (i.e. after a crash)
+	if p.writeParquet && p.outDir != "" {
+		p.writeLeftoverParquetSpools()
+	}
+
+	// Reload the known transactions from a previous run
+	if p.txCacheFn != "" {
+		p.txCacheFile, p.knownTxs, err = openTxCacheFile(p.txCacheFn)
+		if err != nil {
+			p.log.Fatalw("failed to open tx cache file", "file", p.txCacheFn, "error", err)
+		}
+		p.log.Infow("Loaded tx cache file", "file", p.txCacheFn, "knownTxs", common.Printer.Sprint(len(p.knownTxs)))
+	}
+
 	// start the txn map cleaner background task
 	go p.startHousekeeper()
 
-	// start listening for transactions coming in through the channel
+	// Pick up transactions spilled to disk in a previous run
+	if p.overflowPolicy == OverflowPolicySpill {
+		p.spill, err = newSpillQueue(p.spillDir)
+		if err != nil {
+			p.log.Fatalw("failed to open spill directory", "dir", p.spillDir, "error", err)
+		}
+		p.log.Infow("Using spill directory", "dir", p.spillDir, "spilledTxs", common.Printer.Sprint(p.spill.Len()))
+		go p.startSpillDrainLoop()
+	}
+
+	// start the workers, and listening for transactions coming in through the channel
+	p.startWorkers()
 	go p.startTransactionReceiverLoop()
+	go p.startIngestLoop()
 
 	time.Sleep(100 * time.Millisecond) // give some time for the goroutine to start
 	p.log.Info("TxProcessor started successfully")
@@ -167,17 +372,43 @@ func (p *TxProcessor) Start() {
 
 func (p *TxProcessor) startTransactionReceiverLoop() {
 	p.log.Info("Waiting for transactions...")
-	for txIn := range p.txC {
-		if txIn.Tx == nil {
-			continue
-		}
+	defer close(p.receiverLoopDone)
+	for txIn := range p.queueC {
 		p.processTx(txIn)
 	}
 }
 
+// startWorkers starts one goroutine per worker queue, and registers the queue depth metrics
+func (p *TxProcessor) startWorkers() {
+	metrics.RegisterQueueDepth("ingest", func() int { return len(p.txC) })
+	metrics.RegisterQueueDepth("queue", func() int { return len(p.queueC) })
+	metrics.RegisterQueueDepth("spill", func() int { return p.spill.Len() })
+	metrics.RegisterQueueDepth("workers", func() (n int) {
+		for _, c := range p.workerC {
+			n += len(c)
+		}
+		return n
+	})
+
+	for _, c := range p.workerC {
+		p.workersWg.Add(1)
+		go func(c chan txJob) {
+			defer p.workersWg.Done()
+			for job := range c {
+				p.processTxJob(job)
+			}
+		}(c)
+	}
+}
+
+// workerFor returns the worker queue for a transaction. The same hash always goes to the same worker, which avoids processing a tx twice.
+func (p *TxProcessor) workerFor(tx *types.Transaction) chan txJob {
+	h := tx.Hash()
+	return p.workerC[binary.BigEndian.Uint64(h[:8])%uint64(len(p.workerC))]
+}
+
 func (p *TxProcessor) sendTxToReceivers(txIn common.TxIn) {
-	txAllowed := p.receiversAllowAllSources || slices.Contains(p.receiversAllowedSources, txIn.Source)
-	if !txAllowed {
+	if !p.receiversAllowedSources.Allows(txIn.Source) {
 		return
 	}
 
@@ -198,6 +429,7 @@ func (p *TxProcessor) sendTxToReceivers(txIn common.TxIn) {
 	wg.Wait()
 }
 
+// processTx is the hot path for every received transaction: it counts it and writes the sourcelog, and then hands it to a worker
 func (p *TxProcessor) processTx(txIn common.TxIn) {
 	tx := txIn.Tx
 	txHashLower := strings.ToLower(tx.Hash().Hex())
@@ -220,33 +452,51 @@ func (p *TxProcessor) processTx(txIn common.TxIn) {
 			log.Errorw("getOutputFiles", "error", err)
 			return
 		} else if isCreated {
-			p.log.Infof("new file created: %s", outFiles.FTxs.Name())
-			p.log.Infof("new file created: %s", outFiles.FSourcelog.Name())
-			p.log.Infof("new file created: %s", outFiles.FTrash.Name())
+			for _, w := range outFiles.writers() {
+				p.log.Infof("new file created: %s", w.Name())
+			}
 		}
 
-		// write sourcelog
-		_, err = fmt.Fprintf(outFiles.FSourcelog, "%d,%s,%s\n", txIn.T.UnixMilli(), txHashLower, txIn.Source)
+		// write sourcelog (with announcement timestamp as 4th column, if known)
+		if txIn.AnnouncedAt.IsZero() {
+			_, err = fmt.Fprintf(outFiles.FSourcelog, "%d,%s,%s\n", txIn.T.UnixMilli(), txHashLower, txIn.Source)
+		} else {
+			_, err = fmt.Fprintf(outFiles.FSourcelog, "%d,%s,%s,%d\n", txIn.T.UnixMilli(), txHashLower, txIn.Source, txIn.AnnouncedAt.UnixMilli())
+		}
 		if err != nil {
 			log.Errorw("fmt.Fprintf", "error", err)
+			outFiles.release()
 			return
 		}
 	}
 
 	if p.clickhouse != nil {
-		p.clickhouse.AddSourceLog(txIn.T, txHashLower, txIn.Source, p.location)
+		p.clickhouse.AddSourceLog(txIn.T, txIn.AnnouncedAt, txHashLower, txIn.Source, p.location)
 	}
 
+	// the worker releases the reference to the output files
+	p.workerFor(tx) <- txJob{txIn: txIn, txHashLower: txHashLower, outFiles: outFiles}
+}
+
+// processTxJob runs in a worker, and processes a transaction the first time it is seen
+func (p *TxProcessor) processTxJob(job txJob) {
+	txIn, txHashLower, outFiles := job.txIn, job.txHashLower, job.outFiles
+	defer outFiles.release()
+	tx := txIn.Tx
+	log := p.log.With("tx_hash", txHashLower).With("source", txIn.Source)
+
 	// Process transactions only once
 	p.knownTxsLock.RLock()
-	_, isTxKnown := p.knownTxs[txHashLower]
+	firstSeen, isTxKnown := p.knownTxs[txHashLower]
 	p.knownTxsLock.RUnlock()
 	if isTxKnown {
+		p.addParquetSource(firstSeen, txHashLower, txIn.Source)
 		return
 	}
 
 	// Sanity check transaction
-	if err = p.validateTx(txIn); err != nil {
+	err := p.validateTx(txIn)
+	if err != nil {
 		p.writeInvalidTx(outFiles.FTrash, txIn, err)
 		metrics.IncTxReceivedTrash(txIn.Source)
 		p.srcMetrics.Inc(KeyStatsTxTrash, txIn.Source)
@@ -254,7 +504,11 @@ func (p *TxProcessor) processTx(txIn common.TxIn) {
 	}
 
 	// Send tx to receivers
-	go p.sendTxToReceivers(txIn)
+	p.receiversWg.Add(1)
+	go func() {
+		defer p.receiversWg.Done()
+		p.sendTxToReceivers(txIn)
+	}()
 
 	// Check if tx was already included
 	if p.ethClient != nil {
@@ -291,7 +545,7 @@ func (p *TxProcessor) processTx(txIn common.TxIn) {
 	metrics.IncTxReceivedFirst(txIn.Source)
 
 	// write the transaction file (only if outDir is set)
-	if p.outDir != "" {
+	if outFiles.FTxs != nil {
 		// create tx rlp
 		rlpHex, err := common.TxToRLPString(tx)
 		if err != nil {
@@ -306,13 +560,38 @@ func (p *TxProcessor) processTx(txIn common.TxIn) {
 		}
 	}
 
+	if outFiles.Parquet != nil {
+		err = outFiles.Parquet.Add(txIn)
+		if err != nil {
+			log.Errorw("failed to add transaction to parquet", "error", err)
+		}
+	}
+
+	// write the blob sidecar archive (blobs, commitments and proofs by tx hash)
+	if outFiles.FBlobs != nil {
+		for _, row := range common.BlobSidecarCSVRows(txIn.T.UnixMilli(), tx) {
+			_, err = fmt.Fprintf(outFiles.FBlobs, "%s\n", strings.Join(row, ","))
+			if err != nil {
+				log.Errorw("fmt.Fprintf", "error", err)
+				break
+			}
+		}
+	}
+
 	// Remember that this transaction was processed
 	p.knownTxsLock.Lock()
 	p.knownTxs[txHashLower] = txIn.T
 	p.knownTxsLock.Unlock()
+
+	if p.txCacheFile != nil {
+		err = p.txCacheFile.Add(txHashLower, txIn.T)
+		if err != nil {
+			log.Errorw("failed to write tx cache file", "error", err)
+		}
+	}
 }
 
-func (p *TxProcessor) writeTrash(fTrash *os.File, txIn common.TxIn, message, notes string) {
+func (p *TxProcessor) writeTrash(fTrash OutputWriter, txIn common.TxIn, message, notes string) {
 	if fTrash == nil {
 		return // skip writing if file handle is nil (no-write mode)
 	}
@@ -324,7 +603,7 @@ func (p *TxProcessor) writeTrash(fTrash *os.File, txIn common.TxIn, message, not
 	}
 }
 
-func (p *TxProcessor) writeInvalidTx(fTrash *os.File, txIn common.TxIn, err error) {
+func (p *TxProcessor) writeInvalidTx(fTrash OutputWriter, txIn common.TxIn, err error) {
 	if fTrash == nil || err == nil {
 		return // skip writing if file handle is nil (no-write mode) or no error
 	}
@@ -415,71 +694,147 @@ func (p *TxProcessor) validateBlobTx(tx *types.Transaction) error {
 	return nil
 }
 
-// getOutputCSVFiles returns two file handles - one for the transactions and one for source stats, if needed - and a boolean indicating whether the file was created
+// getOutputCSVFiles returns two file handles - one for the transactions and one for source stats, if needed - and a boolean indicating whether the file was created.
+// The files aren't closed until the caller releases them (outFiles.release()).
 func (p *TxProcessor) getOutputCSVFiles(timestamp int64) (outFiles OutFiles, isCreated bool, err error) {
-	// bucketTS := timestamp / secPerDay * secPerDay // down-round timestamp to start of bucket
-	sec := int64(bucketMinutes * 60)
-	bucketTS := timestamp / sec * sec // timestamp down-round to start of bucket
+	bucketTS := bucketStart(timestamp)
 	t := time.Unix(bucketTS, 0).UTC()
 
-	// files may already be opened
+	// files may already be opened (the reference is taken while holding the lock, so the housekeeper can't close them meanwhile)
 	p.outFilesLock.RLock()
 	outFiles, outFilesOk := p.outFiles[bucketTS]
+	if outFilesOk {
+		outFiles.refs.Inc()
+	}
 	p.outFilesLock.RUnlock()
 
 	if outFilesOk {
 		return outFiles, false, nil
 	}
-	// open transactions output files
-	dir := filepath.Join(p.outDir, t.Format(time.DateOnly), "transactions")
-	err = os.MkdirAll(dir, os.ModePerm)
-	if err != nil {
-		return OutFiles{}, false, err
-	}
 
-	fn := filepath.Join(dir, p.getFilename("txs", bucketTS))
-	fTx, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
-	if err != nil {
-		return OutFiles{}, false, err
+	// a file must only have one writer, so check again while holding the write lock
+	p.outFilesLock.Lock()
+	defer p.outFilesLock.Unlock()
+	if outFiles, outFilesOk = p.outFiles[bucketTS]; outFilesOk {
+		outFiles.refs.Inc()
+		return outFiles, false, nil
 	}
 
 	// open sourcelog for writing
-	dir = filepath.Join(p.outDir, t.Format(time.DateOnly), "sourcelog")
-	err = os.MkdirAll(dir, os.ModePerm)
+	outFiles.FSourcelog, err = p.openOutputFile(t, "sourcelog", "src", bucketTS)
 	if err != nil {
 		return OutFiles{}, false, err
 	}
 
-	fn = filepath.Join(dir, p.getFilename("src", bucketTS))
-	fSourcelog, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	// open trash for writing
+	outFiles.FTrash, err = p.openOutputFile(t, "trash", "trash", bucketTS)
 	if err != nil {
+		_ = outFiles.Close()
 		return OutFiles{}, false, err
 	}
 
-	// open trash for writing
-	dir = filepath.Join(p.outDir, t.Format(time.DateOnly), "trash")
-	err = os.MkdirAll(dir, os.ModePerm)
-	if err != nil {
-		return OutFiles{}, false, err
+	// open transactions output file
+	if p.writeTxsCSV {
+		outFiles.FTxs, err = p.openOutputFile(t, "transactions", "txs", bucketTS)
+		if err != nil {
+			_ = outFiles.Close()
+			return OutFiles{}, false, err
+		}
 	}
 
-	fn = filepath.Join(dir, p.getFilename("trash", bucketTS))
-	fTrash, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
-	if err != nil {
-		return OutFiles{}, false, err
+	// open blob sidecar archive
+	if p.writeBlobSidecars {
+		outFiles.FBlobs, err = p.openOutputFile(t, "blobs", "blobs", bucketTS)
+		if err != nil {
+			_ = outFiles.Close()
+			return OutFiles{}, false, err
+		}
 	}
 
-	outFiles = OutFiles{
-		FTxs:       fTx,
-		FSourcelog: fSourcelog,
-		FTrash:     fTrash,
+	// load the parquet file of the bucket, if it was already written before
+	if p.writeParquet {
+		dir := filepath.Join(p.outDir, t.Format(time.DateOnly), "transactions")
+		err = os.MkdirAll(dir, os.ModePerm)
+		if err == nil {
+			fn := filepath.Join(dir, strings.TrimSuffix(p.getFilename("txs", bucketTS), ".csv")+".parquet")
+			outFiles.Parquet, err = openParquetBucket(p.log, fn, p.parquetSchemaVer)
+		}
+		if err != nil {
+			_ = outFiles.Close()
+			return OutFiles{}, false, err
+		}
 	}
-	p.outFilesLock.Lock()
+
+	outFiles.refs = atomic.NewInt64(1)
 	p.outFiles[bucketTS] = outFiles
-	p.outFilesLock.Unlock()
 	return outFiles, true, nil
 }
 
+// bucketStart rounds a timestamp (in seconds) down to the start of its bucket
+func bucketStart(timestamp int64) int64 {
+	sec := int64(bucketMinutes * 60)
+	return timestamp / sec * sec
+}
+
+// writeLeftoverParquetSpools writes the parquet files of spool files which weren't written to for longer than a bucket,
+// i.e. their bucket ended while the collector wasn't running. Spools of the current bucket are continued when it opens.
+func (p *TxProcessor) writeLeftoverParquetSpools() {
+	files, err := filepath.Glob(filepath.Join(p.outDir, "*", "transactions", "*.parquet.spool.csv"))
+	if err != nil {
+		p.log.Errorw("failed to find parquet spool files", "error", err)
+		return
+	}
+	for _, spoolFn := range files {
+		stat, err := os.Stat(spoolFn)
+		if err != nil || time.Since(stat.ModTime()) < time.Duration(bucketMinutes)*time.Minute {
+			continue
+		}
+		fn := strings.TrimSuffix(spoolFn, ".spool.csv")
+		if _, err = os.Stat(fn); err == nil {
+			_ = os.Remove(spoolFn) // the parquet file was written, but the spool not removed anymore
+			continue
+		}
+		b, err := openParquetBucket(p.log, fn, p.parquetSchemaVer)
+		if err == nil {
+			err = b.Close()
+		}
+		if err != nil {
+			p.log.Errorw("failed to write parquet file of spool", "file", spoolFn, "error", err)
+			continue
+		}
+		p.log.Infow("wrote parquet file of leftover spool", "file", b.fn, "txs", len(b.txs))
+	}
+}
+
+// addParquetSource adds a source to a tx in the parquet file of the bucket in which the tx was first seen
+func (p *TxProcessor) addParquetSource(firstSeen time.Time, txHashLower, source string) {
+	if !p.writeParquet {
+		return
+	}
+
+	p.outFilesLock.RLock()
+	defer p.outFilesLock.RUnlock()
+	outFiles, ok := p.outFiles[bucketStart(firstSeen.Unix())]
+	if ok && outFiles.Parquet != nil {
+		err := outFiles.Parquet.AddSource(txHashLower, source)
+		if err != nil {
+			p.log.Errorw("failed to add source to parquet", "tx_hash", txHashLower, "source", source, "error", err)
+		}
+	}
+}
+
+// openOutputFile opens an output file of a bucket, i.e. <out_dir>/<date>/transactions/txs_<date>_<uid>.csv[.zst]
+func (p *TxProcessor) openOutputFile(t time.Time, dir, prefix string, bucketTS int64) (OutputWriter, error) {
+	dir = filepath.Join(p.outDir, t.Format(time.DateOnly), dir)
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	fn := filepath.Join(dir, p.getFilename(prefix, bucketTS)) + outputFileExtension(p.outputCompression)
+	return openOutputWriter(fn, p.outputCompression)
+}
+
 func (p *TxProcessor) getFilename(prefix string, timestamp int64) string {
 	t := time.Unix(timestamp, 0).UTC()
 	if prefix != "" {
@@ -498,29 +853,36 @@ func (p *TxProcessor) startHousekeeper() {
 		time.Sleep(time.Minute)
 
 		// Remove old transactions from cache
+		now := p.now()
 		cachedBefore := len(p.knownTxs)
 		p.knownTxsLock.Lock()
 		for k, v := range p.knownTxs {
-			if time.Since(v) > txCacheTime {
+			if now.Sub(v) > txCacheTime {
 				delete(p.knownTxs, k)
 			}
 		}
 		p.knownTxsLock.Unlock()
 
-		// Remove old files from cache
-		filesBefore := len(p.outFiles)
-		p.outFilesLock.Lock()
-		for timestamp, outFiles := range p.outFiles {
-			usageSec := bucketMinutes * 60 * 2
-			if time.Now().UTC().Unix()-timestamp > int64(usageSec) { // remove all handles from 2x usage seconds ago
-				p.log.Infow("closing output files", "timestamp", timestamp)
-				delete(p.outFiles, timestamp)
-				_ = outFiles.FTxs.Close()
-				_ = outFiles.FSourcelog.Close()
-				_ = outFiles.FTrash.Close()
+		// Forget replacement chains of the same age
+		if p.clickhouse != nil {
+			p.clickhouse.PruneReplacements(now.Add(-txCacheTime))
+		}
+
+		// Rewrite the persisted cache without the expired transactions
+		if p.txCacheFile != nil {
+			err := p.txCacheFile.Compact(func() map[string]time.Time {
+				p.knownTxsLock.RLock()
+				defer p.knownTxsLock.RUnlock()
+				return maps.Clone(p.knownTxs)
+			})
+			if err != nil && !errors.Is(err, os.ErrClosed) {
+				p.log.Errorw("failed to compact tx cache file", "error", err)
 			}
 		}
-		p.outFilesLock.Unlock()
+
+		// Remove old files from cache (and flush the others, so compressed files are readable up to here after a crash)
+		filesBefore := len(p.outFiles)
+		p.closeOldOutputFiles(now)
 
 		// Get memory stats
 		var m runtime.MemStats
@@ -544,6 +906,7 @@ func (p *TxProcessor) startHousekeeper() {
 		p.srcMetrics.Logger(p.log, KeyStatsAll, false).Info("source_stats/all")
 		p.srcMetrics.Logger(p.log, KeyStatsUnique, true).Info("source_stats/unique")
 		p.srcMetrics.Logger(p.log, KeyStatsTxTrash, false).Info("source_stats/trash")
+		p.srcMetrics.Logger(p.log, KeyStatsTxDropped, false).Info("source_stats/dropped")
 
 		// reset counters
 		p.srcMetrics.Reset()
@@ -551,6 +914,26 @@ func (p *TxProcessor) startHousekeeper() {
 	}
 }
 
+// closeOldOutputFiles closes the files of the buckets that started more than 2 bucket durations before now, once no
+// transaction of the bucket is being written anymore, and flushes the others
+func (p *TxProcessor) closeOldOutputFiles(now time.Time) {
+	p.outFilesLock.Lock()
+	defer p.outFilesLock.Unlock()
+
+	usageSec := int64(bucketMinutes * 60 * 2)
+	for timestamp, outFiles := range p.outFiles {
+		if now.UTC().Unix()-timestamp > usageSec && outFiles.refs.Load() == 0 {
+			p.log.Infow("closing output files", "timestamp", timestamp)
+			delete(p.outFiles, timestamp)
+			if err := outFiles.Close(); err != nil {
+				p.log.Errorw("failed to close output files", "timestamp", timestamp, "error", err)
+			}
+		} else if err := outFiles.Flush(); err != nil {
+			p.log.Errorw("failed to flush output files", "timestamp", timestamp, "error", err)
+		}
+	}
+}
+
 func (p *TxProcessor) healthCheckCall() {
 	if healthChecksIOURL == "" {
 		return
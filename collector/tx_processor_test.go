@@ -2,10 +2,19 @@ package collector
 
 import (
 	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
 	"github.com/flashbots/mempool-dumpster/common"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
 )
 
 // var testLog = common.GetLogger(true, false)
@@ -55,3 +64,203 @@ func TestTxProcessor_sendTxToReceivers(t *testing.T) {
 		t.Errorf("expected tx, got nil")
 	}
 }
+
+func TestTxProcessor_workers(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:    common.GetLogger(true, false),
+		OutDir: outDir,
+		UID:    "test",
+	})
+	processor.startWorkers()
+
+	// the same tx from two sources, and another tx
+	now := time.Now()
+	tx1, tx2 := newTestTx(t, 0), newTestTx(t, 1)
+	processor.processTx(common.TxIn{T: now, Tx: tx1, Source: "source1"}) //nolint:exhaustruct
+	processor.processTx(common.TxIn{T: now, Tx: tx1, Source: "source2"}) //nolint:exhaustruct
+	processor.processTx(common.TxIn{T: now, Tx: tx2, Source: "source1"}) //nolint:exhaustruct
+
+	// every tx is in the sourcelog, but only unique txs are processed by the workers
+	require.Eventually(t, func() bool {
+		processor.knownTxsLock.RLock()
+		defer processor.knownTxsLock.RUnlock()
+		return len(processor.knownTxs) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	outFiles, _, err := processor.getOutputCSVFiles(now.Unix())
+	require.NoError(t, err)
+	sourcelog, err := os.ReadFile(outFiles.FSourcelog.Name())
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(string(sourcelog)), "\n"), 3)
+	txs, err := os.ReadFile(outFiles.FTxs.Name())
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(string(txs)), "\n"), 2)
+}
+
+func TestTxProcessor_Shutdown(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:    common.GetLogger(true, false),
+		OutDir: outDir,
+		UID:    "test",
+	})
+	processor.Start()
+
+	// transactions still queued on shutdown must end up in the files
+	now := time.Now()
+	for i := range 50 {
+		processor.txC <- common.TxIn{T: now, Tx: newTestTx(t, uint64(i)), Source: "source1"} //nolint:exhaustruct
+	}
+	outFiles, _, err := processor.getOutputCSVFiles(now.Unix())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, processor.Shutdown(ctx))
+
+	txs, err := os.ReadFile(outFiles.FTxs.Name())
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(string(txs)), "\n"), 50)
+
+	// all files are closed
+	require.Empty(t, processor.outFiles)
+	require.ErrorIs(t, outFiles.FTxs.Close(), os.ErrClosed)
+}
+
+func TestTxProcessor_shutdownSourcesRunning(t *testing.T) {
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:    common.GetLogger(true, false),
+		OutDir: t.TempDir(),
+		UID:    "test",
+	})
+	processor.Start()
+
+	now := time.Now()
+	for i := range 50 {
+		processor.txC <- common.TxIn{T: now, Tx: newTestTx(t, uint64(i)), Source: "source1"} //nolint:exhaustruct
+	}
+	outFiles, _, err := processor.getOutputCSVFiles(now.Unix())
+	require.NoError(t, err)
+
+	// a source is still stopping: the queued transactions are drained and flushed, but txC stays open
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, processor.shutdown(ctx, false))
+
+	txs, err := os.ReadFile(outFiles.FTxs.Name())
+	require.NoError(t, err)
+	require.Len(t, strings.Split(strings.TrimSpace(string(txs)), "\n"), 50)
+	require.ErrorIs(t, outFiles.FTxs.Close(), os.ErrClosed)
+	processor.txC <- common.TxIn{T: now, Tx: newTestTx(t, 50), Source: "source1"} //nolint:exhaustruct
+}
+
+func TestTxProcessor_parquet(t *testing.T) {
+	outDir := t.TempDir()
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:                  common.GetLogger(true, false),
+		OutDir:               outDir,
+		UID:                  "test",
+		NoTxsCSV:             true,
+		WriteParquet:         true,
+		ParquetSchemaVersion: common.ParquetSchemaV2,
+	})
+	processor.Start()
+
+	now := time.Now()
+	tx1, tx2 := newTestTx(t, 0), newTestTx(t, 1)
+	processor.txC <- common.TxIn{T: now, Tx: tx1, Source: "source1"}                           //nolint:exhaustruct
+	processor.txC <- common.TxIn{T: now.Add(time.Millisecond), Tx: tx1, Source: "source2"}     //nolint:exhaustruct
+	processor.txC <- common.TxIn{T: now.Add(2 * time.Millisecond), Tx: tx2, Source: "source2"} //nolint:exhaustruct
+	outFiles, _, err := processor.getOutputCSVFiles(now.Unix())
+	require.NoError(t, err)
+	require.Nil(t, outFiles.FTxs)
+
+	// the parquet file is written when the bucket is closed
+	require.NoError(t, processor.Shutdown(context.Background()))
+	schemaVersion, err := common.ParquetSchemaVersion(outFiles.Parquet.fn)
+	require.NoError(t, err)
+	require.Equal(t, common.ParquetSchemaV2, schemaVersion)
+	txs, err := common.LoadTransactionParquetFiles(processor.log, []string{outFiles.Parquet.fn})
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	require.Equal(t, []string{"source1", "source2"}, txs[strings.ToLower(tx1.Hash().Hex())].Sources)
+	require.Equal(t, []string{"source2"}, txs[strings.ToLower(tx2.Hash().Hex())].Sources)
+	require.Equal(t, now.UnixMilli(), txs[strings.ToLower(tx1.Hash().Hex())].Timestamp)
+}
+
+func newTestBlobTx(t *testing.T) *types.Transaction {
+	t.Helper()
+	var blob kzg4844.Blob
+	commitment, err := kzg4844.BlobToCommitment(&blob)
+	require.NoError(t, err)
+	proof, err := kzg4844.ComputeBlobProof(&blob, commitment)
+	require.NoError(t, err)
+	sidecar := &types.BlobTxSidecar{Blobs: []kzg4844.Blob{blob}, Commitments: []kzg4844.Commitment{commitment}, Proofs: []kzg4844.Proof{proof}}
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.BlobTx{ //nolint:exhaustruct
+		ChainID:    uint256.NewInt(1),
+		GasTipCap:  uint256.NewInt(1_000_000_000),
+		GasFeeCap:  uint256.NewInt(20_000_000_000),
+		Gas:        21_000,
+		BlobFeeCap: uint256.NewInt(1),
+		BlobHashes: sidecar.BlobHashes(),
+		Sidecar:    sidecar,
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+func TestTxProcessor_blobSidecars(t *testing.T) {
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:               common.GetLogger(true, false),
+		OutDir:            t.TempDir(),
+		UID:               "test",
+		WriteBlobSidecars: true,
+	})
+	processor.Start()
+
+	now := time.Now()
+	blobTx := newTestBlobTx(t)
+	processor.txC <- common.TxIn{T: now, Tx: newTestTx(t, 0), Source: "source1"} //nolint:exhaustruct
+	processor.txC <- common.TxIn{T: now, Tx: blobTx, Source: "source1"}          //nolint:exhaustruct
+	processor.txC <- common.TxIn{T: now, Tx: blobTx, Source: "source2"}          //nolint:exhaustruct
+	outFiles, _, err := processor.getOutputCSVFiles(now.Unix())
+	require.NoError(t, err)
+	require.NoError(t, processor.Shutdown(context.Background()))
+
+	// one row per blob of the first seen blob tx
+	content, err := os.ReadFile(outFiles.FBlobs.Name())
+	require.NoError(t, err)
+	require.Equal(t, "blobs", filepath.Base(filepath.Dir(outFiles.FBlobs.Name())))
+	rows := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, rows, 1)
+	require.Equal(t, strings.Join(common.BlobSidecarCSVRows(now.UnixMilli(), blobTx)[0], ","), rows[0])
+}
+
+func TestTxProcessor_closeOldOutputFiles(t *testing.T) {
+	processor := NewTxProcessor(TxProcessorOpts{ //nolint:exhaustruct
+		Log:    common.GetLogger(true, false),
+		OutDir: t.TempDir(),
+		UID:    "test",
+	})
+
+	// a tx of the bucket is still queued for a worker
+	now := time.Now()
+	outFiles, _, err := processor.getOutputCSVFiles(now.Unix())
+	require.NoError(t, err)
+
+	// the files of old buckets stay open while referenced
+	later := now.Add(time.Duration(3*bucketMinutes) * time.Minute)
+	processor.closeOldOutputFiles(later)
+	require.Len(t, processor.outFiles, 1)
+	_, err = outFiles.FTrash.Write([]byte("still writable\n"))
+	require.NoError(t, err)
+
+	// and are closed once released
+	outFiles.release()
+	processor.closeOldOutputFiles(later)
+	require.Empty(t, processor.outFiles)
+}
@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// txCacheFile persists the TxProcessor's known transactions as an append-only CSV file (format: <timestamp_ms>,<tx_hash>),
+// so that a restarted collector doesn't process still-pending transactions a second time.
+//
+// Every processed transaction is appended to the file. The file is compacted (rewritten without expired entries) on load and
+// periodically by the housekeeper. A partially written last line (i.e. after a crash) is ignored.
+type txCacheFile struct {
+	fn     string
+	lock   sync.Mutex
+	f      *os.File
+	closed bool
+
+	// pending are the entries added while compacting, appended to the compacted file before it replaces the old one
+	pending []txCacheEntry
+}
+
+type txCacheEntry struct {
+	hash string
+	t    time.Time
+}
+
+// openTxCacheFile loads all entries younger than txCacheTime from the file (if it exists), compacts it and opens it for appending
+func openTxCacheFile(fn string) (c *txCacheFile, knownTxs map[string]time.Time, err error) {
+	knownTxs, err = loadTxCacheFile(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fn), os.ModePerm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c = &txCacheFile{fn: fn} //nolint:exhaustruct
+	err = c.Compact(func() map[string]time.Time { return knownTxs })
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, knownTxs, nil
+}
+
+func loadTxCacheFile(fn string) (map[string]time.Time, error) {
+	knownTxs := make(map[string]time.Time)
+
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return knownTxs, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ts, hash, found := strings.Cut(scanner.Text(), ",")
+		if !found || len(hash) != 66 {
+			continue // skip incomplete lines
+		}
+
+		tsMs, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		t := time.UnixMilli(tsMs)
+		if time.Since(t) > txCacheTime {
+			continue
+		}
+		knownTxs[hash] = t
+	}
+	return knownTxs, scanner.Err()
+}
+
+// Add appends a processed transaction to the file
+func (c *txCacheFile) Add(hash string, t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.f == nil {
+		return os.ErrClosed
+	}
+	if c.pending != nil {
+		c.pending = append(c.pending, txCacheEntry{hash: hash, t: t})
+	}
+	_, err := fmt.Fprintf(c.f, "%d,%s\n", t.UnixMilli(), hash)
+	return err
+}
+
+// Compact atomically replaces the file with the transactions returned by snapshot, and reopens it for appending. The
+// file is written without holding the lock, so Add isn't blocked meanwhile (entries added after snapshot is called
+// are kept in the compacted file). Compacting a closed file returns os.ErrClosed.
+func (c *txCacheFile) Compact(snapshot func() map[string]time.Time) error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return os.ErrClosed
+	}
+	c.pending = make([]txCacheEntry, 0)
+	c.lock.Unlock()
+
+	tmpFn := c.fn + ".tmp"
+	f, w, err := c.writeTmpFile(tmpFn, snapshot())
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	pending := c.pending
+	c.pending = nil
+	if err != nil {
+		return err
+	}
+	if c.closed {
+		_ = f.Close()
+		_ = os.Remove(tmpFn)
+		return os.ErrClosed
+	}
+
+	for _, entry := range pending {
+		_, err = fmt.Fprintf(w, "%d,%s\n", entry.t.UnixMilli(), entry.hash)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpFn, c.fn); err != nil {
+		return err
+	}
+
+	// reopen the new file for appending
+	if c.f != nil {
+		_ = c.f.Close()
+	}
+	c.f, err = os.OpenFile(c.fn, os.O_APPEND|os.O_WRONLY, 0o600)
+	return err
+}
+
+// writeTmpFile writes the transactions to a new file, and returns it with the (not yet flushed) writer
+func (c *txCacheFile) writeTmpFile(tmpFn string, knownTxs map[string]time.Time) (*os.File, *bufio.Writer, error) {
+	f, err := os.OpenFile(tmpFn, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	for hash, t := range knownTxs {
+		_, err = fmt.Fprintf(w, "%d,%s\n", t.UnixMilli(), hash)
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+	}
+	return f, w, nil
+}
+
+// Close syncs and closes the file
+func (c *txCacheFile) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.closed = true
+	if c.f == nil {
+		return nil
+	}
+	_ = c.f.Sync()
+	err := c.f.Close()
+	c.f = nil
+	return err
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxCacheFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "txcache.csv")
+	hash1 := "0xbb59e550e4730da43af01b7ae6e1d05b1df501baa4119b8ab6a3427d9b3635b1"
+	hash2 := "0xdd00ae95e4dc13fdf92682137223d697e346852a61c268faa8806b59a8cb2c9b"
+	hash3 := "0x0ee8a3a9d55a9b45bc7b1a4bcbb8d1d4c6f5d0dbe2c1f5e4cd3f6a08fe9e3d11"
+
+	// empty on first start
+	c, knownTxs, err := openTxCacheFile(fn)
+	require.NoError(t, err)
+	require.Empty(t, knownTxs)
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	require.NoError(t, c.Add(hash1, now))
+	require.NoError(t, c.Add(hash2, now.Add(-txCacheTime-time.Minute))) // expired
+	require.NoError(t, c.Close())
+
+	// simulate a crash in the middle of writing a line
+	f, err := os.OpenFile(fn, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(f, "%d,%s", now.UnixMilli(), hash3[:20])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// reload keeps only the valid, unexpired entry
+	c, knownTxs, err = openTxCacheFile(fn)
+	require.NoError(t, err)
+	require.Equal(t, map[string]time.Time{hash1: now}, knownTxs)
+
+	// appending after the compaction results in valid entries
+	require.NoError(t, c.Add(hash3, now))
+	require.NoError(t, c.Close())
+	_, knownTxs, err = openTxCacheFile(fn)
+	require.NoError(t, err)
+	require.Len(t, knownTxs, 2)
+	require.Equal(t, now, knownTxs[hash3])
+}
+
+func TestTxCacheFile_Compact(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "txcache.csv")
+	hash1 := "0xbb59e550e4730da43af01b7ae6e1d05b1df501baa4119b8ab6a3427d9b3635b1"
+	hash2 := "0xdd00ae95e4dc13fdf92682137223d697e346852a61c268faa8806b59a8cb2c9b"
+	now := time.UnixMilli(time.Now().UnixMilli())
+
+	c, _, err := openTxCacheFile(fn)
+	require.NoError(t, err)
+
+	// entries added while compacting (after the snapshot) are kept
+	require.NoError(t, c.Compact(func() map[string]time.Time {
+		require.NoError(t, c.Add(hash2, now))
+		return map[string]time.Time{hash1: now}
+	}))
+	require.NoError(t, c.Close())
+
+	// compacting a closed file doesn't reopen it
+	require.ErrorIs(t, c.Compact(func() map[string]time.Time { return nil }), os.ErrClosed)
+	require.ErrorIs(t, c.Add(hash1, now), os.ErrClosed)
+
+	_, knownTxs, err := openTxCacheFile(fn)
+	require.NoError(t, err)
+	require.Equal(t, map[string]time.Time{hash1: now, hash2: now}, knownTxs)
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/flashbots/mempool-dumpster/metrics"
+)
+
+// IdleExempt is implemented by sources which the watchdog leaves alone when they are idle
+type IdleExempt interface {
+	IdleExempt() bool
+}
+
+// IdleHandler is implemented by sources with their own action for idle connections, instead of reconnecting the whole
+// source (i.e. devp2p disconnects only the idle peers)
+type IdleHandler interface {
+	HandleIdle(idleTimeout time.Duration)
+}
+
+// startSourceWatchdog reconnects sources which are subscribed, but haven't sent a transaction for longer than idleTimeout.
+// Some providers silently stop streaming without closing the subscription.
+func (c *Collector) startSourceWatchdog(ctx context.Context, idleTimeout time.Duration) {
+	c.log.Infow("Starting source watchdog", "idleTimeout", idleTimeout.String())
+	ticker := time.NewTicker(idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, source := range c.getSources() {
+			if exempt, ok := source.(IdleExempt); ok && exempt.IdleExempt() {
+				continue
+			}
+
+			health := source.Health()
+			if health.State != SourceStateSubscribed {
+				continue
+			}
+			if handler, ok := source.(IdleHandler); ok {
+				handler.HandleIdle(idleTimeout)
+				continue
+			}
+
+			// idle since the last tx, or since subscribing if no tx was received on this connection yet
+			lastActivity := health.StateSince
+			if health.LastTxTime != nil && health.LastTxTime.After(lastActivity) {
+				lastActivity = *health.LastTxTime
+			}
+
+			idle := time.Since(lastActivity)
+			if idle < idleTimeout {
+				continue
+			}
+
+			metrics.IncSourceStalled(health.Name)
+			c.log.Warnw("source stalled, reconnecting", "source", health.Name, "idle", idle.Round(time.Second).String())
+			source.Reconnect(fmt.Errorf("%w: no transaction for %s", errSourceStalled, idle.Round(time.Second)))
+		}
+	}
+}
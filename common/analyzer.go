@@ -2,25 +2,37 @@ package common
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
+	"maps"
+	"math/big"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/olekukonko/tablewriter"
 )
 
 type Analyzer2Opts struct {
-	Transactions map[string]*TxSummaryEntry
-	Sourelog     map[string]map[string]int64 // [hash][source] = timestampMs
-	SourceComps  []SourceComp
+	Transactions  map[string]*TxSummaryEntry
+	Sourelog      map[string]map[string]int64 // [hash][source] = timestampMs
+	Announcements map[string]map[string]int64 // [hash][source] = announcedMs (optional, only sources with hash announcements)
+	SourceComps   []SourceComp
+	Labels        *Labels // optional, names the methods and contracts (if not already set on the transactions)
 }
 
 type Analyzer2 struct {
-	Transactions map[string]*TxSummaryEntry
-	Sourcelog    map[string]map[string]int64
-	SourceComps  []SourceComp
+	Transactions  map[string]*TxSummaryEntry
+	Sourcelog     map[string]map[string]int64
+	Announcements map[string]map[string]int64
+	SourceComps   []SourceComp
+	Labels        *Labels
+
+	withSourceStats bool // false without sourcelog, only the transaction counts are printed
 
 	nTransactionsPerSource map[string]int64
 	sources                []string
@@ -43,6 +55,29 @@ type Analyzer2 struct {
 	nTxExclusiveIncludedCnt    int64
 	nTxExclusiveNotIncludedCnt int64
 
+	// calldata and access list features
+	nContractCreations     int64
+	nTxWithAccessList      int64
+	nAccessListAddresses   int64
+	nAccessListStorageKeys int64
+	nDataZeroBytes         int64
+	nDataNonZeroBytes      int64
+	intrinsicGasTotal      int64
+
+	// set-code transactions (type 4)
+	setCode setCodeStats
+
+	// replacements (same sender and nonce) and nonce gaps
+	replacements replacementStats
+
+	// transactions per method and per receiving contract
+	methods   labelStats
+	contracts labelStats
+
+	// delay between hash announcement and full transaction, per source
+	announcementDelaysMs map[string][]int64
+	announcedSources     []string
+
 	timestampFirst int64
 	timestampLast  int64
 	timeFirst      time.Time
@@ -51,10 +86,48 @@ type Analyzer2 struct {
 }
 
 func NewAnalyzer2(opts Analyzer2Opts) *Analyzer2 {
-	a := &Analyzer2{ //nolint:exhaustruct
+	a := newAnalyzer2(opts)
+	a.Sourcelog = opts.Sourelog
+	a.Announcements = opts.Announcements
+	a.withSourceStats = opts.Sourelog != nil
+
+	// Now add all transactions to analyzer cache that were not included before received
+	for _, tx := range opts.Transactions {
+		if tx.WasIncludedBeforeReceived() {
+			continue
+		}
+
+		a.Transactions[strings.ToLower(tx.Hash)] = tx
+	}
+
+	// Run the analyzer (in timestamp order, like the streaming analyzer)
+	txs := slices.Collect(maps.Values(a.Transactions))
+	slices.SortFunc(txs, func(a, b *TxSummaryEntry) int {
+		return cmp.Or(cmp.Compare(a.Timestamp, b.Timestamp), cmp.Compare(a.Hash, b.Hash))
+	})
+	for _, tx := range txs {
+		hash := strings.ToLower(tx.Hash)
+		a.Add(tx)
+		a.AddAnnouncements(tx, a.Announcements[hash], a.Sourcelog[hash])
+	}
+	a.Finish()
+	return a
+}
+
+// NewStreamingAnalyzer2 returns an analyzer that doesn't hold the transactions in memory: they are added one by one
+// with Add (sorted by timestamp), and the announcement delays with AddAnnouncements. opts.Transactions, opts.Sourelog
+// and opts.Announcements are ignored. Finish must be called before printing.
+func NewStreamingAnalyzer2(opts Analyzer2Opts) *Analyzer2 {
+	a := newAnalyzer2(opts)
+	a.withSourceStats = true
+	return a
+}
+
+func newAnalyzer2(opts Analyzer2Opts) *Analyzer2 {
+	return &Analyzer2{ //nolint:exhaustruct
 		Transactions: make(map[string]*TxSummaryEntry),
-		Sourcelog:    opts.Sourelog,
 		SourceComps:  opts.SourceComps,
+		Labels:       opts.Labels,
 
 		nTransactionsPerSource: make(map[string]int64),
 		nTxOnChainBySource:     make(map[string]int64),
@@ -62,74 +135,103 @@ func NewAnalyzer2(opts Analyzer2Opts) *Analyzer2 {
 		nTxExclusiveIncluded:   make(map[string]map[bool]int64), // [source][isIncluded]count
 		nTransactionsPerType:   make(map[int64]int64),
 		txBytesPerType:         make(map[int64]int64),
+		announcementDelaysMs:   make(map[string][]int64),
 	}
+}
 
-	// Now add all transactions to analyzer cache that were not included before received
-	for _, tx := range opts.Transactions {
-		if tx.WasIncludedBeforeReceived() {
-			continue
-		}
+// Add adds the stats of a transaction (transactions that were included before they were received are skipped)
+func (a *Analyzer2) Add(tx *TxSummaryEntry) {
+	if tx.WasIncludedBeforeReceived() {
+		return
+	}
 
-		a.Transactions[strings.ToLower(tx.Hash)] = tx
+	a.nUniqueTransactions += 1
+	if tx.IncludedAtBlockHeight == 0 {
+		a.nNotIncluded += 1
+	} else {
+		a.nIncluded += 1
 	}
 
-	// Run the analyzer
-	a.init()
-	return a
-}
+	// Count transactions per type
+	a.nTransactionsPerType[tx.TxType] += 1
+	a.txBytesPerType[tx.TxType] += int64(len(tx.RawTx)) / 2
+	if tx.TxType == types.SetCodeTxType {
+		a.setCode.add(tx)
+	}
+	a.replacements.add(tx)
+
+	// Calldata and access list features
+	if tx.IsContractCreation {
+		a.nContractCreations += 1
+	}
+	if tx.AccessListAddressCount > 0 {
+		a.nTxWithAccessList += 1
+		a.nAccessListAddresses += tx.AccessListAddressCount
+		a.nAccessListStorageKeys += tx.AccessListStorageKeyCount
+	}
+	a.nDataZeroBytes += tx.DataZeroBytes
+	a.nDataNonZeroBytes += tx.DataNonZeroBytes
+	a.intrinsicGasTotal += tx.IntrinsicGas
+
+	// Methods and contracts
+	a.methods.add(cmp.Or(tx.Data4Bytes, "(no calldata)"), a.methodName(tx), tx)
+	if tx.To != "" {
+		a.contracts.add(strings.ToLower(tx.To), a.contractName(tx), tx)
+	}
 
-// Init does some efficient initial data analysis and preparation for later use
-func (a *Analyzer2) init() {
-	a.nUniqueTransactions = int64(len(a.Transactions))
+	// Go over sources
+	for _, src := range tx.Sources {
+		// Count overall tx / source
+		a.nTransactionsPerSource[src] += 1
 
-	// iterate over tx to
-	for _, tx := range a.Transactions {
+		// Count landed vs non-landed tx
 		if tx.IncludedAtBlockHeight == 0 {
-			a.nNotIncluded += 1
+			a.nTxNotOnChainBySource[src] += 1
 		} else {
-			a.nIncluded += 1
+			a.nTxOnChainBySource[src] += 1
 		}
 
-		// Count transactions per type
-		a.nTransactionsPerType[tx.TxType] += 1
-		a.txBytesPerType[tx.TxType] += int64(len(tx.RawTx)) / 2
-
-		// Go over sources
-		for _, src := range tx.Sources {
-			// Count overall tx / source
-			a.nTransactionsPerSource[src] += 1
+		// Count exclusive orderflow
+		if len(tx.Sources) == 1 {
+			if a.nTxExclusiveIncluded[src] == nil {
+				a.nTxExclusiveIncluded[src] = make(map[bool]int64)
+			}
+			a.nTxExclusiveIncluded[src][tx.IncludedAtBlockHeight != 0] += 1
+			a.nExclusiveOrderflow += 1
 
-			// Count landed vs non-landed tx
 			if tx.IncludedAtBlockHeight == 0 {
-				a.nTxNotOnChainBySource[src] += 1
+				a.nTxExclusiveNotIncludedCnt += 1
 			} else {
-				a.nTxOnChainBySource[src] += 1
-			}
-
-			// Count exclusive orderflow
-			if len(tx.Sources) == 1 {
-				if a.nTxExclusiveIncluded[src] == nil {
-					a.nTxExclusiveIncluded[src] = make(map[bool]int64)
-				}
-				a.nTxExclusiveIncluded[src][tx.IncludedAtBlockHeight != 0] += 1
-				a.nExclusiveOrderflow += 1
-
-				if tx.IncludedAtBlockHeight == 0 {
-					a.nTxExclusiveNotIncludedCnt += 1
-				} else {
-					a.nTxExclusiveIncludedCnt += 1
-				}
+				a.nTxExclusiveIncludedCnt += 1
 			}
 		}
+	}
 
-		// find first and last timestamp
-		if a.timestampFirst == 0 || tx.Timestamp < a.timestampFirst {
-			a.timestampFirst = tx.Timestamp
-		}
-		if a.timestampLast == 0 || tx.Timestamp > a.timestampLast {
-			a.timestampLast = tx.Timestamp
+	// find first and last timestamp
+	if a.timestampFirst == 0 || tx.Timestamp < a.timestampFirst {
+		a.timestampFirst = tx.Timestamp
+	}
+	if a.timestampLast == 0 || tx.Timestamp > a.timestampLast {
+		a.timestampLast = tx.Timestamp
+	}
+}
+
+// AddAnnouncements adds the announcement -> body delays of a transaction, from its announcements and sourcelog entries
+// ([source] = timestampMs)
+func (a *Analyzer2) AddAnnouncements(tx *TxSummaryEntry, announcedMs, receivedMs map[string]int64) {
+	if tx.WasIncludedBeforeReceived() {
+		return
+	}
+	for src, announced := range announcedMs {
+		if received, ok := receivedMs[src]; ok {
+			a.announcementDelaysMs[src] = append(a.announcementDelaysMs[src], received-announced)
 		}
 	}
+}
+
+// Finish computes the stats that need all transactions
+func (a *Analyzer2) Finish() {
+	a.replacements.finish()
 
 	// convert timestamps to duration and UTC time
 	a.timeFirst = time.Unix(a.timestampFirst/1000, 0).UTC()
@@ -142,6 +244,13 @@ func (a *Analyzer2) init() {
 	}
 	sort.Strings(a.sources)
 
+	// sort announcement delays (for percentiles) and get sorted list of announcing sources
+	for src, delays := range a.announcementDelaysMs {
+		sort.Slice(delays, func(i, j int) bool { return delays[i] < delays[j] })
+		a.announcedSources = append(a.announcedSources, src)
+	}
+	sort.Strings(a.announcedSources)
+
 	// get sorted list of txTypes
 	for txType := range a.nTransactionsPerType {
 		a.txTypes = append(a.txTypes, txType)
@@ -179,7 +288,7 @@ func (a *Analyzer2) Sprint() string {
 	out += Printer.Sprintf("- Included on-chain: %10d (%5s) \n", a.nIncluded, Int64DiffPercentFmt(a.nIncluded, a.nUniqueTransactions, 1))
 	out += Printer.Sprintf("- Not included:      %10d (%5s) \n", a.nNotIncluded, Int64DiffPercentFmt(a.nNotIncluded, a.nUniqueTransactions, 1))
 
-	if a.Sourcelog == nil {
+	if !a.withSourceStats {
 		return out
 	}
 
@@ -198,8 +307,8 @@ func (a *Analyzer2) Sprint() string {
 	SetupMarkdownTableWriter(table)
 	table.SetHeader([]string{"Tx Type", "Count"})
 	// table.SetHeader([]string{"Tx Type", "Count", "Size Total", "Size Avg"})
-	for txType := range a.txTypes {
-		count := a.nTransactionsPerType[int64(txType)]
+	for _, txType := range a.txTypes {
+		count := a.nTransactionsPerType[txType]
 		table.Append([]string{
 			fmt.Sprint(txType),
 			Printer.Sprintf("%10d (%5s)", count, Int64DiffPercentFmt(count, a.nUniqueTransactions, 1)),
@@ -209,9 +318,329 @@ func (a *Analyzer2) Sprint() string {
 	}
 	table.Render()
 	out += buff.String()
+
+	out += a.sprintFeatureStats()
+
+	if a.nUniqueTransactions > 0 {
+		out += a.methods.sprintTop("Top Methods", "Selector", "Method")
+		out += a.contracts.sprintTop("Top Contracts", "Contract", "Label")
+	}
+
+	if a.setCode.nTxs > 0 {
+		out += a.sprintSetCodeStats()
+	}
+
+	if a.replacements.nReplacements > 0 || a.replacements.nSendersWithNonceGaps > 0 {
+		out += a.replacements.sprint()
+	}
+
+	if len(a.announcedSources) > 0 {
+		out += a.sprintAnnouncementLatency()
+	}
 	return out
 }
 
+// sprintFeatureStats prints the calldata and access list stats
+func (a *Analyzer2) sprintFeatureStats() string {
+	nDataBytes := a.nDataZeroBytes + a.nDataNonZeroBytes
+	out := fmt.Sprintln("")
+	out += Printer.Sprintf("Contract creations:    %10d (%5s) \n", a.nContractCreations, Int64DiffPercentFmt(a.nContractCreations, a.nUniqueTransactions, 1))
+	out += Printer.Sprintf("With access list:      %10d (%5s) \n", a.nTxWithAccessList, Int64DiffPercentFmt(a.nTxWithAccessList, a.nUniqueTransactions, 1))
+	if a.nTxWithAccessList > 0 {
+		out += Printer.Sprintf("- Avg addresses:       %10.1f \n", float64(a.nAccessListAddresses)/float64(a.nTxWithAccessList))
+		out += Printer.Sprintf("- Avg storage keys:    %10.1f \n", float64(a.nAccessListStorageKeys)/float64(a.nTxWithAccessList))
+	}
+	out += Printer.Sprintf("Calldata zero bytes:   %10s (%5s) \n", HumanBytes(uint64(a.nDataZeroBytes)), Int64DiffPercentFmt(a.nDataZeroBytes, nDataBytes, 1)) //nolint:gosec
+	if a.nUniqueTransactions > 0 {
+		out += Printer.Sprintf("Avg intrinsic gas:     %10d \n", a.intrinsicGasTotal/a.nUniqueTransactions)
+	}
+	return out
+}
+
+// methodName returns the signature of the method called by a transaction, if it's known
+func (a *Analyzer2) methodName(tx *TxSummaryEntry) string {
+	return cmp.Or(tx.MethodName, a.Labels.MethodName(tx.Data4Bytes))
+}
+
+// contractName returns the label of the receiver of a transaction, if it's known
+func (a *Analyzer2) contractName(tx *TxSummaryEntry) string {
+	return cmp.Or(tx.ToLabel, a.Labels.AddressLabel(tx.To))
+}
+
+// labelStats counts transactions and non-included transactions per key (i.e. selector or receiver address)
+type labelStats struct {
+	nTxs         map[string]int64
+	nNotIncluded map[string]int64
+	labels       map[string]string
+	nTotal       int64
+}
+
+func (s *labelStats) add(key, label string, tx *TxSummaryEntry) {
+	if s.nTxs == nil {
+		s.nTxs = make(map[string]int64)
+		s.nNotIncluded = make(map[string]int64)
+		s.labels = make(map[string]string)
+	}
+
+	s.nTxs[key] += 1
+	s.nTotal += 1
+	if tx.IncludedAtBlockHeight == 0 {
+		s.nNotIncluded[key] += 1
+	}
+	if label != "" {
+		s.labels[key] = label
+	}
+}
+
+// sprintTop prints the 10 keys with the most transactions, their labels and their non-inclusion rate
+func (s *labelStats) sprintTop(title, keyColumn, labelColumn string) string {
+	out := fmt.Sprintln("")
+	out += fmt.Sprintln(strings.Repeat("-", len(title)))
+	out += fmt.Sprintln(title)
+	out += fmt.Sprintln(strings.Repeat("-", len(title)))
+	out += fmt.Sprintln("")
+
+	keys := make([]string, 0, len(s.nTxs))
+	for key := range s.nTxs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if s.nTxs[keys[i]] != s.nTxs[keys[j]] {
+			return s.nTxs[keys[i]] > s.nTxs[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	buff := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buff)
+	SetupMarkdownTableWriter(table)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{keyColumn, labelColumn, "Transactions", "Not included"})
+	for _, key := range keys[:min(len(keys), 10)] {
+		table.Append([]string{
+			key,
+			s.labels[key],
+			Printer.Sprintf("%10d (%5s)", s.nTxs[key], Int64DiffPercentFmt(s.nTxs[key], s.nTotal, 1)),
+			Printer.Sprintf("%10d (%5s)", s.nNotIncluded[key], Int64DiffPercentFmt(s.nNotIncluded[key], s.nTxs[key], 1)),
+		})
+	}
+	table.Render()
+	out += buff.String()
+	return out
+}
+
+// setCodeStats are the stats of set-code transactions (EIP-7702)
+type setCodeStats struct {
+	nTxs          int64
+	nIncluded     int64
+	nAuths        int64
+	nInvalidAuths int64
+	authorities   map[string]bool
+	targets       map[string]int64 // delegation target address -> authorizations (the zero address clears a delegation)
+}
+
+func (s *setCodeStats) add(tx *TxSummaryEntry) {
+	if s.targets == nil {
+		s.authorities = make(map[string]bool)
+		s.targets = make(map[string]int64)
+	}
+
+	s.nTxs += 1
+	if tx.IncludedAtBlockHeight != 0 {
+		s.nIncluded += 1
+	}
+	s.nAuths += tx.AuthorizationCount
+	s.nInvalidAuths += tx.InvalidAuthorizationCount
+	for _, entry := range tx.Authorizations {
+		auth, err := ParseSetCodeAuthorization(entry)
+		if err != nil || !auth.IsValid() {
+			continue
+		}
+		s.authorities[auth.Authority] = true
+		s.targets[auth.Address] += 1
+	}
+}
+
+// sprintSetCodeStats prints the stats of set-code transactions, and the most used delegation targets
+func (a *Analyzer2) sprintSetCodeStats() string {
+	s := a.setCode
+	out := fmt.Sprintln("")
+	out += fmt.Sprintln("--------------------------------")
+	out += fmt.Sprintln("Set-Code Transactions (EIP-7702)")
+	out += fmt.Sprintln("--------------------------------")
+	out += fmt.Sprintln("")
+	out += Printer.Sprintf("Transactions:          %10d \n", s.nTxs)
+	out += Printer.Sprintf("- Included on-chain:   %10d (%5s) \n", s.nIncluded, Int64DiffPercentFmt(s.nIncluded, s.nTxs, 1))
+	out += Printer.Sprintf("Authorizations:        %10d \n", s.nAuths)
+	out += Printer.Sprintf("- Invalid:             %10d (%5s) \n", s.nInvalidAuths, Int64DiffPercentFmt(s.nInvalidAuths, s.nAuths, 1))
+	out += Printer.Sprintf("- Unique authorities:  %10d \n", len(s.authorities))
+	out += fmt.Sprintln("")
+
+	targets := make([]string, 0, len(s.targets))
+	for target := range s.targets {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if s.targets[targets[i]] != s.targets[targets[j]] {
+			return s.targets[targets[i]] > s.targets[targets[j]]
+		}
+		return targets[i] < targets[j]
+	})
+
+	buff := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buff)
+	SetupMarkdownTableWriter(table)
+	table.SetHeader([]string{"Delegation target", "Authorizations"})
+	for _, target := range targets[:min(len(targets), 10)] {
+		table.Append([]string{
+			target,
+			Printer.Sprintf("%10d (%5s)", s.targets[target], Int64DiffPercentFmt(s.targets[target], s.nAuths, 1)),
+		})
+	}
+	table.Render()
+	out += buff.String()
+	return out
+}
+
+// replacementStats are the stats of replacement chains (see FindReplacements), and of nonce gaps between the
+// transactions seen from a sender. The transactions are added in timestamp order.
+type replacementStats struct {
+	tracker *ReplacementTracker
+	found   []*ReplacementEntry
+	nonces  map[string][]uint64 // from -> seen nonces
+
+	nReplacements         int64
+	nCancellations        int64
+	nChains               int64
+	nChainsIncluded       int64
+	nIncludedLatest       int64 // the last transaction of the chain was included
+	timeBetweenMs         []int64
+	feeCapBumpsGwei       []float64
+	nSenders              int64
+	nSendersWithNonceGaps int64
+	nMissingNonces        int64
+}
+
+func (s *replacementStats) add(tx *TxSummaryEntry) {
+	if s.tracker == nil {
+		s.tracker = NewReplacementTracker()
+		s.nonces = make(map[string][]uint64)
+	}
+
+	if r := s.tracker.Add(tx); r != nil {
+		s.found = append(s.found, r)
+	}
+	if nonce, err := strconv.ParseUint(tx.Nonce, 10, 64); err == nil {
+		from := strings.ToLower(tx.From)
+		s.nonces[from] = append(s.nonces[from], nonce)
+	}
+}
+
+func (s *replacementStats) finish() {
+	// replacement chains
+	latest := make(map[string]*ReplacementEntry) // from:nonce -> last replacement
+	for _, r := range s.found {
+		r.IncludedHash = s.tracker.IncludedHash(r.From, r.Nonce)
+		s.nReplacements += 1
+		if r.IsCancellation {
+			s.nCancellations += 1
+		}
+		s.timeBetweenMs = append(s.timeBetweenMs, r.TimeSinceReplacedMs)
+		if delta, ok := new(big.Float).SetString(r.GasFeeCapDelta); ok {
+			gwei, _ := new(big.Float).Quo(delta, big.NewFloat(1e9)).Float64()
+			s.feeCapBumpsGwei = append(s.feeCapBumpsGwei, gwei)
+		}
+		latest[replacementKey(r.From, r.Nonce)] = r
+	}
+	for _, r := range latest {
+		s.nChains += 1
+		if r.IncludedHash != "" {
+			s.nChainsIncluded += 1
+			if r.IncludedHash == r.Hash {
+				s.nIncludedLatest += 1
+			}
+		}
+	}
+	sort.Slice(s.timeBetweenMs, func(i, j int) bool { return s.timeBetweenMs[i] < s.timeBetweenMs[j] })
+	sort.Float64s(s.feeCapBumpsGwei)
+
+	// nonce gaps between the seen transactions of a sender (nonces that were never seen, between the lowest and highest)
+	for _, seen := range s.nonces {
+		slices.Sort(seen)
+		seen = slices.Compact(seen)
+		s.nSenders += 1
+		var missing uint64
+		for i := 1; i < len(seen); i++ {
+			missing += seen[i] - seen[i-1] - 1
+		}
+		if missing > 0 {
+			s.nSendersWithNonceGaps += 1
+			s.nMissingNonces += int64(missing) //nolint:gosec
+		}
+	}
+}
+
+// sprint prints the replacement and nonce gap stats
+func (s *replacementStats) sprint() string {
+	out := fmt.Sprintln("")
+	out += fmt.Sprintln("-------------------------")
+	out += fmt.Sprintln("Replacements & Nonce Gaps")
+	out += fmt.Sprintln("-------------------------")
+	out += fmt.Sprintln("")
+	out += Printer.Sprintf("Replacements:          %10d \n", s.nReplacements)
+	out += Printer.Sprintf("- Cancellations:       %10d (%5s) \n", s.nCancellations, Int64DiffPercentFmt(s.nCancellations, s.nReplacements, 1))
+	if s.nReplacements > 0 {
+		out += Printer.Sprintf("- Time between p50:    %10d ms \n", percentileInt64(s.timeBetweenMs, 50))
+		out += Printer.Sprintf("- Time between p90:    %10d ms \n", percentileInt64(s.timeBetweenMs, 90))
+	}
+	if len(s.feeCapBumpsGwei) > 0 {
+		out += Printer.Sprintf("- Fee cap bump p50:    %10.3f gwei \n", s.feeCapBumpsGwei[(len(s.feeCapBumpsGwei)-1)*50/100])
+	}
+	out += Printer.Sprintf("Replaced nonces:       %10d \n", s.nChains)
+	out += Printer.Sprintf("- Included:            %10d (%5s) \n", s.nChainsIncluded, Int64DiffPercentFmt(s.nChainsIncluded, s.nChains, 1))
+	out += Printer.Sprintf("- Latest tx included:  %10d (%5s) \n", s.nIncludedLatest, Int64DiffPercentFmt(s.nIncludedLatest, s.nChainsIncluded, 1))
+	out += Printer.Sprintf("Senders:               %10d \n", s.nSenders)
+	out += Printer.Sprintf("- With nonce gaps:     %10d (%5s) \n", s.nSendersWithNonceGaps, Int64DiffPercentFmt(s.nSendersWithNonceGaps, s.nSenders, 1))
+	out += Printer.Sprintf("- Missing nonces:      %10d \n", s.nMissingNonces)
+	return out
+}
+
+// sprintAnnouncementLatency prints how long it took from hash announcement to receiving the full transaction, per source
+func (a *Analyzer2) sprintAnnouncementLatency() string {
+	out := fmt.Sprintln("")
+	out += fmt.Sprintln("--------------------")
+	out += fmt.Sprintln("Announcement Latency")
+	out += fmt.Sprintln("--------------------")
+	out += fmt.Sprintln("")
+
+	buff := bytes.Buffer{}
+	table := tablewriter.NewWriter(&buff)
+	SetupMarkdownTableWriter(table)
+	table.SetHeader([]string{"Source", "Announced txs", "Body delay p50", "Body delay p90", "Body delay p99"})
+	for _, src := range a.announcedSources {
+		delays := a.announcementDelaysMs[src]
+		table.Append([]string{
+			src,
+			Printer.Sprintf("%10d (%5s)", len(delays), IntDiffPercentFmt(len(delays), int(a.nTransactionsPerSource[src]), 1)),
+			Printer.Sprintf("%d ms", percentileInt64(delays, 50)),
+			Printer.Sprintf("%d ms", percentileInt64(delays, 90)),
+			Printer.Sprintf("%d ms", percentileInt64(delays, 99)),
+		})
+	}
+	table.Render()
+	out += buff.String()
+	return out
+}
+
+// percentileInt64 returns the p-th percentile of an already sorted slice
+func percentileInt64(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := (len(sorted) - 1) * p / 100
+	return sorted[idx]
+}
+
 func (a *Analyzer2) WriteToFile(filename string) error {
 	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzer2_setCode(t *testing.T) {
+	tx, _ := newTestSetCodeTx(t)
+	summary, _, err := ParseTx(1693785600337, tx)
+	require.NoError(t, err)
+	summary.Sources = []string{"local"}
+
+	analyzer := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{summary.Hash: &summary},
+		Sourelog:     map[string]map[string]int64{strings.ToLower(summary.Hash): {"local": summary.Timestamp}},
+	})
+	out := analyzer.Sprint()
+	require.Contains(t, out, "Set-Code Transactions (EIP-7702)")
+	require.Contains(t, out, "| 0x63c0c19a282a1b52b07dd5a65b58948a07dae32b |")
+	require.Regexp(t, `- Invalid: +2 \( *66\.6%\)`, out)
+}
+
+func TestAnalyzer2_features(t *testing.T) {
+	summary, _, err := ParseTxRLP(1693785600337, test1Rlp)
+	require.NoError(t, err)
+	summary.Sources = []string{"local"}
+
+	analyzer := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{summary.Hash: &summary},
+		Sourelog:     map[string]map[string]int64{strings.ToLower(summary.Hash): {"local": summary.Timestamp}},
+	})
+	out := analyzer.Sprint()
+	require.Regexp(t, `Contract creations: +0 \( *0\.0%\)`, out)
+	require.Regexp(t, `With access list: +0 `, out)
+	require.Regexp(t, `Avg intrinsic gas: +21,064`, out)
+}
+
+func TestAnalyzer2_labels(t *testing.T) {
+	summary, _, err := ParseTxRLP(1693785600337, test1Rlp)
+	require.NoError(t, err)
+	summary.Sources = []string{"local"}
+	summary.ToLabel = "Test Contract"
+
+	labels, err := LoadLabels(nil, nil)
+	require.NoError(t, err)
+
+	analyzer := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: map[string]*TxSummaryEntry{summary.Hash: &summary},
+		Sourelog:     map[string]map[string]int64{strings.ToLower(summary.Hash): {"local": summary.Timestamp}},
+		Labels:       labels,
+	})
+	out := analyzer.Sprint()
+	require.Contains(t, out, "Top Methods")
+	require.Regexp(t, `\| 0x98e5b12a +\| +\| +1 \(100\.0%\) \| +1 \(100\.0%\) \|`, out)
+	require.Contains(t, out, "Top Contracts")
+	require.Contains(t, out, "| 0x0ed1bcc400acd34593451e76f854992198995f52 | Test Contract |")
+}
+
+func TestAnalyzer2_replacements(t *testing.T) {
+	to := "0x0ed1bcc400acd34593451e76f854992198995f52"
+	txs := []*TxSummaryEntry{
+		newTestReplacementTx("0x01", 1693785600000, "5", "10000000000", to),
+		newTestReplacementTx("0x02", 1693785601000, "5", "12000000000", to),
+		newTestReplacementTx("0x03", 1693785602000, "8", "10000000000", to),
+	}
+	txs[1].IncludedAtBlockHeight = 100
+
+	txMap := make(map[string]*TxSummaryEntry)
+	sourcelog := make(map[string]map[string]int64)
+	for _, tx := range txs {
+		tx.Sources = []string{"local"}
+		txMap[tx.Hash] = tx
+		sourcelog[tx.Hash] = map[string]int64{"local": tx.Timestamp}
+	}
+
+	analyzer := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions: txMap,
+		Sourelog:     sourcelog,
+	})
+	out := analyzer.Sprint()
+	require.Contains(t, out, "Replacements & Nonce Gaps")
+	require.Regexp(t, `Replacements: +1 `, out)
+	require.Regexp(t, `- Time between p50: +1,000 ms`, out)
+	require.Regexp(t, `- Fee cap bump p50: +2\.000 gwei`, out)
+	require.Regexp(t, `- Latest tx included: +1 \(100\.0%\)`, out)
+	require.Regexp(t, `- With nonce gaps: +1 \(100\.0%\)`, out)
+	require.Regexp(t, `- Missing nonces: +2 `, out)
+}
+
+func TestAnalyzer2_streaming(t *testing.T) {
+	tx1, _, err := ParseTxRLP(1693785600337, test1Rlp)
+	require.NoError(t, err)
+	tx1.Sources = []string{"local", "other"}
+	setCodeTx, _ := newTestSetCodeTx(t)
+	tx2, _, err := ParseTx(1693785600400, setCodeTx)
+	require.NoError(t, err)
+	tx2.Sources = []string{"local"}
+
+	hash1, hash2 := strings.ToLower(tx1.Hash), strings.ToLower(tx2.Hash)
+	sourcelog := map[string]map[string]int64{
+		hash1: {"local": tx1.Timestamp, "other": tx1.Timestamp + 10},
+		hash2: {"local": tx2.Timestamp},
+	}
+	announcements := map[string]map[string]int64{hash1: {"other": tx1.Timestamp - 5}}
+
+	analyzer := NewAnalyzer2(Analyzer2Opts{ //nolint:exhaustruct
+		Transactions:  map[string]*TxSummaryEntry{hash1: &tx1, hash2: &tx2},
+		Sourelog:      sourcelog,
+		Announcements: announcements,
+	})
+
+	// the streaming analyzer gets the same stats from the sorted transactions
+	streaming := NewStreamingAnalyzer2(Analyzer2Opts{}) //nolint:exhaustruct
+	for _, tx := range []*TxSummaryEntry{&tx1, &tx2} {
+		streaming.Add(tx)
+		streaming.AddAnnouncements(tx, announcements[strings.ToLower(tx.Hash)], sourcelog[strings.ToLower(tx.Hash)])
+	}
+	streaming.Finish()
+
+	out := analyzer.Sprint()
+	require.Contains(t, out, "Announcement Latency")
+	require.Equal(t, out, streaming.Sprint())
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// BlobSidecarCSVHeader is the header of the blob sidecar archive, which has one row per blob (keyed by tx hash and blob index)
+var BlobSidecarCSVHeader = []string{
+	"timestamp_ms",
+	"hash",
+	"blob_index",
+	"versioned_hash",
+	"commitment",
+	"proof",
+	"blob",
+}
+
+// BlobSidecarCSVRows returns the sidecar archive rows of a transaction (none if it's not a blob tx with sidecar)
+func BlobSidecarCSVRows(timestampMs int64, tx *types.Transaction) [][]string {
+	sidecar := tx.BlobTxSidecar()
+	if sidecar == nil {
+		return nil
+	}
+
+	hashes := tx.BlobHashes()
+	rows := make([][]string, 0, len(sidecar.Blobs))
+	for i := range sidecar.Blobs {
+		if i >= len(hashes) || i >= len(sidecar.Commitments) || i >= len(sidecar.Proofs) {
+			break // malformed sidecar, keep the complete blobs
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(timestampMs, 10),
+			tx.Hash().Hex(),
+			strconv.Itoa(i),
+			hashes[i].Hex(),
+			hexutil.Encode(sidecar.Commitments[i][:]),
+			hexutil.Encode(sidecar.Proofs[i][:]),
+			hexutil.Encode(sidecar.Blobs[i][:]),
+		})
+	}
+	return rows
+}
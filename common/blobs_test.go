@@ -0,0 +1,81 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBlobTx(t *testing.T, numBlobs int) *types.Transaction {
+	t.Helper()
+
+	sidecar := &types.BlobTxSidecar{} //nolint:exhaustruct
+	for i := range numBlobs {
+		var blob kzg4844.Blob
+		blob[1] = byte(i + 1)
+		commitment, err := kzg4844.BlobToCommitment(&blob)
+		require.NoError(t, err)
+		proof, err := kzg4844.ComputeBlobProof(&blob, commitment)
+		require.NoError(t, err)
+		sidecar.Blobs = append(sidecar.Blobs, blob)
+		sidecar.Commitments = append(sidecar.Commitments, commitment)
+		sidecar.Proofs = append(sidecar.Proofs, proof)
+	}
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.BlobTx{ //nolint:exhaustruct
+		ChainID:    uint256.NewInt(1),
+		GasTipCap:  uint256.NewInt(1_000_000_000),
+		GasFeeCap:  uint256.NewInt(20_000_000_000),
+		Gas:        21_000,
+		BlobFeeCap: uint256.NewInt(3_000_000_000),
+		BlobHashes: sidecar.BlobHashes(),
+		Sidecar:    sidecar,
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+func TestParseTx_blob(t *testing.T) {
+	tx := newTestBlobTx(t, 2)
+	summary, _, err := ParseTx(1693785600337, tx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), summary.BlobCount)
+	require.Equal(t, []string{tx.BlobHashes()[0].Hex(), tx.BlobHashes()[1].Hex()}, summary.BlobVersionedHashes)
+	require.Equal(t, "3000000000", summary.MaxFeePerBlobGas)
+	require.Equal(t, int64(2*131072), summary.BlobGas)
+
+	// the raw tx is the network encoding, including the sidecar
+	rawTx, err := summary.RawTxBytes()
+	require.NoError(t, err)
+	decoded := new(types.Transaction)
+	require.NoError(t, decoded.UnmarshalBinary(rawTx))
+	require.NotNil(t, decoded.BlobTxSidecar())
+	require.Len(t, decoded.BlobTxSidecar().Blobs, 2)
+
+	// the blob columns survive both parquet schema versions
+	v2, err := summary.ToV2()
+	require.NoError(t, err)
+	require.Equal(t, summary, *v2.ToV1())
+}
+
+func TestBlobSidecarCSVRows(t *testing.T) {
+	tx := newTestBlobTx(t, 2)
+	rows := BlobSidecarCSVRows(1693785600337, tx)
+	require.Len(t, rows, 2)
+	for i, row := range rows {
+		require.Len(t, row, len(BlobSidecarCSVHeader))
+		require.Equal(t, tx.Hash().Hex(), row[1])
+		require.Equal(t, tx.BlobHashes()[i].Hex(), row[3])
+		require.Len(t, row[6], 2+2*len(kzg4844.Blob{}))
+	}
+
+	// no rows without sidecar
+	require.Empty(t, BlobSidecarCSVRows(1693785600337, tx.WithoutBlobTxSidecar()))
+}
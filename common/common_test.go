@@ -1,14 +1,24 @@
 package common
 
 import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/require"
 	"github.com/xitongsys/parquet-go-source/local"
 	"github.com/xitongsys/parquet-go/parquet"
 	"github.com/xitongsys/parquet-go/reader"
 	"github.com/xitongsys/parquet-go/writer"
+	"go.uber.org/zap"
 )
 
 var (
@@ -125,3 +135,70 @@ func TestParquet(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, summary.Hash, summary2.Hash)
 }
+
+func TestParseTx_features(t *testing.T) {
+	summary, _, err := ParseTxRLP(1693785600337, test1Rlp)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.DataZeroBytes)
+	require.Equal(t, int64(4), summary.DataNonZeroBytes)
+	require.Equal(t, int64(21_000+4*16), summary.IntrinsicGas)
+	require.False(t, summary.IsContractCreation)
+	require.Empty(t, summary.ContractAddress)
+
+	// contract creation with access list
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.AccessListTx{ //nolint:exhaustruct
+		ChainID:  big.NewInt(1),
+		Nonce:    3,
+		GasPrice: big.NewInt(1_000_000_000),
+		Gas:      100_000,
+		Data:     []byte{0x60, 0x00, 0x00},
+		AccessList: types.AccessList{
+			{Address: common.HexToAddress("0x01"), StorageKeys: []common.Hash{{1}, {2}}},
+			{Address: common.HexToAddress("0x02"), StorageKeys: nil},
+		},
+	})
+	require.NoError(t, err)
+	summary, _, err = ParseTx(1693785600337, tx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), summary.AccessListAddressCount)
+	require.Equal(t, int64(2), summary.AccessListStorageKeyCount)
+	require.Equal(t, int64(2), summary.DataZeroBytes)
+	require.Equal(t, int64(1), summary.DataNonZeroBytes)
+	require.True(t, summary.IsContractCreation)
+	require.Equal(t, strings.ToLower(crypto.CreateAddress(crypto.PubkeyToAddress(key.PublicKey), 3).Hex()), summary.ContractAddress)
+	// 53000 (creation) + 2*4 + 16 (calldata) + 2 (initcode words) + 2*2400 + 2*1900 (access list)
+	require.Equal(t, int64(53_000+2*4+16+2+2*2400+2*1900), summary.IntrinsicGas)
+
+	v2, err := summary.ToV2()
+	require.NoError(t, err)
+	require.Equal(t, summary, *v2.ToV1())
+}
+
+func TestReadTransactionCSV(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "txs.csv.gz")
+	f, err := os.Create(fn)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = fmt.Fprintf(gz, "1693785600400,%s,%s\ninvalid\n1693785600337,%s,%s", test1Hash, test1Rlp, test1Hash, test1Rlp) // duplicate, no trailing newline
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	txs := make(map[string]*TxSummaryEntry)
+	err = ForEachCSVReader(fn, func(r io.Reader) error {
+		return ReadTransactionCSV(zap.NewNop().Sugar(), r, nil, txs)
+	})
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, int64(1693785600337), txs[strings.ToLower(test1Hash)].Timestamp)
+
+	// blacklisted
+	txs = make(map[string]*TxSummaryEntry)
+	err = ForEachCSVReader(fn, func(r io.Reader) error {
+		return ReadTransactionCSV(zap.NewNop().Sugar(), r, map[string]bool{strings.ToLower(test1Hash): true}, txs)
+	})
+	require.NoError(t, err)
+	require.Empty(t, txs)
+}
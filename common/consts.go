@@ -11,10 +11,12 @@ const (
 	SourceTagEden       = "eden"
 	SourceTagAlchemy    = "alchemy"
 	SourceTagInfura     = "infura"
+	SourceTagDevP2P     = "devp2p"
 
 	// Trash tx reasons
 	TrashTxAlreadyOnChain = "tx-already-onchain"
 	TrashTxSignatureError = "signature-error"
+	TrashTxDropped        = "dropped" // not processed because the collector was overloaded
 
 	// GRPCWindowSize is recommended window size by bloxroute-labs:
 	// https://docs.bloxroute.com/streams/working-with-streams/creating-a-subscription/grpc
@@ -2,11 +2,14 @@ package common
 
 import (
 	"archive/zip"
+	"compress/gzip"
 	"encoding/csv"
 	"errors"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/klauspost/compress/zstd"
 	"go.uber.org/zap"
 )
 
@@ -40,7 +43,7 @@ func MustBeFile(log *zap.SugaredLogger, fn string, extensions []string) {
 }
 
 func MustBeCSVFile(log *zap.SugaredLogger, fn string) {
-	MustBeFile(log, fn, []string{".csv", ".csv.zip"})
+	MustBeFile(log, fn, []string{".csv", ".csv.zip", ".csv.gz", ".csv.zst"})
 }
 
 func MustBeParquetFile(log *zap.SugaredLogger, fn string) {
@@ -59,17 +62,66 @@ func GetCSVFromFiles(filenames []string) (rows [][]string, err error) {
 	return rows, nil
 }
 
-// GetCSV returns a CSV content from a file (.csv or .csv.zip)
+// IsCompressedCSVFile returns true for CSV files written with compression by the collector (.csv.gz or .csv.zst)
+func IsCompressedCSVFile(filename string) bool {
+	return strings.HasSuffix(filename, ".csv.gz") || strings.HasSuffix(filename, ".csv.zst")
+}
+
+// OpenCompressedCSVFile opens a .csv.gz or .csv.zst file for reading the uncompressed content
+func OpenCompressedCSVFile(filename string) (io.ReadCloser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(filename, ".gz") {
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &compressedFileReader{Reader: r, f: f, close: r.Close}, nil
+	}
+
+	r, err := zstd.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &compressedFileReader{Reader: r, f: f, close: func() error { r.Close(); return nil }}, nil
+}
+
+type compressedFileReader struct {
+	io.Reader
+	f     *os.File
+	close func() error
+}
+
+func (r *compressedFileReader) Close() error {
+	return errors.Join(r.close(), r.f.Close())
+}
+
+// GetCSV returns a CSV content from a file (.csv, .csv.zip, .csv.gz or .csv.zst)
 func GetCSV(filename string) (rows [][]string, err error) {
 	rows = make([][]string, 0)
 
-	if strings.HasSuffix(filename, ".csv") {
+	if IsCompressedCSVFile(filename) {
+		r, err := OpenCompressedCSVFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		csvReader := csv.NewReader(r)
+		csvReader.FieldsPerRecord = -1
+		return csvReader.ReadAll()
+	} else if strings.HasSuffix(filename, ".csv") {
 		r, err := os.Open(filename)
 		if err != nil {
 			return nil, err
 		}
 		defer r.Close()
 		csvReader := csv.NewReader(r)
+		csvReader.FieldsPerRecord = -1 // rows may have optional columns (i.e. sourcelog announcement timestamp)
 		return csvReader.ReadAll()
 	} else if strings.HasSuffix(filename, ".zip") { // a zip file can contain many files
 		zipReader, err := zip.OpenReader(filename)
@@ -89,6 +141,7 @@ func GetCSV(filename string) (rows [][]string, err error) {
 			}
 			defer r.Close()
 			csvReader := csv.NewReader(r)
+			csvReader.FieldsPerRecord = -1
 			_rows, err := csvReader.ReadAll()
 			if err != nil {
 				return nil, err
@@ -99,3 +152,47 @@ func GetCSV(filename string) (rows [][]string, err error) {
 	}
 	return nil, ErrUnsupportedFileFormat
 }
+
+// ForEachCSVReader calls cb with a reader of the uncompressed CSV content of a file (.csv, .csv.gz or .csv.zst), or
+// once per CSV file in a .zip file
+func ForEachCSVReader(filename string, cb func(r io.Reader) error) error {
+	if IsCompressedCSVFile(filename) {
+		r, err := OpenCompressedCSVFile(filename)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return cb(r)
+	} else if strings.HasSuffix(filename, ".csv") {
+		r, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return cb(r)
+	} else if strings.HasSuffix(filename, ".zip") {
+		zipReader, err := zip.OpenReader(filename)
+		if err != nil {
+			return err
+		}
+		defer zipReader.Close()
+
+		for _, f := range zipReader.File {
+			if !strings.HasSuffix(f.Name, ".csv") {
+				continue
+			}
+
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = cb(r)
+			_ = r.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return ErrUnsupportedFileFormat
+}
@@ -0,0 +1,158 @@
+package common
+
+//
+// Labels name function selectors and addresses, fully offline: a bundled 4byte signature dump (common/labels/4byte.csv)
+// can be extended with more signature files, and addresses are labeled from user-supplied CSV files.
+//
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+//go:embed labels/4byte.csv
+var bundled4ByteCSV string
+
+var ErrInvalidLabelsFile = errors.New("invalid labels file")
+
+type Labels struct {
+	methods   map[string]string // selector (i.e. 0xa9059cbb) -> text signature
+	addresses map[string]string // lowercase address -> label
+}
+
+// LoadLabels loads the bundled 4byte dump, then the signature files (same format, or a 4byte.directory CSV export),
+// and the address label files (address,label). Signatures of later files override earlier ones.
+func LoadLabels(signatureFiles, addressLabelFiles []string) (*Labels, error) {
+	l := &Labels{
+		methods:   make(map[string]string),
+		addresses: make(map[string]string),
+	}
+
+	err := l.addSignatures(strings.NewReader(bundled4ByteCSV))
+	if err != nil {
+		return nil, fmt.Errorf("bundled 4byte dump: %w", err)
+	}
+
+	for _, fn := range signatureFiles {
+		err = l.loadFile(fn, l.addSignatures)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, fn := range addressLabelFiles {
+		err = l.loadFile(fn, l.addAddressLabels)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *Labels) loadFile(fn string, add func(r io.Reader) error) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = add(f); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	return nil
+}
+
+// addSignatures reads selector,signature rows (the signature may contain unquoted commas), or a 4byte.directory export
+// (id,created_at,text_signature,hex_signature,bytes_signature). The first signature of a selector in a file is used.
+func (l *Labels) addSignatures(r io.Reader) error {
+	rows, err := readLabelsCSV(r)
+	if err != nil {
+		return err
+	}
+
+	selectorIdx, signatureIdx := 0, 1
+	isExport := len(rows) > 0 && slices.Contains(rows[0], "hex_signature")
+	if isExport {
+		selectorIdx = slices.Index(rows[0], "hex_signature")
+		signatureIdx = slices.Index(rows[0], "text_signature")
+		rows = rows[1:]
+	}
+
+	added := make(map[string]bool)
+	for _, row := range rows {
+		if len(row) <= max(selectorIdx, signatureIdx) {
+			return fmt.Errorf("%w: %s", ErrInvalidLabelsFile, strings.Join(row, ","))
+		}
+		selector := strings.ToLower(row[selectorIdx])
+		if !strings.HasPrefix(selector, "0x") {
+			selector = "0x" + selector
+		}
+		if len(selector) != 10 {
+			continue // header
+		}
+
+		signature := row[signatureIdx]
+		if !isExport {
+			signature = strings.Join(row[signatureIdx:], ",")
+		}
+		if !added[selector] {
+			l.methods[selector] = signature
+			added[selector] = true
+		}
+	}
+	return nil
+}
+
+// addAddressLabels reads address,label rows
+func (l *Labels) addAddressLabels(r io.Reader) error {
+	rows, err := readLabelsCSV(r)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if len(row) < 2 {
+			return fmt.Errorf("%w: %s", ErrInvalidLabelsFile, strings.Join(row, ","))
+		}
+		address := strings.ToLower(strings.TrimSpace(row[0]))
+		if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+			continue // header
+		}
+		l.addresses[address] = strings.TrimSpace(strings.Join(row[1:], ","))
+	}
+	return nil
+}
+
+func readLabelsCSV(r io.Reader) ([][]string, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	return csvReader.ReadAll()
+}
+
+// MethodName returns the text signature of a selector (data4Bytes), or an empty string if it's unknown
+func (l *Labels) MethodName(data4Bytes string) string {
+	if l == nil || data4Bytes == "" {
+		return ""
+	}
+	return l.methods[strings.ToLower(data4Bytes)]
+}
+
+// AddressLabel returns the label of an address, or an empty string if it's unknown
+func (l *Labels) AddressLabel(address string) string {
+	if l == nil || address == "" {
+		return ""
+	}
+	return l.addresses[strings.ToLower(address)]
+}
+
+// Apply sets the method name and the label of the receiver of a transaction
+func (l *Labels) Apply(tx *TxSummaryEntry) {
+	tx.MethodName = l.MethodName(tx.Data4Bytes)
+	tx.ToLabel = l.AddressLabel(tx.To)
+}
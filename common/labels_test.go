@@ -0,0 +1,50 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadLabels(t *testing.T) {
+	dir := t.TempDir()
+	fnSignatures := filepath.Join(dir, "signatures.csv")
+	fnExport := filepath.Join(dir, "4byte_export.csv")
+	fnAddresses := filepath.Join(dir, "addresses.csv")
+	require.NoError(t, os.WriteFile(fnSignatures, []byte("selector,signature\n0x98e5b12a,updateFeed(bytes32,uint256)\n0x095ea7b3,approveCustom(address,uint256)\n"), 0o600))
+	require.NoError(t, os.WriteFile(fnExport, []byte("id,created_at,text_signature,hex_signature,bytes_signature\n1,2016-07-09,\"setOwner(address)\",0x13af4035,x\n2,2016-07-09,\"setOwnerAlt(address)\",0x13af4035,x\n"), 0o600))
+	require.NoError(t, os.WriteFile(fnAddresses, []byte("address,label\n0x0ED1BCC400ACD34593451E76F854992198995F52,Test Contract\n"), 0o600))
+
+	labels, err := LoadLabels([]string{fnSignatures, fnExport}, []string{fnAddresses})
+	require.NoError(t, err)
+
+	// bundled dump, and overrides
+	require.Equal(t, "transfer(address,uint256)", labels.MethodName("0xA9059CBB"))
+	require.Equal(t, "approveCustom(address,uint256)", labels.MethodName("0x095ea7b3"))
+	require.Equal(t, "updateFeed(bytes32,uint256)", labels.MethodName("0x98e5b12a"))
+	require.Equal(t, "setOwner(address)", labels.MethodName("0x13af4035"))
+	require.Equal(t, "", labels.MethodName("0xffffffff"))
+
+	require.Equal(t, "Test Contract", labels.AddressLabel("0x0ed1bcc400acd34593451e76f854992198995f52"))
+	require.Equal(t, "", labels.AddressLabel("0x0000000000000000000000000000000000000000"))
+
+	summary, _, err := ParseTxRLP(1693785600337, test1Rlp)
+	require.NoError(t, err)
+	labels.Apply(&summary)
+	require.Equal(t, "updateFeed(bytes32,uint256)", summary.MethodName)
+	require.Equal(t, "Test Contract", summary.ToLabel)
+
+	// nil labels (no lookups)
+	var noLabels *Labels
+	require.Equal(t, "", noLabels.MethodName("0xa9059cbb"))
+}
+
+func TestLoadLabels_invalidFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "addresses.csv")
+	require.NoError(t, os.WriteFile(fn, []byte("0x0ed1bcc400acd34593451e76f854992198995f52\n"), 0o600))
+
+	_, err := LoadLabels(nil, []string{fn})
+	require.ErrorIs(t, err, ErrInvalidLabelsFile)
+}
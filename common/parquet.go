@@ -0,0 +1,241 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+	"go.uber.org/zap"
+)
+
+// TxParquetWriter writes transactions to a parquet file, in the given schema version
+type TxParquetWriter struct {
+	schemaVersion int
+	fw            source.ParquetFile
+	pw            *writer.ParquetWriter
+}
+
+func NewTxParquetWriter(fn string, schemaVersion int) (*TxParquetWriter, error) {
+	var schema any
+	switch schemaVersion {
+	case ParquetSchemaV1:
+		schema = new(TxSummaryEntry)
+	case ParquetSchemaV2:
+		schema = new(TxSummaryEntryV2)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedParquetSchemaVer, schemaVersion)
+	}
+
+	fw, err := local.NewLocalFileWriter(fn)
+	if err != nil {
+		return nil, err
+	}
+	// one marshalling goroutine: with more, the dictionary pages depend on the scheduling (the file isn't reproducible)
+	pw, err := writer.NewParquetWriter(fw, schema, 1)
+	if err != nil {
+		_ = fw.Close()
+		return nil, err
+	}
+
+	// Parquet config: https://parquet.apache.org/docs/file-format/configurations/
+	pw.RowGroupSize = 128 * 1024 * 1024 // 128M
+	pw.PageSize = 1024 * 1024           // 1M
+
+	// Parquet compression: must be gzip for compatibility with both ClickHouse and S3 Select
+	pw.CompressionType = parquet.CompressionCodec_GZIP
+
+	version := strconv.Itoa(schemaVersion)
+	pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: ParquetSchemaVersionKey, Value: &version})
+
+	return &TxParquetWriter{schemaVersion: schemaVersion, fw: fw, pw: pw}, nil
+}
+
+func (w *TxParquetWriter) Write(tx *TxSummaryEntry) error {
+	if w.schemaVersion == ParquetSchemaV1 {
+		return w.pw.Write(tx)
+	}
+
+	v2, err := tx.ToV2()
+	if err != nil {
+		return fmt.Errorf("tx %s: %w", tx.Hash, err)
+	}
+	return w.pw.Write(v2)
+}
+
+// Close writes the footer, and closes the file
+func (w *TxParquetWriter) Close() error {
+	err := w.pw.WriteStop()
+	return errors.Join(err, w.fw.Close())
+}
+
+// ParquetSchemaVersion returns the schema version of a transactions parquet file
+func ParquetSchemaVersion(fn string) (int, error) {
+	footer, err := readParquetFooter(fn)
+	if err != nil {
+		return 0, err
+	}
+	return parquetSchemaVersionFromFooter(footer)
+}
+
+func readParquetFooter(fn string) (*parquet.FileMetaData, error) {
+	fr, err := local.NewLocalFileReader(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.ReadStop()
+	return pr.Footer, nil
+}
+
+func parquetSchemaVersionFromFooter(footer *parquet.FileMetaData) (int, error) {
+	for _, kv := range footer.KeyValueMetadata {
+		if kv.Key == ParquetSchemaVersionKey && kv.Value != nil {
+			version, err := strconv.Atoi(*kv.Value)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %s", ErrUnsupportedParquetSchemaVer, *kv.Value)
+			}
+			return version, nil
+		}
+	}
+	return ParquetSchemaV1, nil // files written before the schema was versioned
+}
+
+// parquetColumns returns the (lowercase) names of the top-level columns of a parquet file
+func parquetColumns(footer *parquet.FileMetaData) map[string]bool {
+	columns := make(map[string]bool)
+	if len(footer.Schema) == 0 {
+		return columns
+	}
+
+	// the schema is the depth-first list of the schema tree, skip the children of nested columns
+	skip := 0
+	for _, el := range footer.Schema[1:] {
+		if skip > 0 {
+			skip += int(el.GetNumChildren()) - 1
+			continue
+		}
+		columns[strings.ToLower(el.GetName())] = true
+		skip = int(el.GetNumChildren())
+	}
+	return columns
+}
+
+// ReadTransactionParquetFile reads a transactions parquet file (any schema version), and calls cb for every row until it returns false
+func ReadTransactionParquetFile(fn string, cb func(tx *TxSummaryEntry) bool) (schemaVersion int, err error) {
+	footer, err := readParquetFooter(fn)
+	if err != nil {
+		return 0, err
+	}
+	schemaVersion, err = parquetSchemaVersionFromFooter(footer)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := parquetColumns(footer)
+	switch schemaVersion {
+	case ParquetSchemaV1:
+		return schemaVersion, readParquetRows(fn, columns, cb)
+	case ParquetSchemaV2:
+		return schemaVersion, readParquetRows(fn, columns, func(tx *TxSummaryEntryV2) bool {
+			return cb(tx.ToV1())
+		})
+	default:
+		return schemaVersion, fmt.Errorf("%w: %d", ErrUnsupportedParquetSchemaVer, schemaVersion)
+	}
+}
+
+// readParquetRows reads the rows of a parquet file into T. Files written before a column was added to T are read
+// with a struct of only the columns they have, the other fields are left empty.
+func readParquetRows[T any](fn string, columns map[string]bool, cb func(row *T) bool) error {
+	typ := reflect.TypeFor[T]()
+	fileTyp, fieldIdx := parquetProjection(typ, columns)
+
+	fr, err := local.NewLocalFileReader(fn)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, reflect.New(fileTyp).Interface(), 4)
+	if err != nil {
+		return err
+	}
+	defer pr.ReadStop()
+
+	num := int(pr.GetNumRows())
+	for range num {
+		rows := reflect.New(reflect.SliceOf(fileTyp))
+		rows.Elem().Set(reflect.MakeSlice(reflect.SliceOf(fileTyp), 1, 1))
+		if err = pr.Read(rows.Interface()); err != nil {
+			return err
+		}
+
+		row := new(T)
+		src := rows.Elem().Index(0)
+		if fileTyp == typ {
+			reflect.ValueOf(row).Elem().Set(src)
+		} else {
+			dst := reflect.ValueOf(row).Elem()
+			for i, idx := range fieldIdx {
+				dst.Field(idx).Set(src.Field(i))
+			}
+		}
+
+		if !cb(row) {
+			break
+		}
+	}
+	return nil
+}
+
+// parquetProjection returns the struct type with the fields of typ that are columns of the file, and their indexes in typ
+func parquetProjection(typ reflect.Type, columns map[string]bool) (reflect.Type, []int) {
+	fields := make([]reflect.StructField, 0, typ.NumField())
+	fieldIdx := make([]int, 0, typ.NumField())
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("parquet"), ",")
+		if columns[strings.ToLower(strings.TrimPrefix(tag, "name="))] {
+			fields = append(fields, field)
+			fieldIdx = append(fieldIdx, i)
+		}
+	}
+
+	if len(fields) == typ.NumField() {
+		return typ, fieldIdx
+	}
+	return reflect.StructOf(fields), fieldIdx
+}
+
+// LoadTransactionParquetFiles loads transaction parquet files (any schema version) into a map[txHash]*TxSummaryEntry
+func LoadTransactionParquetFiles(log *zap.SugaredLogger, files []string) (txs map[string]*TxSummaryEntry, err error) {
+	txs = make(map[string]*TxSummaryEntry)
+	for _, filename := range files {
+		log.Infof("Loading %s ...", filename)
+		schemaVersion, err := ReadTransactionParquetFile(filename, func(tx *TxSummaryEntry) bool {
+			txs[strings.ToLower(tx.Hash)] = tx
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		log.Infow("Processed file",
+			"schemaVersion", schemaVersion,
+			"txTotal", Printer.Sprintf("%d", len(txs)),
+			"memUsed", GetMemUsageHuman(),
+		)
+	}
+	return txs, nil
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func TestTxParquetWriter(t *testing.T) {
+	summary, _, err := ParseTxRLP(1693785600337, test1Rlp)
+	require.NoError(t, err)
+	summary.Sources = []string{"local", "bloxroute"}
+	summary.Value = "115792089237316195423570985008687907853269984665640564039457584007913129639935" // max uint256
+
+	for _, schemaVersion := range ParquetSchemaVersions {
+		fn := filepath.Join(t.TempDir(), "test.parquet")
+		w, err := NewTxParquetWriter(fn, schemaVersion)
+		require.NoError(t, err)
+		require.NoError(t, w.Write(&summary))
+		require.NoError(t, w.Close())
+
+		var txs []*TxSummaryEntry
+		version, err := ReadTransactionParquetFile(fn, func(tx *TxSummaryEntry) bool {
+			txs = append(txs, tx)
+			return true
+		})
+		require.NoError(t, err)
+		require.Equal(t, schemaVersion, version)
+		require.Len(t, txs, 1)
+		require.Equal(t, summary, *txs[0])
+		require.Equal(t, test1Rlp, txs[0].RawTxHex())
+	}
+}
+
+func TestTxSummaryEntryToV2(t *testing.T) {
+	summary, _, err := ParseTxRLP(1693785600337, test1Rlp)
+	require.NoError(t, err)
+
+	v2, err := summary.ToV2()
+	require.NoError(t, err)
+	require.Equal(t, int64(353339), v2.Nonce)
+	require.Len(t, v2.GasFeeCap, 32)
+	require.Equal(t, summary, *v2.ToV1())
+
+	summary.Value = "-1"
+	_, err = summary.ToV2()
+	require.ErrorIs(t, err, ErrInvalidUint256)
+}
+
+// legacyTxSummaryEntry is the parquet schema of files written before the blob columns were added
+type legacyTxSummaryEntry struct {
+	Timestamp int64    `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Hash      string   `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	TxType    int64    `parquet:"name=txType, type=INT64, encoding=PLAIN_DICTIONARY"`
+	Sources   []string `parquet:"name=sources, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	RawTx     string   `parquet:"name=rawTx, type=BYTE_ARRAY, encoding=PLAIN, omitstats=true"`
+}
+
+func TestReadTransactionParquetFile_missingColumns(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "legacy.parquet")
+	fw, err := local.NewLocalFileWriter(fn)
+	require.NoError(t, err)
+	pw, err := writer.NewParquetWriter(fw, new(legacyTxSummaryEntry), 1)
+	require.NoError(t, err)
+	require.NoError(t, pw.Write(legacyTxSummaryEntry{Timestamp: 1, Hash: "0x01", TxType: 2, Sources: []string{"local"}, RawTx: "\x02"}))
+	require.NoError(t, pw.WriteStop())
+	require.NoError(t, fw.Close())
+
+	var txs []*TxSummaryEntry
+	version, err := ReadTransactionParquetFile(fn, func(tx *TxSummaryEntry) bool {
+		txs = append(txs, tx)
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, ParquetSchemaV1, version)
+	require.Equal(t, []*TxSummaryEntry{{Timestamp: 1, Hash: "0x01", TxType: 2, Sources: []string{"local"}, RawTx: "\x02"}}, txs) //nolint:exhaustruct
+}
+
+func TestTxParquetWriter_reproducible(t *testing.T) {
+	summary, _, err := ParseTxRLP(1693785600337, test1Rlp)
+	require.NoError(t, err)
+
+	// dictionary encoded columns with several values
+	txs := make([]*TxSummaryEntry, 10_000)
+	for i := range txs {
+		tx := summary
+		tx.To = fmt.Sprintf("0x%040x", i%7)
+		tx.Data4Bytes = fmt.Sprintf("0x%08x", i%5)
+		txs[i] = &tx
+	}
+
+	for _, schemaVersion := range ParquetSchemaVersions {
+		var files [][]byte
+		for range 2 {
+			fn := filepath.Join(t.TempDir(), "test.parquet")
+			w, err := NewTxParquetWriter(fn, schemaVersion)
+			require.NoError(t, err)
+			for _, tx := range txs {
+				require.NoError(t, w.Write(tx))
+			}
+			require.NoError(t, w.Close())
+
+			content, err := os.ReadFile(fn)
+			require.NoError(t, err)
+			files = append(files, content)
+		}
+		require.Equal(t, files[0], files[1], "schema version %d", schemaVersion)
+	}
+}
@@ -0,0 +1,282 @@
+package common
+
+//
+// Replacements are transactions with the same sender and nonce as an earlier transaction, but a different hash
+// (i.e. fee bumps and cancellations). Each (from, nonce) forms a replacement chain, ordered by the time the
+// transactions were first seen.
+//
+
+import (
+	"cmp"
+	"errors"
+	"iter"
+	"math/big"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+var ReplacementCSVHeader = []string{
+	"timestamp_ms",
+	"hash",
+	"replaced_hash",
+	"from",
+	"nonce",
+	"replaced_timestamp_ms",
+	"time_since_replaced_ms",
+	"chain_index",
+	"gas_fee_cap_delta",
+	"gas_tip_cap_delta",
+	"is_cancellation",
+	"included_hash",
+}
+
+// ReplacementEntry is a transaction that replaced an earlier transaction with the same sender and nonce
+type ReplacementEntry struct {
+	Timestamp    int64  `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"` // first seen of the replacing tx
+	Hash         string `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	ReplacedHash string `parquet:"name=replacedHash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	From         string `parquet:"name=from, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	Nonce        string `parquet:"name=nonce, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	ReplacedTimestamp   int64 `parquet:"name=replacedTimestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	TimeSinceReplacedMs int64 `parquet:"name=timeSinceReplacedMs, type=INT64"`
+	ChainIndex          int64 `parquet:"name=chainIndex, type=INT64"` // 1 for the first replacement of a (from, nonce)
+
+	// Fee deltas to the replaced tx in wei (decimal, negative if the fee was lowered)
+	GasFeeCapDelta string `parquet:"name=gasFeeCapDelta, type=BYTE_ARRAY, convertedtype=UTF8"`
+	GasTipCapDelta string `parquet:"name=gasTipCapDelta, type=BYTE_ARRAY, convertedtype=UTF8"`
+
+	IsCancellation bool   `parquet:"name=isCancellation, type=BOOLEAN"`                                                      // see IsCancellation
+	IncludedHash   string `parquet:"name=includedHash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"` // tx of the chain that was included (empty if none, or unknown)
+}
+
+func (r *ReplacementEntry) ToCSVRow() []string {
+	return []string{
+		strconv.FormatInt(r.Timestamp, 10),
+		r.Hash,
+		r.ReplacedHash,
+		r.From,
+		r.Nonce,
+		strconv.FormatInt(r.ReplacedTimestamp, 10),
+		strconv.FormatInt(r.TimeSinceReplacedMs, 10),
+		strconv.FormatInt(r.ChainIndex, 10),
+		r.GasFeeCapDelta,
+		r.GasTipCapDelta,
+		strconv.FormatBool(r.IsCancellation),
+		r.IncludedHash,
+	}
+}
+
+// IsCancellation returns true for the usual way to cancel a transaction: a zero-value transfer to self without calldata
+func (t *TxSummaryEntry) IsCancellation() bool {
+	return strings.EqualFold(t.From, t.To) && t.DataSize == 0 && (t.Value == "" || t.Value == "0")
+}
+
+// replacementChainEntry is the latest transaction of a (from, nonce)
+type replacementChainEntry struct {
+	hash       string
+	timestamp  int64
+	gasFeeCap  string
+	gasTipCap  string
+	chainIndex int64
+
+	includedHash string // tx of the chain that was included (if known)
+}
+
+// ReplacementTracker tracks the latest transaction per sender and nonce, to detect replacements. It's safe for
+// concurrent use. Transactions must be added in the order they were first seen, or queued (if they may arrive out of
+// order, i.e. from concurrent workers) and added in order with FlushQueue.
+type ReplacementTracker struct {
+	lock   sync.Mutex
+	latest map[string]replacementChainEntry // from:nonce -> latest tx
+
+	queueLock sync.Mutex
+	queue     []*TxSummaryEntry
+}
+
+func NewReplacementTracker() *ReplacementTracker {
+	return &ReplacementTracker{ //nolint:exhaustruct
+		latest: make(map[string]replacementChainEntry),
+	}
+}
+
+func replacementKey(from, nonce string) string {
+	return strings.ToLower(from) + ":" + nonce
+}
+
+// Add records a transaction, and returns the replacement if there was an earlier transaction with the same sender and
+// nonce (nil otherwise, or if the transaction was already added)
+func (t *ReplacementTracker) Add(tx *TxSummaryEntry) *ReplacementEntry {
+	key := replacementKey(tx.From, tx.Nonce)
+	hash := strings.ToLower(tx.Hash)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	prev, found := t.latest[key]
+	if found && prev.hash == hash {
+		return nil
+	}
+
+	entry := replacementChainEntry{
+		hash:         hash,
+		timestamp:    tx.Timestamp,
+		gasFeeCap:    tx.GasFeeCap,
+		gasTipCap:    tx.GasTipCap,
+		chainIndex:   0,
+		includedHash: prev.includedHash,
+	}
+	if tx.IncludedAtBlockHeight != 0 {
+		entry.includedHash = hash
+	}
+	if !found {
+		t.latest[key] = entry
+		return nil
+	}
+
+	entry.chainIndex = prev.chainIndex + 1
+	t.latest[key] = entry
+	return &ReplacementEntry{
+		Timestamp:           tx.Timestamp,
+		Hash:                hash,
+		ReplacedHash:        prev.hash,
+		From:                strings.ToLower(tx.From),
+		Nonce:               tx.Nonce,
+		ReplacedTimestamp:   prev.timestamp,
+		TimeSinceReplacedMs: tx.Timestamp - prev.timestamp,
+		ChainIndex:          entry.chainIndex,
+		GasFeeCapDelta:      bigDelta(tx.GasFeeCap, prev.gasFeeCap),
+		GasTipCapDelta:      bigDelta(tx.GasTipCap, prev.gasTipCap),
+		IsCancellation:      tx.IsCancellation(),
+		IncludedHash:        "",
+	}
+}
+
+// Queue buffers a transaction until FlushQueue, which adds the queued transactions in the order they were first seen
+func (t *ReplacementTracker) Queue(tx *TxSummaryEntry) {
+	t.queueLock.Lock()
+	defer t.queueLock.Unlock()
+	t.queue = append(t.queue, tx)
+}
+
+// FlushQueue adds the queued transactions in the order they were first seen, and returns the replacements. The
+// transactions seen within the last window (before the newest queued one) stay queued, as earlier ones may still be
+// queued after them. A zero window adds all queued transactions.
+func (t *ReplacementTracker) FlushQueue(window time.Duration) (replacements []*ReplacementEntry) {
+	t.queueLock.Lock()
+	slices.SortStableFunc(t.queue, func(a, b *TxSummaryEntry) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+	n := len(t.queue)
+	if n > 0 && window > 0 {
+		until := t.queue[n-1].Timestamp - window.Milliseconds()
+		n, _ = slices.BinarySearchFunc(t.queue, until+1, func(tx *TxSummaryEntry, ts int64) int {
+			return cmp.Compare(tx.Timestamp, ts)
+		})
+	}
+	txs := t.queue[:n]
+	t.queue = slices.Clone(t.queue[n:])
+	t.queueLock.Unlock()
+
+	for _, tx := range txs {
+		if r := t.Add(tx); r != nil {
+			replacements = append(replacements, r)
+		}
+	}
+	return replacements
+}
+
+// Prune removes the chains whose latest transaction was seen before the given time
+func (t *ReplacementTracker) Prune(before time.Time) (removed int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for key, entry := range t.latest {
+		if entry.timestamp < before.UnixMilli() {
+			delete(t.latest, key)
+			removed += 1
+		}
+	}
+	return removed
+}
+
+// IncludedHash returns the included transaction of a sender and nonce (empty if none was added as included)
+func (t *ReplacementTracker) IncludedHash(from, nonce string) string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.latest[replacementKey(from, nonce)].includedHash
+}
+
+func (t *ReplacementTracker) Len() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.latest)
+}
+
+// bigDelta returns a - b of two decimal strings (invalid values count as 0)
+func bigDelta(a, b string) string {
+	x, _ := new(big.Int).SetString(a, 10)
+	y, _ := new(big.Int).SetString(b, 10)
+	if x == nil {
+		x = new(big.Int)
+	}
+	if y == nil {
+		y = new(big.Int)
+	}
+	return new(big.Int).Sub(x, y).String()
+}
+
+// FindReplacements returns the replacements between the transactions (in order of first seen), with the included
+// transaction of each replacement chain
+func FindReplacements(txs []*TxSummaryEntry) []*ReplacementEntry {
+	sorted := make([]*TxSummaryEntry, len(txs))
+	copy(sorted, txs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+	return FindSortedReplacements(slices.Values(sorted))
+}
+
+// FindSortedReplacements is FindReplacements for transactions that are already sorted by timestamp (i.e. streamed)
+func FindSortedReplacements(txs iter.Seq[*TxSummaryEntry]) []*ReplacementEntry {
+	tracker := NewReplacementTracker()
+	replacements := make([]*ReplacementEntry, 0)
+	for tx := range txs {
+		if r := tracker.Add(tx); r != nil {
+			replacements = append(replacements, r)
+		}
+	}
+
+	for _, r := range replacements {
+		r.IncludedHash = tracker.IncludedHash(r.From, r.Nonce)
+	}
+	return replacements
+}
+
+// WriteReplacementsParquet writes the replacements to a parquet file
+func WriteReplacementsParquet(fn string, replacements []*ReplacementEntry) error {
+	fw, err := local.NewLocalFileWriter(fn)
+	if err != nil {
+		return err
+	}
+	pw, err := writer.NewParquetWriter(fw, new(ReplacementEntry), 1)
+	if err != nil {
+		_ = fw.Close()
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_GZIP
+
+	for _, r := range replacements {
+		if err = pw.Write(r); err != nil {
+			break
+		}
+	}
+	return errors.Join(err, pw.WriteStop(), fw.Close())
+}
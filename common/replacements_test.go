@@ -0,0 +1,99 @@
+package common
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestReplacementTx(hash string, timestamp int64, nonce, gasFeeCap, to string) *TxSummaryEntry {
+	return &TxSummaryEntry{ //nolint:exhaustruct
+		Timestamp: timestamp,
+		Hash:      hash,
+		From:      "0xD8aA8F3be2fB0C790D3579dcF68a04701C1e33DB",
+		To:        to,
+		Value:     "0",
+		Nonce:     nonce,
+		GasFeeCap: gasFeeCap,
+		GasTipCap: "1000000000",
+	}
+}
+
+func TestReplacementTracker(t *testing.T) {
+	tracker := NewReplacementTracker()
+	tx1 := newTestReplacementTx("0x01", 1000, "5", "10000000000", "0x0ed1bcc400acd34593451e76f854992198995f52")
+	tx2 := newTestReplacementTx("0x02", 1500, "5", "12000000000", "0xd8aa8f3be2fb0c790d3579dcf68a04701c1e33db")
+	tx3 := newTestReplacementTx("0x03", 1600, "6", "10000000000", "0x0ed1bcc400acd34593451e76f854992198995f52")
+
+	require.Nil(t, tracker.Add(tx1))
+	require.Nil(t, tracker.Add(tx1)) // same tx
+	require.Nil(t, tracker.Add(tx3)) // other nonce
+
+	r := tracker.Add(tx2)
+	require.NotNil(t, r)
+	require.Equal(t, "0x02", r.Hash)
+	require.Equal(t, "0x01", r.ReplacedHash)
+	require.Equal(t, "0xd8aa8f3be2fb0c790d3579dcf68a04701c1e33db", r.From)
+	require.Equal(t, "5", r.Nonce)
+	require.Equal(t, int64(500), r.TimeSinceReplacedMs)
+	require.Equal(t, int64(1), r.ChainIndex)
+	require.Equal(t, "2000000000", r.GasFeeCapDelta)
+	require.Equal(t, "0", r.GasTipCapDelta)
+	require.True(t, r.IsCancellation)
+
+	require.Equal(t, 2, tracker.Len())
+	require.Equal(t, 1, tracker.Prune(time.UnixMilli(1550)))
+	require.Equal(t, 1, tracker.Len())
+}
+
+func TestReplacementTracker_queue(t *testing.T) {
+	tracker := NewReplacementTracker()
+	tx1 := newTestReplacementTx("0x01", 1000, "5", "10000000000", "0x0ed1bcc400acd34593451e76f854992198995f52")
+	tx2 := newTestReplacementTx("0x02", 1500, "5", "12000000000", "0x0ed1bcc400acd34593451e76f854992198995f52")
+	tx3 := newTestReplacementTx("0x03", 3000, "5", "14000000000", "0x0ed1bcc400acd34593451e76f854992198995f52")
+
+	// queued out of order (i.e. by concurrent workers), added in the order they were first seen
+	tracker.Queue(tx2)
+	tracker.Queue(tx3)
+	tracker.Queue(tx1)
+
+	// the transactions within the window before the newest one stay queued
+	replacements := tracker.FlushQueue(time.Second)
+	require.Len(t, replacements, 1)
+	require.Equal(t, "0x02", replacements[0].Hash)
+	require.Equal(t, "0x01", replacements[0].ReplacedHash)
+
+	replacements = tracker.FlushQueue(0)
+	require.Len(t, replacements, 1)
+	require.Equal(t, "0x03", replacements[0].Hash)
+	require.Equal(t, "0x02", replacements[0].ReplacedHash)
+	require.Equal(t, int64(2), replacements[0].ChainIndex)
+	require.Empty(t, tracker.FlushQueue(0))
+}
+
+func TestFindReplacements(t *testing.T) {
+	to := "0x0ed1bcc400acd34593451e76f854992198995f52"
+	tx1 := newTestReplacementTx("0x01", 1000, "5", "10000000000", to)
+	tx2 := newTestReplacementTx("0x02", 2000, "5", "11000000000", to)
+	tx3 := newTestReplacementTx("0x03", 3000, "5", "9000000000", to)
+	tx2.IncludedAtBlockHeight = 100
+
+	// input order doesn't matter, chains are ordered by first seen
+	replacements := FindReplacements([]*TxSummaryEntry{tx3, tx1, tx2})
+	require.Len(t, replacements, 2)
+	require.Equal(t, "0x01", replacements[0].ReplacedHash)
+	require.Equal(t, "0x02", replacements[1].ReplacedHash)
+	require.Equal(t, int64(2), replacements[1].ChainIndex)
+	require.Equal(t, "-2000000000", replacements[1].GasFeeCapDelta)
+	require.False(t, replacements[1].IsCancellation)
+	for _, r := range replacements {
+		require.Equal(t, "0x02", r.IncludedHash)
+	}
+
+	// parquet output
+	fn := filepath.Join(t.TempDir(), "replacements.parquet")
+	require.NoError(t, WriteReplacementsParquet(fn, replacements))
+	require.Len(t, replacements[0].ToCSVRow(), len(ReplacementCSVHeader))
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Status of an EIP-7702 authorization. Only the checks that don't need chain state are done (not: authority nonce
+// and code), so a valid authorization may still be skipped on-chain.
+const (
+	AuthorizationValid            = "valid"
+	AuthorizationWrongChainID     = "wrong-chain-id"
+	AuthorizationNonceOverflow    = "nonce-overflow"
+	AuthorizationInvalidSignature = "invalid-signature"
+)
+
+var ErrInvalidAuthorizationEntry = errors.New("invalid authorization entry")
+
+// SetCodeAuthorization is an authorization of a set-code (type 4) transaction, with the recovered authority
+type SetCodeAuthorization struct {
+	ChainID   string
+	Address   string // the delegation target
+	Nonce     uint64
+	Authority string // the account setting its code, empty if the signature is invalid
+	Status    string
+}
+
+// DecodeSetCodeAuthorizations returns the authorization list of a set-code transaction (nil for other tx types)
+func DecodeSetCodeAuthorizations(tx *types.Transaction) []SetCodeAuthorization {
+	authList := tx.SetCodeAuthorizations()
+	if authList == nil {
+		return nil
+	}
+
+	auths := make([]SetCodeAuthorization, 0, len(authList))
+	for _, auth := range authList {
+		entry := SetCodeAuthorization{
+			ChainID: auth.ChainID.String(),
+			Address: strings.ToLower(auth.Address.Hex()),
+			Nonce:   auth.Nonce,
+			Status:  AuthorizationValid,
+		}
+
+		authority, err := auth.Authority()
+		if err == nil {
+			entry.Authority = strings.ToLower(authority.Hex())
+		}
+
+		// same order as the checks of the state transition
+		switch {
+		case !auth.ChainID.IsZero() && auth.ChainID.CmpBig(tx.ChainId()) != 0:
+			entry.Status = AuthorizationWrongChainID
+		case auth.Nonce == math.MaxUint64:
+			entry.Status = AuthorizationNonceOverflow
+		case err != nil:
+			entry.Status = AuthorizationInvalidSignature
+		}
+		auths = append(auths, entry)
+	}
+	return auths
+}
+
+// String returns the entry as stored in the summary files: <chainId>:<address>:<nonce>:<authority>:<status>
+func (a SetCodeAuthorization) String() string {
+	return fmt.Sprintf("%s:%s:%d:%s:%s", a.ChainID, a.Address, a.Nonce, a.Authority, a.Status)
+}
+
+func (a SetCodeAuthorization) IsValid() bool {
+	return a.Status == AuthorizationValid
+}
+
+// ParseSetCodeAuthorization parses an entry of the summary files (see SetCodeAuthorization.String)
+func ParseSetCodeAuthorization(s string) (SetCodeAuthorization, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 5 {
+		return SetCodeAuthorization{}, fmt.Errorf("%w: %s", ErrInvalidAuthorizationEntry, s)
+	}
+	nonce, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return SetCodeAuthorization{}, fmt.Errorf("%w: %s", ErrInvalidAuthorizationEntry, s)
+	}
+	return SetCodeAuthorization{
+		ChainID:   parts[0],
+		Address:   parts[1],
+		Nonce:     nonce,
+		Authority: parts[3],
+		Status:    parts[4],
+	}, nil
+}
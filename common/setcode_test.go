@@ -0,0 +1,65 @@
+package common
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSetCodeTx(t *testing.T) (tx *types.Transaction, authority common.Address) {
+	t.Helper()
+	target := common.HexToAddress("0x63c0c19a282a1b52b07dd5a65b58948a07dae32b")
+
+	authKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	valid, err := types.SignSetCode(authKey, types.SetCodeAuthorization{ChainID: *uint256.NewInt(1), Address: target, Nonce: 7}) //nolint:exhaustruct
+	require.NoError(t, err)
+	wrongChain, err := types.SignSetCode(authKey, types.SetCodeAuthorization{ChainID: *uint256.NewInt(5), Address: target, Nonce: 8}) //nolint:exhaustruct
+	require.NoError(t, err)
+	badSig := types.SetCodeAuthorization{ChainID: *uint256.NewInt(0), Address: target, Nonce: 9} //nolint:exhaustruct
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err = types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1)), &types.SetCodeTx{ //nolint:exhaustruct
+		ChainID:   uint256.NewInt(1),
+		GasTipCap: uint256.NewInt(1_000_000_000),
+		GasFeeCap: uint256.NewInt(20_000_000_000),
+		Gas:       100_000,
+		To:        crypto.PubkeyToAddress(authKey.PublicKey),
+		AuthList:  []types.SetCodeAuthorization{valid, wrongChain, badSig},
+	})
+	require.NoError(t, err)
+	return tx, crypto.PubkeyToAddress(authKey.PublicKey)
+}
+
+func TestParseTx_setCode(t *testing.T) {
+	tx, authority := newTestSetCodeTx(t)
+	summary, _, err := ParseTx(1693785600337, tx)
+	require.NoError(t, err)
+
+	authorityLower := strings.ToLower(authority.Hex())
+	require.Equal(t, int64(3), summary.AuthorizationCount)
+	require.Equal(t, int64(2), summary.InvalidAuthorizationCount)
+	require.Equal(t, []string{
+		"1:0x63c0c19a282a1b52b07dd5a65b58948a07dae32b:7:" + authorityLower + ":valid",
+		"5:0x63c0c19a282a1b52b07dd5a65b58948a07dae32b:8:" + authorityLower + ":wrong-chain-id",
+		"0:0x63c0c19a282a1b52b07dd5a65b58948a07dae32b:9::invalid-signature",
+	}, summary.Authorizations)
+
+	auth, err := ParseSetCodeAuthorization(summary.Authorizations[0])
+	require.NoError(t, err)
+	require.Equal(t, SetCodeAuthorization{ChainID: "1", Address: "0x63c0c19a282a1b52b07dd5a65b58948a07dae32b", Nonce: 7, Authority: authorityLower, Status: AuthorizationValid}, auth)
+	_, err = ParseSetCodeAuthorization("1:0x63c0c19a282a1b52b07dd5a65b58948a07dae32b")
+	require.ErrorIs(t, err, ErrInvalidAuthorizationEntry)
+
+	// the authorizations survive both parquet schema versions
+	v2, err := summary.ToV2()
+	require.NoError(t, err)
+	require.Equal(t, summary, *v2.ToV1())
+}
@@ -8,18 +8,26 @@ import (
 	"go.uber.org/zap"
 )
 
-// LoadSourcelogFiles loads sourcelog .csv (or .csv.zip) files (format: <timestamp_ms>,<tx_hash>,<source>) and returns a map[hash][source] = timestampMs
+// LoadSourcelogFiles loads sourcelog .csv (or .csv.zip) files (format: <timestamp_ms>,<tx_hash>,<source>[,<announced_ms>]) and returns a map[hash][source] = timestampMs
 func LoadSourcelogFiles(log *zap.SugaredLogger, files []string) (txs map[string]map[string]int64, cntProcessedRecords int64) {
+	txs, _, cntProcessedRecords = LoadSourcelogFilesWithAnnouncements(log, files)
+	return txs, cntProcessedRecords
+}
+
+// LoadSourcelogFilesWithAnnouncements loads sourcelog files like LoadSourcelogFiles, and additionally returns the optional
+// announcement timestamps (4th column) as map[hash][source] = announcedMs (only for entries that have one).
+func LoadSourcelogFilesWithAnnouncements(log *zap.SugaredLogger, files []string) (txs, announcements map[string]map[string]int64, cntProcessedRecords int64) {
 	txs = make(map[string]map[string]int64)
+	announcements = make(map[string]map[string]int64)
 
 	rows, err := GetCSVFromFiles(files)
 	if err != nil {
 		log.Errorw("GetCSV", "error", err)
-		return txs, cntProcessedRecords
+		return txs, announcements, cntProcessedRecords
 	}
 
 	for _, items := range rows {
-		if len(items) != 3 {
+		if len(items) != 3 && len(items) != 4 {
 			log.Errorw("invalid line", "line", items)
 			continue
 		}
@@ -59,7 +67,23 @@ func LoadSourcelogFiles(log *zap.SugaredLogger, files []string) (txs map[string]
 		if txs[txHash][txSource] == 0 || txTimestamp < txs[txHash][txSource] {
 			txs[txHash][txSource] = txTimestamp
 		}
+
+		// Optional announcement timestamp (empty or missing if the source doesn't expose announcements)
+		if len(items) == 4 && items[3] != "" {
+			announcedTs, err := strconv.ParseInt(items[3], 10, 64)
+			if err != nil {
+				log.Errorw("strconv.ParseInt", "error", err, "line", items)
+				continue
+			}
+
+			if _, ok := announcements[txHash]; !ok {
+				announcements[txHash] = make(map[string]int64)
+			}
+			if prev, ok := announcements[txHash][txSource]; !ok || announcedTs < prev {
+				announcements[txHash][txSource] = announcedTs
+			}
+		}
 	}
 
-	return txs, cntProcessedRecords
+	return txs, announcements, cntProcessedRecords
 }
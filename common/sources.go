@@ -0,0 +1,31 @@
+package common
+
+// SourceAll allows the transactions of any source in a SourceAllowList
+const SourceAll = "all"
+
+// SourceAllowList selects transactions by the source they were received from. It's used for the transactions sent to
+// the receivers, and by the API subscription filters.
+type SourceAllowList struct {
+	all     bool
+	sources map[string]bool
+}
+
+// NewSourceAllowList allows the given sources, or any source if the list contains "all"
+func NewSourceAllowList(sources []string) *SourceAllowList {
+	l := &SourceAllowList{sources: make(map[string]bool, len(sources))} //nolint:exhaustruct
+	for _, source := range sources {
+		if source == SourceAll {
+			l.all = true
+		}
+		l.sources[source] = true
+	}
+	return l
+}
+
+// Allows returns true if transactions of the source are allowed (a nil list allows no source)
+func (l *SourceAllowList) Allows(source string) bool {
+	if l == nil {
+		return false
+	}
+	return l.all || l.sources[source]
+}
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceAllowList(t *testing.T) {
+	l := NewSourceAllowList([]string{SourceTagLocal, SourceTagBloxroute})
+	require.True(t, l.Allows(SourceTagLocal))
+	require.True(t, l.Allows(SourceTagBloxroute))
+	require.False(t, l.Allows(SourceTagChainbound))
+
+	l = NewSourceAllowList([]string{SourceAll})
+	require.True(t, l.Allows(SourceTagLocal))
+	require.True(t, l.Allows("anything"))
+
+	require.False(t, NewSourceAllowList(nil).Allows(SourceTagLocal))
+
+	var nilList *SourceAllowList
+	require.False(t, nilList.Allows(SourceTagLocal))
+}
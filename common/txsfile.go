@@ -3,6 +3,7 @@ package common
 import (
 	"archive/zip"
 	"bufio"
+	"bytes"
 	"errors"
 	"io"
 	"os"
@@ -10,11 +11,13 @@ import (
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"go.uber.org/zap"
 )
 
-// LoadTransactionCSVFiles loads transaction CSV files into a map[txHash]*TxSummaryEntry
+// LoadTransactionCSVFiles loads transaction CSV files (.csv, .csv.zip, .csv.gz or .csv.zst) into a map[txHash]*TxSummaryEntry
 // All transactions occurring in []knownTxsFiles are skipped
 func LoadTransactionCSVFiles(log *zap.SugaredLogger, txInputFiles, txBlacklistFiles []string) (txs map[string]*TxSummaryEntry, err error) {
 	// load previously known transaction hashes
@@ -31,7 +34,19 @@ func LoadTransactionCSVFiles(log *zap.SugaredLogger, txInputFiles, txBlacklistFi
 		log.Infof("Loading %s ...", filename)
 		cntProcessedFiles += 1
 
-		if strings.HasSuffix(filename, ".csv") {
+		if IsCompressedCSVFile(filename) {
+			r, err := OpenCompressedCSVFile(filename)
+			if err != nil {
+				log.Errorw("OpenCompressedCSVFile", "error", err, "file", filename)
+				return nil, err
+			}
+			defer r.Close()
+			err = readTxFile(log, r, prevKnownTxs, &txs, true)
+			if err != nil {
+				log.Errorw("readTxFile", "error", err, "file", filename)
+				return nil, err
+			}
+		} else if strings.HasSuffix(filename, ".csv") {
 			readFile, err := os.Open(filename)
 			if err != nil {
 				log.Errorw("os.Open", "error", err, "file", filename)
@@ -80,6 +95,12 @@ func LoadTransactionCSVFiles(log *zap.SugaredLogger, txInputFiles, txBlacklistFi
 	return txs, nil
 }
 
+// ReadTransactionCSV reads transaction CSV lines (timestamp_ms,hash,raw_tx) into txs, like LoadTransactionCSVFiles:
+// transactions in prevKnownTxs are skipped, and duplicates keep the earliest timestamp
+func ReadTransactionCSV(log *zap.SugaredLogger, r io.Reader, prevKnownTxs map[string]bool, txs map[string]*TxSummaryEntry) error {
+	return readTxFile(log, r, prevKnownTxs, &txs, false)
+}
+
 // readTxFile reads a single transaction CSV file line-by-line
 func readTxFile(log *zap.SugaredLogger, rd io.Reader, prevKnownTxs map[string]bool, txs *map[string]*TxSummaryEntry, logProgress bool) (err error) {
 	cnt := 0
@@ -179,12 +200,13 @@ func ParseTx(timestampMs int64, tx *types.Transaction) (TxSummaryEntry, *types.T
 		data4Bytes = hexutil.Encode(tx.Data()[:4])
 	}
 
+	// network encoding: blob transactions include the sidecar (if the tx has one)
 	rawTxBytes, err := tx.MarshalBinary()
 	if err != nil {
 		return TxSummaryEntry{}, nil, err
 	}
 
-	return TxSummaryEntry{ //nolint:exhaustruct
+	summary := TxSummaryEntry{ //nolint:exhaustruct
 		Timestamp: timestampMs,
 		Hash:      tx.Hash().Hex(),
 
@@ -205,7 +227,47 @@ func ParseTx(timestampMs int64, tx *types.Transaction) (TxSummaryEntry, *types.T
 
 		RawTx:   string(rawTxBytes),
 		Sources: []string{},
-	}, tx, nil
+
+		BlobVersionedHashes: []string{},
+		Authorizations:      []string{},
+	}
+
+	if tx.Type() == types.BlobTxType {
+		summary.BlobCount = int64(len(tx.BlobHashes()))
+		for _, h := range tx.BlobHashes() {
+			summary.BlobVersionedHashes = append(summary.BlobVersionedHashes, h.Hex())
+		}
+		summary.MaxFeePerBlobGas = tx.BlobGasFeeCap().String()
+		summary.BlobGas = int64(tx.BlobGas()) //nolint:gosec
+	}
+
+	if tx.Type() == types.SetCodeTxType {
+		for _, auth := range DecodeSetCodeAuthorizations(tx) {
+			summary.Authorizations = append(summary.Authorizations, auth.String())
+			if !auth.IsValid() {
+				summary.InvalidAuthorizationCount += 1
+			}
+		}
+		summary.AuthorizationCount = int64(len(summary.Authorizations))
+	}
+
+	// calldata and access list features
+	for _, tuple := range tx.AccessList() {
+		summary.AccessListAddressCount += 1
+		summary.AccessListStorageKeyCount += int64(len(tuple.StorageKeys))
+	}
+	summary.DataZeroBytes = int64(bytes.Count(tx.Data(), []byte{0}))
+	summary.DataNonZeroBytes = summary.DataSize - summary.DataZeroBytes
+	summary.IsContractCreation = tx.To() == nil
+	if summary.IsContractCreation {
+		summary.ContractAddress = strings.ToLower(crypto.CreateAddress(from, tx.Nonce()).Hex())
+	}
+	// intrinsic gas with the current fork rules (only fails on overflow)
+	intrinsicGas, err := core.IntrinsicGas(tx.Data(), tx.AccessList(), tx.SetCodeAuthorizations(), summary.IsContractCreation, true, true, true)
+	if err == nil {
+		summary.IntrinsicGas = int64(intrinsicGas) //nolint:gosec
+	}
+	return summary, tx, nil
 }
 
 // LoadTxHashesFromMetadataCSVFiles loads transaction hashes from metadata CSV (or .csv.zip) files into a map[txHash]bool
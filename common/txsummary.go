@@ -33,6 +33,22 @@ var TxSummaryEntryCSVHeader = []string{
 	"included_block_timestamp_ms",
 	"inclusion_delay_ms",
 	"tx_type",
+	"blob_count",
+	"blob_versioned_hashes",
+	"max_fee_per_blob_gas",
+	"blob_gas",
+	"authorization_count",
+	"authorizations",
+	"invalid_authorization_count",
+	"access_list_address_count",
+	"access_list_storage_key_count",
+	"data_zero_bytes",
+	"data_nonzero_bytes",
+	"intrinsic_gas",
+	"is_contract_creation",
+	"contract_address",
+	"method_name",
+	"to_label",
 }
 
 // TxSummaryEntry is a struct that represents a single transaction in the summary CSV and Parquet file
@@ -65,7 +81,31 @@ type TxSummaryEntry struct {
 	IncludedBlockTimestamp int64 `parquet:"name=includedBlockTimestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
 	InclusionDelayMs       int64 `parquet:"name=inclusionDelayMs, type=INT64"`
 
-	// Finally, the raw transaction (not written to CSV)
+	// Blob transactions (type 3), empty for other types
+	BlobCount           int64    `parquet:"name=blobCount, type=INT64"`
+	BlobVersionedHashes []string `parquet:"name=blobVersionedHashes, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	MaxFeePerBlobGas    string   `parquet:"name=maxFeePerBlobGas, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	BlobGas             int64    `parquet:"name=blobGas, type=INT64"`
+
+	// Set-code transactions (type 4): the EIP-7702 authorization list, entries are <chainId>:<address>:<nonce>:<authority>:<status>
+	AuthorizationCount        int64    `parquet:"name=authorizationCount, type=INT64"`
+	Authorizations            []string `parquet:"name=authorizations, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	InvalidAuthorizationCount int64    `parquet:"name=invalidAuthorizationCount, type=INT64"`
+
+	// Calldata and access list features
+	AccessListAddressCount    int64  `parquet:"name=accessListAddressCount, type=INT64"`
+	AccessListStorageKeyCount int64  `parquet:"name=accessListStorageKeyCount, type=INT64"`
+	DataZeroBytes             int64  `parquet:"name=dataZeroBytes, type=INT64"`
+	DataNonZeroBytes          int64  `parquet:"name=dataNonZeroBytes, type=INT64"`
+	IntrinsicGas              int64  `parquet:"name=intrinsicGas, type=INT64"`
+	IsContractCreation        bool   `parquet:"name=isContractCreation, type=BOOLEAN"`
+	ContractAddress           string `parquet:"name=contractAddress, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"` // address of the created contract
+
+	// Labels (set by the merger, see Labels)
+	MethodName string `parquet:"name=methodName, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"` // text signature of data4Bytes
+	ToLabel    string `parquet:"name=toLabel, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+
+	// Finally, the raw transaction in network encoding, i.e. including the sidecar of blob transactions (not written to CSV)
 	RawTx string `parquet:"name=rawTx, type=BYTE_ARRAY, encoding=PLAIN, omitstats=true"`
 }
 
@@ -106,6 +146,22 @@ func (t *TxSummaryEntry) ToCSVRow() []string {
 		strconv.FormatInt(t.IncludedBlockTimestamp, 10),
 		strconv.FormatInt(t.InclusionDelayMs, 10),
 		strconv.FormatInt(t.TxType, 10),
+		strconv.FormatInt(t.BlobCount, 10),
+		strings.Join(t.BlobVersionedHashes, " "),
+		t.MaxFeePerBlobGas,
+		strconv.FormatInt(t.BlobGas, 10),
+		strconv.FormatInt(t.AuthorizationCount, 10),
+		strings.Join(t.Authorizations, " "),
+		strconv.FormatInt(t.InvalidAuthorizationCount, 10),
+		strconv.FormatInt(t.AccessListAddressCount, 10),
+		strconv.FormatInt(t.AccessListStorageKeyCount, 10),
+		strconv.FormatInt(t.DataZeroBytes, 10),
+		strconv.FormatInt(t.DataNonZeroBytes, 10),
+		strconv.FormatInt(t.IntrinsicGas, 10),
+		strconv.FormatBool(t.IsContractCreation),
+		t.ContractAddress,
+		t.MethodName,
+		t.ToLabel,
 	}
 }
 
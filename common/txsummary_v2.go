@@ -0,0 +1,207 @@
+package common
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Parquet schema versions of the transaction files. The version is stored in the file metadata (files without are v1).
+const (
+	ParquetSchemaV1 = 1 // TxSummaryEntry, numbers as UTF8 strings
+	ParquetSchemaV2 = 2 // TxSummaryEntryV2, numeric types
+
+	ParquetSchemaVersionKey = "mempool_dumpster_schema_version"
+)
+
+var (
+	ParquetSchemaVersions = []int{ParquetSchemaV1, ParquetSchemaV2}
+
+	ErrInvalidUint256              = errors.New("invalid uint256")
+	ErrUnsupportedParquetSchemaVer = errors.New("unsupported parquet schema version")
+)
+
+// TxSummaryEntryV2 is the parquet schema v2 of TxSummaryEntry, with numeric types:
+// - nonce and gas are INT64 annotated as UINT_64 (the Go fields hold the same bits as int64)
+// - value and the gas prices (incl. maxFeePerBlobGas) are FIXED_LEN_BYTE_ARRAY(32), the big-endian uint256
+// - rawTx is the binary transaction (as in v1)
+type TxSummaryEntryV2 struct {
+	Timestamp int64  `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Hash      string `parquet:"name=hash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+
+	ChainID string `parquet:"name=chainId, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN"`
+	TxType  int64  `parquet:"name=txType, type=INT64, encoding=PLAIN_DICTIONARY"`
+
+	From  string `parquet:"name=from, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"`
+	To    string `parquet:"name=to, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value string `parquet:"name=value, type=FIXED_LEN_BYTE_ARRAY, length=32, encoding=PLAIN, omitstats=true"`
+	Nonce int64  `parquet:"name=nonce, type=INT64, convertedtype=UINT_64"` // unsigned
+
+	Gas       int64  `parquet:"name=gas, type=INT64, convertedtype=UINT_64"` // unsigned
+	GasPrice  string `parquet:"name=gasPrice, type=FIXED_LEN_BYTE_ARRAY, length=32, encoding=PLAIN, omitstats=true"`
+	GasTipCap string `parquet:"name=gasTipCap, type=FIXED_LEN_BYTE_ARRAY, length=32, encoding=PLAIN, omitstats=true"`
+	GasFeeCap string `parquet:"name=gasFeeCap, type=FIXED_LEN_BYTE_ARRAY, length=32, encoding=PLAIN, omitstats=true"`
+
+	DataSize   int64  `parquet:"name=dataSize, type=INT64"`
+	Data4Bytes string `parquet:"name=data4Bytes, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+
+	Sources []string `parquet:"name=sources, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+
+	// Inclusion stats
+	IncludedAtBlockHeight  int64 `parquet:"name=includedAtBlockHeight, type=INT64"`
+	IncludedBlockTimestamp int64 `parquet:"name=includedBlockTimestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	InclusionDelayMs       int64 `parquet:"name=inclusionDelayMs, type=INT64"`
+
+	// Blob transactions (type 3)
+	BlobCount           int64    `parquet:"name=blobCount, type=INT64"`
+	BlobVersionedHashes []string `parquet:"name=blobVersionedHashes, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	MaxFeePerBlobGas    string   `parquet:"name=maxFeePerBlobGas, type=FIXED_LEN_BYTE_ARRAY, length=32, encoding=PLAIN, omitstats=true"`
+	BlobGas             int64    `parquet:"name=blobGas, type=INT64"`
+
+	// Set-code transactions (type 4)
+	AuthorizationCount        int64    `parquet:"name=authorizationCount, type=INT64"`
+	Authorizations            []string `parquet:"name=authorizations, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	InvalidAuthorizationCount int64    `parquet:"name=invalidAuthorizationCount, type=INT64"`
+
+	// Calldata and access list features
+	AccessListAddressCount    int64  `parquet:"name=accessListAddressCount, type=INT64"`
+	AccessListStorageKeyCount int64  `parquet:"name=accessListStorageKeyCount, type=INT64"`
+	DataZeroBytes             int64  `parquet:"name=dataZeroBytes, type=INT64"`
+	DataNonZeroBytes          int64  `parquet:"name=dataNonZeroBytes, type=INT64"`
+	IntrinsicGas              int64  `parquet:"name=intrinsicGas, type=INT64"`
+	IsContractCreation        bool   `parquet:"name=isContractCreation, type=BOOLEAN"`
+	ContractAddress           string `parquet:"name=contractAddress, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN, omitstats=true"` // address of the created contract
+
+	// Labels (set by the merger, see Labels)
+	MethodName string `parquet:"name=methodName, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"` // text signature of data4Bytes
+	ToLabel    string `parquet:"name=toLabel, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+
+	RawTx string `parquet:"name=rawTx, type=BYTE_ARRAY, encoding=PLAIN, omitstats=true"`
+}
+
+// ToV2 converts the entry to the parquet schema v2
+func (t *TxSummaryEntry) ToV2() (*TxSummaryEntryV2, error) {
+	v2 := &TxSummaryEntryV2{
+		Timestamp:              t.Timestamp,
+		Hash:                   t.Hash,
+		ChainID:                t.ChainID,
+		TxType:                 t.TxType,
+		From:                   t.From,
+		To:                     t.To,
+		DataSize:               t.DataSize,
+		Data4Bytes:             t.Data4Bytes,
+		Sources:                t.Sources,
+		IncludedAtBlockHeight:  t.IncludedAtBlockHeight,
+		IncludedBlockTimestamp: t.IncludedBlockTimestamp,
+		InclusionDelayMs:       t.InclusionDelayMs,
+		BlobCount:              t.BlobCount,
+		BlobVersionedHashes:    t.BlobVersionedHashes,
+		BlobGas:                t.BlobGas,
+		RawTx:                  t.RawTx,
+
+		AuthorizationCount:        t.AuthorizationCount,
+		Authorizations:            t.Authorizations,
+		InvalidAuthorizationCount: t.InvalidAuthorizationCount,
+
+		AccessListAddressCount:    t.AccessListAddressCount,
+		AccessListStorageKeyCount: t.AccessListStorageKeyCount,
+		DataZeroBytes:             t.DataZeroBytes,
+		DataNonZeroBytes:          t.DataNonZeroBytes,
+		IntrinsicGas:              t.IntrinsicGas,
+		IsContractCreation:        t.IsContractCreation,
+		ContractAddress:           t.ContractAddress,
+
+		MethodName: t.MethodName,
+		ToLabel:    t.ToLabel,
+	}
+
+	nonce, err := strconv.ParseUint(t.Nonce, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+	gas, err := strconv.ParseUint(t.Gas, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("gas: %w", err)
+	}
+	v2.Nonce, v2.Gas = int64(nonce), int64(gas) //nolint:gosec // stored as UINT_64
+	if v2.Value, err = uint256ToBytes(t.Value); err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+	if v2.GasPrice, err = uint256ToBytes(t.GasPrice); err != nil {
+		return nil, fmt.Errorf("gasPrice: %w", err)
+	}
+	if v2.GasTipCap, err = uint256ToBytes(t.GasTipCap); err != nil {
+		return nil, fmt.Errorf("gasTipCap: %w", err)
+	}
+	if v2.GasFeeCap, err = uint256ToBytes(t.GasFeeCap); err != nil {
+		return nil, fmt.Errorf("gasFeeCap: %w", err)
+	}
+	if v2.MaxFeePerBlobGas, err = uint256ToBytes(cmp.Or(t.MaxFeePerBlobGas, "0")); err != nil {
+		return nil, fmt.Errorf("maxFeePerBlobGas: %w", err)
+	}
+	return v2, nil
+}
+
+// ToV1 converts a v2 entry back to TxSummaryEntry
+func (t *TxSummaryEntryV2) ToV1() *TxSummaryEntry {
+	maxFeePerBlobGas := "" // empty for non-blob transactions, as in v1
+	if t.BlobCount > 0 {
+		maxFeePerBlobGas = uint256FromBytes(t.MaxFeePerBlobGas)
+	}
+
+	return &TxSummaryEntry{
+		Timestamp:              t.Timestamp,
+		Hash:                   t.Hash,
+		ChainID:                t.ChainID,
+		TxType:                 t.TxType,
+		From:                   t.From,
+		To:                     t.To,
+		Value:                  uint256FromBytes(t.Value),
+		Nonce:                  strconv.FormatUint(uint64(t.Nonce), 10), //nolint:gosec // stored as UINT_64
+		Gas:                    strconv.FormatUint(uint64(t.Gas), 10),   //nolint:gosec
+		GasPrice:               uint256FromBytes(t.GasPrice),
+		GasTipCap:              uint256FromBytes(t.GasTipCap),
+		GasFeeCap:              uint256FromBytes(t.GasFeeCap),
+		DataSize:               t.DataSize,
+		Data4Bytes:             t.Data4Bytes,
+		Sources:                t.Sources,
+		IncludedAtBlockHeight:  t.IncludedAtBlockHeight,
+		IncludedBlockTimestamp: t.IncludedBlockTimestamp,
+		InclusionDelayMs:       t.InclusionDelayMs,
+		BlobCount:              t.BlobCount,
+		BlobVersionedHashes:    t.BlobVersionedHashes,
+		MaxFeePerBlobGas:       maxFeePerBlobGas,
+		BlobGas:                t.BlobGas,
+		RawTx:                  t.RawTx,
+
+		AuthorizationCount:        t.AuthorizationCount,
+		Authorizations:            t.Authorizations,
+		InvalidAuthorizationCount: t.InvalidAuthorizationCount,
+
+		AccessListAddressCount:    t.AccessListAddressCount,
+		AccessListStorageKeyCount: t.AccessListStorageKeyCount,
+		DataZeroBytes:             t.DataZeroBytes,
+		DataNonZeroBytes:          t.DataNonZeroBytes,
+		IntrinsicGas:              t.IntrinsicGas,
+		IsContractCreation:        t.IsContractCreation,
+		ContractAddress:           t.ContractAddress,
+
+		MethodName: t.MethodName,
+		ToLabel:    t.ToLabel,
+	}
+}
+
+// uint256ToBytes converts a decimal string to the 32 byte big-endian representation
+func uint256ToBytes(s string) (string, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return "", fmt.Errorf("%w: %s", ErrInvalidUint256, s)
+	}
+	return string(n.FillBytes(make([]byte, 32))), nil
+}
+
+func uint256FromBytes(b string) string {
+	return new(big.Int).SetBytes([]byte(b)).String()
+}
@@ -8,15 +8,20 @@ import (
 )
 
 type TxIn struct {
-	T      time.Time
+	T      time.Time // when the full transaction was received
 	Tx     *types.Transaction
 	Source string
+
+	// AnnouncedAt is when the source first announced the tx hash (before delivering the full transaction).
+	// Only set by sources that expose hash announcements, zero otherwise.
+	AnnouncedAt time.Time
 }
 
 type BlxRawTxMsg struct {
 	Params struct {
 		Result struct {
-			RawTx string
+			RawTx  string
+			TxHash string
 		}
 	}
 }
@@ -10,6 +10,10 @@ var (
 	txReceived      = metrics.NewCounter("mempool_dumpster_tx_received_total")
 	txReceivedFirst = metrics.NewCounter("mempool_dumpster_tx_received_first")
 	txReceivedTrash = metrics.NewCounter("mempool_dumpster_tx_received_trash")
+	txDropped       = metrics.NewCounter("mempool_dumpster_tx_dropped_total")
+	txSpilled       = metrics.NewCounter("mempool_dumpster_tx_spilled_total")
+
+	sourceStalled = metrics.NewCounter("mempool_dumpster_source_stalled_total")
 
 	clickhouseErrors           = metrics.NewCounter("mempool_dumpster_clickhouse_errors_total")
 	clickhouseErrorsBatchSave  = metrics.NewCounter("mempool_dumpster_clickhouse_errors_batch_save_total")
@@ -22,9 +26,14 @@ const (
 	TxReceivedSourceLabel      = `mempool_dumpster_tx_received_total{source="%s"}`
 	TxReceivedFirstSourceLabel = `mempool_dumpster_tx_received_first{source="%s"}`
 	TxReceivedTrashLabel       = `mempool_dumpster_tx_received_trash{source="%s"}`
+	TxDroppedLabel             = `mempool_dumpster_tx_dropped_total{source="%s"}`
+	TxSpilledLabel             = `mempool_dumpster_tx_spilled_total{source="%s"}`
+	SourceStalledLabel         = `mempool_dumpster_source_stalled_total{source="%s"}`
 
 	ClickhouseBatchSaveTimeLabel = `mempool_dumpster_clickhouse_batch_save_duration_milliseconds{type="%s"}`
 	ClickhouseEntriesSavedLabel  = `mempool_dumpster_clickhouse_entries_saved_total{type="%s"}`
+
+	QueueDepthLabel = `mempool_dumpster_queue_depth{stage="%s"}`
 )
 
 func IncTxReceived(source string) {
@@ -45,6 +54,24 @@ func IncTxReceivedTrash(source string) {
 	metrics.GetOrCreateCounter(l).Inc()
 }
 
+func IncTxDropped(source string) {
+	txDropped.Inc()
+	l := fmt.Sprintf(TxDroppedLabel, source)
+	metrics.GetOrCreateCounter(l).Inc()
+}
+
+func IncTxSpilled(source string) {
+	txSpilled.Inc()
+	l := fmt.Sprintf(TxSpilledLabel, source)
+	metrics.GetOrCreateCounter(l).Inc()
+}
+
+func IncSourceStalled(source string) {
+	sourceStalled.Inc()
+	l := fmt.Sprintf(SourceStalledLabel, source)
+	metrics.GetOrCreateCounter(l).Inc()
+}
+
 func IncClickhouseError() {
 	clickhouseErrors.Inc()
 }
@@ -74,3 +101,9 @@ func AddClickhouseEntriesSaved(cntType string, cnt int) {
 	label := fmt.Sprintf(ClickhouseEntriesSavedLabel, cntType)
 	metrics.GetOrCreateCounter(label).Add(cnt)
 }
+
+// RegisterQueueDepth exposes the current length of a processing queue (only the first registration of a stage is used)
+func RegisterQueueDepth(stage string, f func() int) {
+	label := fmt.Sprintf(QueueDepthLabel, stage)
+	metrics.GetOrCreateGauge(label, func() float64 { return float64(f()) })
+}
@@ -0,0 +1,2 @@
+ALTER TABLE sourcelogs
+    ADD COLUMN IF NOT EXISTS announced_at Nullable(DateTime64(3)) COMMENT 'When the source first announced the tx hash (only for sources with hash announcements)';
@@ -0,0 +1,4 @@
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS authorization_count Int64 DEFAULT 0 COMMENT 'Number of EIP-7702 authorizations (set-code transactions, type 4)',
+    ADD COLUMN IF NOT EXISTS authorizations Array(String) COMMENT 'EIP-7702 authorizations, entries are <chainId>:<address>:<nonce>:<authority>:<status>',
+    ADD COLUMN IF NOT EXISTS invalid_authorization_count Int64 DEFAULT 0 COMMENT 'Number of authorizations that are invalid without chain state (chain id, nonce overflow, signature)';
@@ -0,0 +1,8 @@
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS access_list_address_count Int64 DEFAULT 0 COMMENT 'Number of addresses in the access list',
+    ADD COLUMN IF NOT EXISTS access_list_storage_key_count Int64 DEFAULT 0 COMMENT 'Number of storage keys in the access list',
+    ADD COLUMN IF NOT EXISTS data_zero_bytes Int64 DEFAULT 0 COMMENT 'Number of zero bytes in the calldata',
+    ADD COLUMN IF NOT EXISTS data_nonzero_bytes Int64 DEFAULT 0 COMMENT 'Number of non-zero bytes in the calldata',
+    ADD COLUMN IF NOT EXISTS intrinsic_gas Int64 DEFAULT 0 COMMENT 'Intrinsic gas with the current fork rules',
+    ADD COLUMN IF NOT EXISTS is_contract_creation Bool DEFAULT false COMMENT 'Whether the transaction creates a contract (no to address)',
+    ADD COLUMN IF NOT EXISTS contract_address String DEFAULT '' COMMENT 'Address of the created contract (derived from sender and nonce)';
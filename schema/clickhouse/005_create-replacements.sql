@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS replacements (
+    received_at DateTime64(3, 'UTC'),
+    hash String,
+    replaced_hash String,
+    from String,
+    nonce String,
+    replaced_received_at DateTime64(3, 'UTC'),
+    time_since_replaced_ms Int64,
+    chain_index Int64,
+    gas_fee_cap_delta String,
+    gas_tip_cap_delta String,
+    is_cancellation Bool,
+)
+ENGINE = ReplacingMergeTree
+PRIMARY KEY (hash)
+ORDER BY (hash)
+PARTITION BY toDate(received_at)
+COMMENT 'Transactions that replaced an earlier transaction with the same sender and nonce, as seen by the collector. The merger writes the inclusion status per chain (replacements files).';
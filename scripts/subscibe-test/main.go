@@ -31,10 +31,15 @@ func main() {
 func MainGeneric() {
 	txC := make(chan common.TxIn)
 	log := common.GetLogger(true, false)
-	nc := collector.NewNodeConnection(log, url, txC)
+	nc := collector.NewNodeConnection(collector.NodeConnectionOpts{
+		TxC:                txC,
+		Log:                log,
+		URI:                url,
+		TrackAnnouncements: true,
+	})
 	nc.StartInBackground()
 	for tx := range txC {
-		log.Infow("received tx", "tx", tx.Tx.Hash())
+		log.Infow("received tx", "tx", tx.Tx.Hash(), "announcedAt", tx.AnnouncedAt)
 	}
 }
 